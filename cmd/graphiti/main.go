@@ -0,0 +1,98 @@
+// Command graphiti inspects the Zep knowledge graph behind graphiti memories.
+//
+// Usage:
+//
+//	graphiti export (-user ID | -group ID) [-format dot|graphml|mermaid] [-o FILE] [-base-url URL]
+//
+// The API key is read from the ZEP_API_KEY environment variable.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/graphiti"
+	"github.com/getzep/zep-go/option"
+)
+
+const usage = `usage: graphiti <command> [flags]
+
+commands:
+  export    write a user or group graph as DOT, GraphML or Mermaid
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "export":
+		err = runExport(context.Background(), os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(os.Stdout, usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "graphiti: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runExport(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	userID := flags.String("user", "", "ID of the user whose graph to export")
+	groupID := flags.String("group", "", "ID of the group whose graph to export")
+	format := flags.String("format", string(graphiti.ExportFormatDOT), "output format: dot, graphml or mermaid")
+	output := flags.String("o", "", "output file (default stdout)")
+	baseURL := flags.String("base-url", "", "Zep API base URL")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if (*userID == "") == (*groupID == "") {
+		return errors.New("exactly one of -user or -group is required")
+	}
+	switch graphiti.ExportFormat(*format) {
+	case graphiti.ExportFormatDOT, graphiti.ExportFormatGraphML, graphiti.ExportFormatMermaid:
+	default:
+		return fmt.Errorf("unknown export format: %s", *format)
+	}
+
+	opts := []option.RequestOption{option.WithAPIKey(os.Getenv("ZEP_API_KEY"))}
+	if *baseURL != "" {
+		opts = append(opts, option.WithBaseURL(*baseURL))
+	}
+	client := graphiti.NewGraphClient(opts...)
+
+	var graph *graphiti.Graph
+	var err error
+	if *userID != "" {
+		graph, err = client.UserGraph(ctx, *userID)
+	} else {
+		graph, err = client.GroupGraph(ctx, *groupID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		return graph.Export(os.Stdout, graphiti.ExportFormat(*format))
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := graph.Export(f, graphiti.ExportFormat(*format)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
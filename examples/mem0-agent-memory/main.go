@@ -106,16 +106,8 @@ func main() {
 		log.Fatal("MEM0_API_KEY environment variable is not set")
 	}
 
-	// Initialize Mem0 client
-	mem0Client, err := client.NewMemoryClient(client.ClientOptions{
-		APIKey: apiKey,
-	})
-	if err != nil {
-		log.Fatalf("Failed to create Mem0 client: %v", err)
-	}
-
 	// Create memory with user ID
-	memory := mem0.NewMemory(mem0Client, "user-123",
+	memory := mem0.NewClientMemory(client.ClientOptions{APIKey: apiKey}, "user-123",
 		mem0.WithMemoryKey("chat_history"),
 		mem0.WithHumanPrefix("User"),
 		mem0.WithAIPrefix("Assistant"),
@@ -0,0 +1,347 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/0xDezzy/langchaingo-memory/memory/mem0"
+	"github.com/0xDezzy/langchaingo-memory/memory/mem0/mem0test"
+	"github.com/0xDezzy/langchaingo-memory/memory/memorytest"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/memory"
+	"github.com/tmc/langchaingo/schema"
+)
+
+const addPath = "/v1/memories/"
+
+// clock is a manual clock for breakers.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// blockingHistory is a history whose writes wait for release.
+type blockingHistory struct {
+	schema.ChatMessageHistory
+	release chan struct{}
+}
+
+func (h blockingHistory) AddMessage(ctx context.Context, message llms.ChatMessage) error {
+	<-h.release
+	return h.ChatMessageHistory.AddMessage(ctx, message)
+}
+
+// hangingHistory is a history whose requests wait for their context.
+type hangingHistory struct {
+	schema.ChatMessageHistory
+}
+
+func (hangingHistory) Messages(ctx context.Context) ([]llms.ChatMessage, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func newServer(t *testing.T) (*mem0test.Server, *mem0.ChatMessageHistory) {
+	t.Helper()
+
+	server := mem0test.NewServer()
+	t.Cleanup(server.Close)
+	client, err := server.NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return server, mem0.NewMem0ChatMessageHistory(client, "sarah")
+}
+
+func saveTurn(t *testing.T, m schema.Memory, i int) {
+	t.Helper()
+
+	err := m.SaveContext(context.Background(),
+		map[string]any{"input": fmt.Sprintf("question %d", i)},
+		map[string]any{"output": fmt.Sprintf("answer %d", i)})
+	if err != nil {
+		t.Fatalf("SaveContext: %v", err)
+	}
+}
+
+// load returns the conversation of the memory variables, and whether they are degraded.
+func load(t *testing.T, m schema.Memory) (string, bool) {
+	t.Helper()
+
+	variables, err := m.LoadMemoryVariables(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("LoadMemoryVariables: %v", err)
+	}
+	var c []string
+	for _, message := range variables["history"].([]llms.ChatMessage) {
+		if message.GetType() != llms.ChatMessageTypeSystem {
+			c = append(c, message.GetContent())
+		}
+	}
+	degraded, _ := variables[DefaultDegradedKey].(bool)
+	return strings.Join(c, "|"), degraded
+}
+
+func TestBreaker(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	failure := &core.BackendError{Kind: core.ErrUnavailable, StatusCode: http.StatusServiceUnavailable}
+	rejected := &core.BackendError{Kind: core.ErrInvalidInput, StatusCode: http.StatusBadRequest}
+
+	c := &clock{now: time.Unix(0, 0)}
+	var changes []string
+	b := NewBreaker(WithFailureThreshold(2), WithOpenTimeout(time.Minute), WithClock(c.Now),
+		WithOnStateChange(func(from, to State) { changes = append(changes, from.String()+">"+to.String()) }))
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	b.allow()
+	b.done(ctx, failure)
+	b.allow()
+	b.done(cancelled, context.Canceled)
+	if b.State() != StateClosed || !b.allow() {
+		t.Fatalf("Expected a failure and a cancelled request to keep the breaker closed, got %v", b.State())
+	}
+	b.done(ctx, rejected)
+	b.allow()
+	b.done(ctx, failure)
+	if b.State() != StateClosed || !b.allow() {
+		t.Fatalf("Expected a rejected request not to count as a failure, got %v", b.State())
+	}
+	b.done(ctx, failure)
+	if b.State() != StateOpen || b.allow() {
+		t.Fatalf("Expected 2 failures to open the breaker, ignoring cancelled requests, got %v", b.State())
+	}
+
+	c.Advance(time.Minute)
+	if b.State() != StateHalfOpen || !b.allow() || b.allow() {
+		t.Fatalf("Expected a single probe after the open timeout")
+	}
+	b.done(ctx, failure)
+	if b.State() != StateOpen {
+		t.Fatalf("Expected a failed probe to open the breaker again, got %v", b.State())
+	}
+
+	c.Advance(time.Minute)
+	if !b.allow() {
+		t.Fatalf("Expected a probe after the open timeout")
+	}
+	b.done(ctx, nil)
+	if b.State() != StateClosed {
+		t.Fatalf("Expected a successful probe to close the breaker, got %v", b.State())
+	}
+
+	expected := "closed>open|open>half-open|half-open>open|open>half-open|half-open>closed"
+	if got := strings.Join(changes, "|"); got != expected {
+		t.Errorf("Expected state changes %q, got %q", expected, got)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	t.Parallel()
+
+	t.Run("DegradesAndRecovers", func(t *testing.T) {
+		t.Parallel()
+		server, remote := newServer(t)
+		c := &clock{now: time.Unix(0, 0)}
+		m := NewMemory(remote, WithBreaker(NewBreaker(WithFailureThreshold(1), WithClock(c.Now))))
+
+		saveTurn(t, m, 1)
+		if got, degraded := load(t, m); got != "question 1|answer 1" || degraded {
+			t.Fatalf("Expected the turn from mem0, got %q, degraded %v", got, degraded)
+		}
+
+		server.InjectFault(memorytest.Fault{StatusCode: http.StatusServiceUnavailable})
+		if got, degraded := load(t, m); got != "question 1|answer 1" || !degraded {
+			t.Errorf("Expected the cached turn flagged as degraded, got %q, degraded %v", got, degraded)
+		}
+		if m.History.Breaker.State() != StateOpen {
+			t.Fatalf("Expected the failure to open the breaker")
+		}
+		requests := server.RequestCount(http.MethodPost, addPath)
+		saveTurn(t, m, 2)
+		if got, degraded := load(t, m); got != "question 1|answer 1|question 2|answer 2" || !degraded {
+			t.Errorf("Expected the queued turn in the cache, got %q, degraded %v", got, degraded)
+		}
+		if n := server.RequestCount(http.MethodPost, addPath); n != requests || m.History.Queued() != 1 {
+			t.Errorf("Expected the turn to be queued without a request, got %d requests", n-requests)
+		}
+
+		server.ClearFaults()
+		c.Advance(30 * time.Second)
+		if got, degraded := load(t, m); got != "question 1|answer 1|question 2|answer 2" || degraded {
+			t.Errorf("Expected the probe to send the queued turn and recover, got %q, degraded %v", got, degraded)
+		}
+		if m.History.Breaker.State() != StateClosed || m.History.Queued() != 0 {
+			t.Errorf("Expected the breaker to close and the queue to be empty")
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		t.Parallel()
+		m := NewMemory(hangingHistory{}, WithTimeout(10*time.Millisecond))
+		if got, degraded := load(t, m); got != "" || !degraded {
+			t.Errorf("Expected an empty degraded load, got %q, degraded %v", got, degraded)
+		}
+	})
+
+	t.Run("QueueFull", func(t *testing.T) {
+		t.Parallel()
+		server, remote := newServer(t)
+		server.InjectFault(memorytest.Fault{Method: http.MethodPost, StatusCode: http.StatusTooManyRequests})
+		m := NewMemory(remote, WithMaxQueued(1))
+		saveTurn(t, m, 1)
+		err := m.SaveContext(context.Background(), map[string]any{"input": "x"}, map[string]any{"output": "y"})
+		if !errors.Is(err, ErrQueueFull) {
+			t.Errorf("Expected ErrQueueFull, got %v", err)
+		}
+	})
+
+	t.Run("NoReplayOfPossiblyApplied", func(t *testing.T) {
+		t.Parallel()
+		server, remote := newServer(t)
+		var errs []error
+		m := NewMemory(remote, WithOnError(func(_ context.Context, err error) { errs = append(errs, err) }))
+		server.InjectFault(memorytest.Fault{Method: http.MethodPost, StatusCode: http.StatusServiceUnavailable, Times: 1})
+		saveTurn(t, m, 1)
+		if m.History.Queued() != 0 || len(errs) != 1 {
+			t.Fatalf("Expected a write failing with a 503 to be reported, not queued, got %d queued, %v", m.History.Queued(), errs)
+		}
+
+		saveTurn(t, m, 2)
+		if n := server.RequestCount(http.MethodPost, addPath); n != 2 {
+			t.Errorf("Expected the failed write not to be sent again, got %d requests", n)
+		}
+	})
+
+	t.Run("DropsRejected", func(t *testing.T) {
+		t.Parallel()
+		server, remote := newServer(t)
+		var errs []error
+		m := NewMemory(remote, WithBreaker(NewBreaker(WithFailureThreshold(1))),
+			WithOnError(func(_ context.Context, err error) { errs = append(errs, err) }))
+		server.InjectFault(memorytest.Fault{Method: http.MethodPost, StatusCode: http.StatusBadRequest, Times: 1})
+		saveTurn(t, m, 1)
+
+		var dropped *DroppedWriteError
+		if len(errs) != 1 || !errors.As(errs[0], &dropped) || !errors.Is(dropped, core.ErrInvalidInput) ||
+			len(dropped.Messages) != 2 {
+			t.Fatalf("Expected the rejected turn to be reported as dropped, got %v", errs)
+		}
+		if m.History.Queued() != 0 || m.History.Breaker.State() != StateClosed {
+			t.Errorf("Expected the rejected turn neither to be queued nor to open the breaker")
+		}
+		if got, _ := load(t, m); got != "" {
+			t.Errorf("Expected the rejected turn to be left out of the cache, got %q", got)
+		}
+	})
+
+	t.Run("ReadsDuringWrites", func(t *testing.T) {
+		t.Parallel()
+		release := make(chan struct{})
+		h := NewChatMessageHistory(blockingHistory{memory.NewChatMessageHistory(), release})
+		done := make(chan error)
+		go func() { done <- h.AddUserMessage(context.Background(), "hi") }()
+
+		read := make(chan struct{})
+		go func() {
+			h.Queued()
+			h.Messages(context.Background())
+			close(read)
+		}()
+		select {
+		case <-read:
+		case <-time.After(time.Second):
+			t.Errorf("Expected reads not to wait for a write in flight")
+		}
+		close(release)
+		if err := <-done; err != nil {
+			t.Errorf("AddUserMessage: %v", err)
+		}
+	})
+
+	t.Run("MemoryVariables", func(t *testing.T) {
+		t.Parallel()
+		_, remote := newServer(t)
+		m := NewMemory(remote, WithDegradedKey("stale"), WithMemoryOptions(core.WithMemoryKey("chat")))
+		if got := m.MemoryVariables(context.Background()); !slices.Equal(got, []string{"chat", "stale"}) {
+			t.Errorf("Expected the memory key and the degraded key, got %v", got)
+		}
+	})
+
+	t.Run("ClearWhileOpen", func(t *testing.T) {
+		t.Parallel()
+		server, remote := newServer(t)
+		server.InjectFault(memorytest.Fault{StatusCode: http.StatusServiceUnavailable})
+		m := NewMemory(remote, WithBreaker(NewBreaker(WithFailureThreshold(1))))
+		load(t, m)
+		if err := m.Clear(context.Background()); !errors.Is(err, ErrOpen) {
+			t.Errorf("Expected ErrOpen, got %v", err)
+		}
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		t.Parallel()
+		_, remote := newServer(t)
+		m := NewMemory(remote)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := m.LoadMemoryVariables(ctx, nil); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected a cancelled load to fail rather than degrade, got %v", err)
+		}
+	})
+}
+
+func TestResolver(t *testing.T) {
+	t.Parallel()
+
+	server := mem0test.NewServer()
+	t.Cleanup(server.Close)
+	remote := mem0.NewClientChatMessageHistory(server.ClientOptions(), "", mem0.WithChatHistoryResolver(core.ResolveFromContext))
+	h := NewChatMessageHistory(remote)
+	for _, userID := range []string{"sarah", "john"} {
+		ctx := core.ContextWithUserID(context.Background(), userID)
+		if err := h.AddUserMessage(ctx, "I am "+userID); !errors.Is(err, core.ErrResolverUnsupported) {
+			t.Errorf("Expected ErrResolverUnsupported for %s, got %v", userID, err)
+		}
+		if _, err := h.Messages(ctx); !errors.Is(err, core.ErrResolverUnsupported) {
+			t.Errorf("Expected a load for %s to fail rather than degrade, got %v", userID, err)
+		}
+	}
+	if local, _ := h.Local.Messages(context.Background()); len(local) != 0 || h.Queued() != 0 {
+		t.Errorf("Expected nothing cached or queued, got %v and %d writes", local, h.Queued())
+	}
+}
+
+func TestConformance(t *testing.T) {
+	t.Parallel()
+
+	memorytest.RunChatMessageHistorySuite(t, func(t *testing.T) schema.ChatMessageHistory {
+		_, remote := newServer(t)
+		return NewChatMessageHistory(remote)
+	}, memorytest.WithoutSetMessages())
+	memorytest.RunMemorySuite(t, func(t *testing.T) schema.Memory {
+		_, remote := newServer(t)
+		return NewMemory(remote)
+	})
+}
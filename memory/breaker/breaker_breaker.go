@@ -0,0 +1,137 @@
+// Package breaker keeps an agent running while mem0 or Zep is down. A circuit breaker trips
+// open after repeated backend failures; while it is open, loads return the locally cached
+// history and are flagged as degraded, and writes are queued until a probe finds the backend
+// healthy again.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+)
+
+// ErrOpen is returned for requests that cannot be degraded, like Clear, while the breaker is
+// open.
+var ErrOpen = errors.New("breaker: circuit open")
+
+// State is the state of a Breaker.
+type State int
+
+const (
+	// StateClosed lets every request through.
+	StateClosed State = iota
+	// StateOpen rejects every request until the open timeout has passed.
+	StateOpen
+	// StateHalfOpen lets a single probe request through. Its success closes the breaker, its
+	// failure opens it again.
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Breaker is a circuit breaker for a backend. Share one Breaker between the histories of
+// every session of a backend, so that they trip together. It is safe for concurrent use.
+type Breaker struct {
+	failureThreshold int
+	openTimeout      time.Duration
+	now              func() time.Time
+	onStateChange    func(from, to State)
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	// probing is set while the probe of the half-open state is in flight.
+	probing bool
+}
+
+// NewBreaker creates a closed breaker.
+func NewBreaker(options ...BreakerOption) *Breaker {
+	return applyBreakerOptions(options...)
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.openTimeout {
+		return StateHalfOpen
+	}
+	return b.state
+}
+
+// allow reports whether a request may be sent. Every allowed request must be followed by a
+// call to done.
+func (b *Breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.setState(StateHalfOpen)
+		fallthrough
+	case StateHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+	}
+	return true
+}
+
+// done records the outcome of an allowed request. Requests cancelled by their caller neither
+// count as a success nor as a failure. Only transient failures count as failures: a request
+// the backend rejected, as invalid or unauthorized, shows that it is available.
+func (b *Breaker) done(ctx context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	switch {
+	case ctx.Err() != nil && err != nil:
+	case err == nil || !transient(err):
+		b.failures = 0
+		if b.state != StateClosed {
+			b.setState(StateClosed)
+		}
+	default:
+		b.failures++
+		if b.state == StateHalfOpen || b.failures >= b.failureThreshold {
+			b.openedAt = b.now()
+			b.setState(StateOpen)
+		}
+	}
+}
+
+// transient reports whether err shows that the backend is unavailable, rather than that it
+// rejected the request.
+func transient(err error) bool {
+	return errors.Is(err, core.ErrUnavailable) || core.ClassifyError(err) != core.NotRetryable
+}
+
+// setState changes the state. The caller must hold b.mu.
+func (b *Breaker) setState(state State) {
+	from := b.state
+	b.state = state
+	if state == StateOpen {
+		b.failures = 0
+	}
+	if b.onStateChange != nil && from != state {
+		b.onStateChange(from, state)
+	}
+}
@@ -0,0 +1,53 @@
+package breaker
+
+import "time"
+
+// BreakerOption is a function for creating a new breaker
+// with other than the default values.
+type BreakerOption func(b *Breaker)
+
+// WithFailureThreshold is an option for specifying how many consecutive failures trip the
+// breaker open. Defaults to 5.
+func WithFailureThreshold(failureThreshold int) BreakerOption {
+	return func(b *Breaker) {
+		b.failureThreshold = failureThreshold
+	}
+}
+
+// WithOpenTimeout is an option for specifying how long the breaker stays open before it lets a
+// probe through. Defaults to 30s.
+func WithOpenTimeout(openTimeout time.Duration) BreakerOption {
+	return func(b *Breaker) {
+		b.openTimeout = openTimeout
+	}
+}
+
+// WithOnStateChange is an option for specifying a function that is called whenever the
+// breaker changes state, e.g. to alert when it opens. It is called with the breaker locked
+// and must not use it.
+func WithOnStateChange(onStateChange func(from, to State)) BreakerOption {
+	return func(b *Breaker) {
+		b.onStateChange = onStateChange
+	}
+}
+
+// WithClock is an option for specifying the clock of the breaker, for tests.
+func WithClock(now func() time.Time) BreakerOption {
+	return func(b *Breaker) {
+		b.now = now
+	}
+}
+
+func applyBreakerOptions(options ...BreakerOption) *Breaker {
+	b := &Breaker{
+		failureThreshold: 5,
+		openTimeout:      30 * time.Second,
+		now:              time.Now,
+	}
+
+	for _, option := range options {
+		option(b)
+	}
+
+	return b
+}
@@ -0,0 +1,308 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// ErrQueueFull is returned for writes that cannot be queued because MaxQueued writes are
+// already waiting for the backend to recover.
+var ErrQueueFull = errors.New("breaker: write queue full")
+
+// DroppedWriteError is passed to OnError for writes that were given up on, either because
+// History rejected them or because they failed after History may have applied them, in which
+// case sending them again could store them twice.
+type DroppedWriteError struct {
+	Messages []llms.ChatMessage
+	Err      error
+}
+
+func (e *DroppedWriteError) Error() string {
+	return fmt.Sprintf("breaker: dropped write of %d messages: %v", len(e.Messages), e.Err)
+}
+
+func (e *DroppedWriteError) Unwrap() error {
+	return e.Err
+}
+
+// ChatMessageHistory sends requests to History through Breaker. It caches the messages of
+// History in Local, and falls back to them when History fails or the breaker is open. Writes
+// that were not sent, or that History did not process, are queued and sent, in order, before
+// the next write that the breaker lets through. Local and the queue hold the messages of a
+// single identity, so a History that resolves the identity of every call from its context is
+// rejected with core.ErrResolverUnsupported; wrap one history per identity instead.
+type ChatMessageHistory struct {
+	History schema.ChatMessageHistory
+	Breaker *Breaker
+	Local   schema.ChatMessageHistory
+	// MaxQueued limits the writes waiting for the backend to recover.
+	MaxQueued int
+	// Timeout, if set, limits every request to History.
+	Timeout time.Duration
+	// OnError, if set, is called with the errors that were hidden by degrading, and with a
+	// *DroppedWriteError for every write that was given up on.
+	OnError func(ctx context.Context, err error)
+
+	// sendMu serializes the writes to History, so that queued writes are sent in order.
+	sendMu sync.Mutex
+	// mu guards queued and Local. It is never held during a request to History.
+	mu     sync.Mutex
+	queued [][]llms.ChatMessage
+}
+
+// Statically assert that ChatMessageHistory implement the chat message history interface.
+var _ schema.ChatMessageHistory = &ChatMessageHistory{}
+
+// Statically assert that ChatMessageHistory can be used as a core memory backend.
+var _ core.Backend = &ChatMessageHistory{}
+
+// NewChatMessageHistory creates a history that degrades to a local cache of history when it
+// fails.
+func NewChatMessageHistory(history schema.ChatMessageHistory, options ...ChatMessageHistoryOption) *ChatMessageHistory {
+	h := applyChatHistoryOptions(options...)
+	h.History = history
+	return h
+}
+
+type degradedKey struct{}
+
+// withDegradedFlag returns a context whose flag is set by the histories that degrade a read
+// made with it.
+func withDegradedFlag(ctx context.Context) (context.Context, *atomic.Bool) {
+	degraded := &atomic.Bool{}
+	return context.WithValue(ctx, degradedKey{}, degraded), degraded
+}
+
+func setDegraded(ctx context.Context) {
+	if degraded, ok := ctx.Value(degradedKey{}).(*atomic.Bool); ok {
+		degraded.Store(true)
+	}
+}
+
+// call sends a request through the breaker, limited by Timeout. It fails with ErrOpen if the
+// breaker does not let the request through.
+func (h *ChatMessageHistory) call(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !h.Breaker.allow() {
+		return ErrOpen
+	}
+	callCtx := ctx
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+	err := fn(callCtx)
+	h.Breaker.done(ctx, err)
+	return err
+}
+
+// checkHistory fails if History resolves the identity of every call, whose messages would
+// all end up in the same local cache and queue.
+func (h *ChatMessageHistory) checkHistory() error {
+	if core.ResolvesIdentity(h.History) {
+		return fmt.Errorf("breaker: %w", core.ErrResolverUnsupported)
+	}
+	return nil
+}
+
+func (h *ChatMessageHistory) report(ctx context.Context, err error) {
+	if h.OnError != nil {
+		h.OnError(ctx, err)
+	}
+}
+
+// flush sends the queued writes in order. A write that History did not process stays queued
+// and stops the flush. Any other failed write is dropped: a rejected one would fail again, and
+// one that History may have applied must not be sent twice. The caller must hold h.sendMu.
+func (h *ChatMessageHistory) flush(ctx context.Context) error {
+	for {
+		h.mu.Lock()
+		if len(h.queued) == 0 {
+			h.queued = nil
+			h.mu.Unlock()
+			return nil
+		}
+		messages := h.queued[0]
+		h.mu.Unlock()
+
+		err := addMessages(ctx, h.History, messages)
+		if err != nil && ctx.Err() == nil && core.ClassifyError(err) == core.Retryable {
+			return err
+		}
+		h.mu.Lock()
+		h.queued = h.queued[1:]
+		h.mu.Unlock()
+		if err != nil {
+			h.report(ctx, &DroppedWriteError{Messages: messages, Err: err})
+			if ctx.Err() != nil || transient(err) {
+				return err
+			}
+		}
+	}
+}
+
+// Queued returns the number of writes waiting for the backend to recover.
+func (h *ChatMessageHistory) Queued() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.queued)
+}
+
+// Messages returns the messages of History, once the queued writes are sent, and caches them.
+// If History fails or the breaker is open, it returns the cached messages, including the
+// queued writes, instead. A core.Memory wrapped by this package flags its memory variables as
+// degraded then.
+func (h *ChatMessageHistory) Messages(ctx context.Context) ([]llms.ChatMessage, error) {
+	if err := h.checkHistory(); err != nil {
+		return nil, err
+	}
+	var messages []llms.ChatMessage
+	err := h.call(ctx, func(ctx context.Context) error {
+		if h.Queued() > 0 {
+			h.sendMu.Lock()
+			err := h.flush(ctx)
+			h.sendMu.Unlock()
+			if err != nil {
+				return err
+			}
+		}
+		var err error
+		messages, err = h.History.Messages(ctx)
+		return err
+	})
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		if !errors.Is(err, ErrOpen) {
+			h.report(ctx, err)
+		}
+		setDegraded(ctx)
+		return h.Local.Messages(ctx)
+	}
+	if err := h.Local.SetMessages(ctx, messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// AddMessage adds a message, or queues it while History is unavailable.
+func (h *ChatMessageHistory) AddMessage(ctx context.Context, message llms.ChatMessage) error {
+	return h.AddMessages(ctx, []llms.ChatMessage{message})
+}
+
+// AddUserMessage adds a user message, or queues it while History is unavailable.
+func (h *ChatMessageHistory) AddUserMessage(ctx context.Context, text string) error {
+	return h.AddMessage(ctx, llms.HumanChatMessage{Content: text})
+}
+
+// AddAIMessage adds an AI message, or queues it while History is unavailable.
+func (h *ChatMessageHistory) AddAIMessage(ctx context.Context, text string) error {
+	return h.AddMessage(ctx, llms.AIChatMessage{Content: text})
+}
+
+// AddMessages adds messages to History, in a single request if it is a core.Backend, and to
+// the local cache. If the breaker is open, or History did not process the request, the
+// messages are queued instead, unless MaxQueued writes are queued already. If History failed
+// after it may have applied them, the messages are not queued, so that they are not stored
+// twice. If History rejected them, they are dropped and left out of the local cache too. Both
+// failures are passed to OnError.
+func (h *ChatMessageHistory) AddMessages(ctx context.Context, messages []llms.ChatMessage) error {
+	if err := h.checkHistory(); err != nil {
+		return err
+	}
+	h.sendMu.Lock()
+	defer h.sendMu.Unlock()
+
+	sent := false
+	err := h.call(ctx, func(ctx context.Context) error {
+		if err := h.flush(ctx); err != nil {
+			return err
+		}
+		sent = true
+		return addMessages(ctx, h.History, messages)
+	})
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch {
+	case err == nil:
+	case ctx.Err() != nil:
+		return err
+	case !sent || core.ClassifyError(err) == core.Retryable:
+		if !errors.Is(err, ErrOpen) {
+			h.report(ctx, err)
+		}
+		if h.MaxQueued > 0 && len(h.queued) >= h.MaxQueued {
+			return ErrQueueFull
+		}
+		h.queued = append(h.queued, messages)
+	case transient(err):
+		h.report(ctx, err)
+	default:
+		h.report(ctx, &DroppedWriteError{Messages: messages, Err: err})
+		return nil
+	}
+	return addMessages(ctx, h.Local, messages)
+}
+
+func addMessages(ctx context.Context, history schema.ChatMessageHistory, messages []llms.ChatMessage) error {
+	if backend, ok := history.(core.Backend); ok {
+		return backend.AddMessages(ctx, messages)
+	}
+	for _, message := range messages {
+		if err := history.AddMessage(ctx, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Clear clears History and the local cache, dropping the queued writes. It fails with ErrOpen
+// while the breaker is open.
+func (h *ChatMessageHistory) Clear(ctx context.Context) error {
+	return h.replace(ctx, nil, func(ctx context.Context) error {
+		return h.History.Clear(ctx)
+	})
+}
+
+// SetMessages replaces the messages of History and of the local cache, dropping the queued
+// writes. It fails with ErrOpen while the breaker is open.
+func (h *ChatMessageHistory) SetMessages(ctx context.Context, messages []llms.ChatMessage) error {
+	return h.replace(ctx, messages, func(ctx context.Context) error {
+		return h.History.SetMessages(ctx, messages)
+	})
+}
+
+// replace sends a request replacing every message, without degrading it.
+func (h *ChatMessageHistory) replace(ctx context.Context, messages []llms.ChatMessage, fn func(ctx context.Context) error) error {
+	if err := h.checkHistory(); err != nil {
+		return err
+	}
+	h.sendMu.Lock()
+	defer h.sendMu.Unlock()
+	if err := h.call(ctx, fn); err != nil {
+		if errors.Is(err, ErrOpen) || ctx.Err() != nil {
+			return err
+		}
+		return fmt.Errorf("breaker: %w", err)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.queued = nil
+	return h.Local.SetMessages(ctx, messages)
+}
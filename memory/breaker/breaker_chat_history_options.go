@@ -0,0 +1,73 @@
+package breaker
+
+import (
+	"context"
+	"time"
+
+	"github.com/tmc/langchaingo/memory"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// ChatMessageHistoryOption is a function for creating a new breaker chat message history
+// with other than the default values.
+type ChatMessageHistoryOption func(h *ChatMessageHistory)
+
+// WithChatHistoryBreaker is an option for specifying the breaker, shared with the histories
+// of the other sessions of the same backend. Defaults to a new breaker for the history.
+func WithChatHistoryBreaker(breaker *Breaker) ChatMessageHistoryOption {
+	return func(h *ChatMessageHistory) {
+		h.Breaker = breaker
+	}
+}
+
+// WithChatHistoryLocal is an option for specifying the local cache, such as a
+// tiered.FileChatMessageHistory to degrade to the history from before a restart. Defaults to
+// an in-process cache.
+func WithChatHistoryLocal(local schema.ChatMessageHistory) ChatMessageHistoryOption {
+	return func(h *ChatMessageHistory) {
+		h.Local = local
+	}
+}
+
+// WithChatHistoryMaxQueued is an option for specifying how many writes are queued while the
+// backend is unavailable. Zero or less queues every write. Defaults to 1000.
+func WithChatHistoryMaxQueued(maxQueued int) ChatMessageHistoryOption {
+	return func(h *ChatMessageHistory) {
+		h.MaxQueued = maxQueued
+	}
+}
+
+// WithChatHistoryTimeout is an option for limiting every request to the backend, so that a
+// hanging backend counts as failing. Defaults to no limit besides the context of the caller.
+func WithChatHistoryTimeout(timeout time.Duration) ChatMessageHistoryOption {
+	return func(h *ChatMessageHistory) {
+		h.Timeout = timeout
+	}
+}
+
+// WithChatHistoryOnError is an option for specifying a function that is called with the
+// backend errors that were hidden by degrading, and with a *DroppedWriteError for every write
+// that was given up on.
+func WithChatHistoryOnError(onError func(ctx context.Context, err error)) ChatMessageHistoryOption {
+	return func(h *ChatMessageHistory) {
+		h.OnError = onError
+	}
+}
+
+func applyChatHistoryOptions(options ...ChatMessageHistoryOption) *ChatMessageHistory {
+	h := &ChatMessageHistory{
+		MaxQueued: 1000,
+	}
+
+	for _, option := range options {
+		option(h)
+	}
+	if h.Breaker == nil {
+		h.Breaker = NewBreaker()
+	}
+	if h.Local == nil {
+		h.Local = memory.NewChatMessageHistory()
+	}
+
+	return h
+}
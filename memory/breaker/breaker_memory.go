@@ -0,0 +1,66 @@
+package breaker
+
+import (
+	"context"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// DefaultDegradedKey is the memory variable that flags degraded memory variables.
+const DefaultDegradedKey = "memory_degraded"
+
+// Memory is a core.Memory whose history degrades to a local cache while its backend is
+// unavailable. The memory variables loaded from the cache carry DegradedKey set to true, so
+// that the agent can tell that it may be missing context.
+type Memory struct {
+	core.Memory
+	History     *ChatMessageHistory
+	DegradedKey string
+}
+
+// Statically assert that Memory implement the memory interface.
+var _ schema.Memory = &Memory{}
+
+// NewMemory creates a memory on top of history, such as a mem0 or graphiti chat message
+// history.
+func NewMemory(history schema.ChatMessageHistory, options ...MemoryOption) *Memory {
+	m := applyBreakerMemoryOptions(options...)
+	m.History.History = history
+	m.ChatHistory = m.History
+	return m
+}
+
+// MemoryVariables returns the memory variables of the embedded core.Memory and DegradedKey,
+// which the loads may set.
+func (m *Memory) MemoryVariables(ctx context.Context) []string {
+	return append(m.Memory.MemoryVariables(ctx), m.DegradedKey)
+}
+
+// LoadMemoryVariables returns the memory variables of the embedded core.Memory, with
+// DegradedKey set to true if they were loaded from the local cache.
+func (m *Memory) LoadMemoryVariables(ctx context.Context, inputs map[string]any) (map[string]any, error) {
+	ctx, degraded := withDegradedFlag(ctx)
+	variables, err := m.Memory.LoadMemoryVariables(ctx, inputs)
+	if err != nil {
+		return nil, err
+	}
+	if degraded.Load() {
+		variables[m.DegradedKey] = true
+	}
+	return variables, nil
+}
+
+// SaveAndLoadContext is like core.Memory.SaveAndLoadContext, with DegradedKey set to true if
+// the memory variables were loaded from the local cache.
+func (m *Memory) SaveAndLoadContext(ctx context.Context, inputValues, outputValues map[string]any) (map[string]any, error) {
+	ctx, degraded := withDegradedFlag(ctx)
+	variables, err := m.Memory.SaveAndLoadContext(ctx, inputValues, outputValues)
+	if err != nil {
+		return nil, err
+	}
+	if degraded.Load() {
+		variables[m.DegradedKey] = true
+	}
+	return variables, nil
+}
@@ -0,0 +1,84 @@
+package breaker
+
+import (
+	"context"
+	"time"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// MemoryOption is a function for creating a new breaker memory
+// with other than the default values.
+type MemoryOption func(m *Memory)
+
+// WithBreaker is an option for specifying the breaker, shared with the memories of the other
+// sessions of the same backend.
+func WithBreaker(breaker *Breaker) MemoryOption {
+	return func(m *Memory) {
+		WithChatHistoryBreaker(breaker)(m.History)
+	}
+}
+
+// WithLocal is an option for specifying the local cache that loads degrade to.
+func WithLocal(local schema.ChatMessageHistory) MemoryOption {
+	return func(m *Memory) {
+		WithChatHistoryLocal(local)(m.History)
+	}
+}
+
+// WithMaxQueued is an option for specifying how many writes are queued while the backend is
+// unavailable.
+func WithMaxQueued(maxQueued int) MemoryOption {
+	return func(m *Memory) {
+		WithChatHistoryMaxQueued(maxQueued)(m.History)
+	}
+}
+
+// WithTimeout is an option for limiting every request to the backend.
+func WithTimeout(timeout time.Duration) MemoryOption {
+	return func(m *Memory) {
+		WithChatHistoryTimeout(timeout)(m.History)
+	}
+}
+
+// WithOnError is an option for specifying a function that is called with the backend errors
+// that were hidden by degrading, and with a *DroppedWriteError for every write that was given
+// up on.
+func WithOnError(onError func(ctx context.Context, err error)) MemoryOption {
+	return func(m *Memory) {
+		WithChatHistoryOnError(onError)(m.History)
+	}
+}
+
+// WithDegradedKey is an option for specifying the memory variable that flags degraded memory
+// variables. Defaults to DefaultDegradedKey.
+func WithDegradedKey(degradedKey string) MemoryOption {
+	return func(m *Memory) {
+		m.DegradedKey = degradedKey
+	}
+}
+
+// WithMemoryOptions is an option for applying core.Memory options, such as the memory key or
+// a token budget.
+func WithMemoryOptions(options ...core.Option) MemoryOption {
+	return func(m *Memory) {
+		for _, option := range options {
+			option(&m.Memory)
+		}
+	}
+}
+
+func applyBreakerMemoryOptions(options ...MemoryOption) *Memory {
+	m := &Memory{
+		Memory:      *core.ApplyOptions(),
+		History:     applyChatHistoryOptions(),
+		DegradedKey: DefaultDegradedKey,
+	}
+
+	for _, option := range options {
+		option(m)
+	}
+
+	return m
+}
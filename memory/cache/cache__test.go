@@ -0,0 +1,341 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/0xDezzy/langchaingo-memory/memory/mem0"
+	"github.com/0xDezzy/langchaingo-memory/memory/mem0/mem0test"
+	"github.com/0xDezzy/langchaingo-memory/memory/memorytest"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+const memoriesPath = "/v1/memories/"
+
+func newRemote(t *testing.T) (*mem0test.Server, *mem0.ChatMessageHistory) {
+	t.Helper()
+
+	server := mem0test.NewServer()
+	t.Cleanup(server.Close)
+	client, err := server.NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return server, mem0.NewMem0ChatMessageHistory(client, "sarah")
+}
+
+func messages(t *testing.T, h schema.ChatMessageHistory) string {
+	t.Helper()
+
+	loaded, err := h.Messages(context.Background())
+	if err != nil {
+		t.Fatalf("Messages: %v", err)
+	}
+	var c []string
+	for _, message := range loaded {
+		if message.GetType() != llms.ChatMessageTypeSystem {
+			c = append(c, message.GetContent())
+		}
+	}
+	return strings.Join(c, "|")
+}
+
+func TestChatMessageHistory(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("ReadThrough", func(t *testing.T) {
+		t.Parallel()
+		server, remote := newRemote(t)
+		h := NewChatMessageHistory(remote, Key{UserID: "sarah"})
+		if err := h.AddUserMessage(ctx, "hello"); err != nil {
+			t.Fatalf("AddUserMessage: %v", err)
+		}
+
+		for i := 0; i < 3; i++ {
+			if got := messages(t, h); got != "hello" {
+				t.Errorf("Expected the added message, got %q", got)
+			}
+		}
+		if n := server.RequestCount(http.MethodGet, memoriesPath); n != 1 {
+			t.Errorf("Expected a single load from mem0, got %d", n)
+		}
+
+		if err := h.AddAIMessage(ctx, "hi"); err != nil {
+			t.Fatalf("AddAIMessage: %v", err)
+		}
+		if got := messages(t, h); got != "hello|hi" {
+			t.Errorf("Expected the write to invalidate the cache, got %q", got)
+		}
+		if n := server.RequestCount(http.MethodGet, memoriesPath); n != 2 {
+			t.Errorf("Expected a second load after the write, got %d", n)
+		}
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+		t.Parallel()
+		server, remote := newRemote(t)
+		store := NewLRUStore(0)
+		now := time.Now()
+		store.now = func() time.Time { return now }
+		h := NewChatMessageHistory(remote, Key{UserID: "sarah"}, WithChatHistoryStore(store), WithChatHistoryTTL(time.Minute))
+
+		messages(t, h)
+		messages(t, h)
+		now = now.Add(2 * time.Minute)
+		messages(t, h)
+		if n := server.RequestCount(http.MethodGet, memoriesPath); n != 2 {
+			t.Errorf("Expected a load after the entry expired, got %d loads", n)
+		}
+	})
+
+	t.Run("Facts", func(t *testing.T) {
+		t.Parallel()
+		server, remote := newRemote(t)
+		store := NewLRUStore(0)
+		h := NewChatMessageHistory(remote, Key{UserID: "sarah", Scope: "chat"}, WithChatHistoryStore(store))
+		if err := h.AddUserMessage(ctx, "I live in Berlin"); err != nil {
+			t.Fatalf("AddUserMessage: %v", err)
+		}
+
+		messages(t, h)
+		for i := 0; i < 2; i++ {
+			facts, err := h.Facts(ctx)
+			if err != nil {
+				t.Fatalf("Facts: %v", err)
+			}
+			if len(facts) != 1 || facts[0] != "I live in Berlin" {
+				t.Errorf("Expected the extracted memory, got %v", facts)
+			}
+		}
+		if n := server.RequestCount(http.MethodGet, memoriesPath); n != 2 || store.Len() != 2 {
+			t.Errorf("Expected the messages and the facts to be cached apart, got %d loads and %d entries", n, store.Len())
+		}
+		if err := h.Clear(ctx); err != nil {
+			t.Fatalf("Clear: %v", err)
+		}
+		if store.Len() != 0 {
+			t.Errorf("Expected Clear to invalidate every scope of the user, got %d entries", store.Len())
+		}
+	})
+}
+
+// blockingHistory blocks loads until release is closed, telling loading when they start.
+type blockingHistory struct {
+	schema.ChatMessageHistory
+	loading chan struct{}
+	release chan struct{}
+}
+
+func (h *blockingHistory) Messages(ctx context.Context) ([]llms.ChatMessage, error) {
+	h.loading <- struct{}{}
+	<-h.release
+	return h.ChatMessageHistory.Messages(ctx)
+}
+
+func TestSharedStore(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("WriteThroughOtherInstance", func(t *testing.T) {
+		t.Parallel()
+		server, remote := newRemote(t)
+		store := NewLRUStore(0)
+		slow := &blockingHistory{ChatMessageHistory: remote, loading: make(chan struct{}), release: make(chan struct{})}
+		a := NewChatMessageHistory(slow, Key{UserID: "sarah"}, WithChatHistoryStore(store))
+		b := NewChatMessageHistory(remote, Key{UserID: "sarah"}, WithChatHistoryStore(store))
+
+		loaded := make(chan error, 1)
+		go func() {
+			_, err := a.Messages(ctx)
+			loaded <- err
+		}()
+		<-slow.loading
+		if err := b.AddUserMessage(ctx, "hello"); err != nil {
+			t.Fatalf("AddUserMessage: %v", err)
+		}
+		close(slow.release)
+		if err := <-loaded; err != nil {
+			t.Fatalf("Messages: %v", err)
+		}
+		if got := messages(t, b); got != "hello" {
+			t.Errorf("Expected the load that raced with the write not to be cached, got %q", got)
+		}
+		if n := server.RequestCount(http.MethodGet, memoriesPath); n != 2 {
+			t.Errorf("Expected a second load after the write, got %d", n)
+		}
+	})
+
+	t.Run("WritesOfOtherUsers", func(t *testing.T) {
+		t.Parallel()
+		server := mem0test.NewServer()
+		t.Cleanup(server.Close)
+		remote := mem0.NewClientChatMessageHistory(server.ClientOptions(), "", mem0.WithChatHistoryResolver(core.ResolveFromContext))
+		slow := &blockingHistory{ChatMessageHistory: remote, loading: make(chan struct{}, 1), release: make(chan struct{})}
+		h := NewChatMessageHistory(slow, Key{}, WithChatHistoryResolver(core.ResolveFromContext))
+		sarah := core.ContextWithUserID(ctx, "sarah")
+		john := core.ContextWithUserID(ctx, "john")
+
+		loaded := make(chan error, 1)
+		go func() {
+			_, err := h.Messages(sarah)
+			loaded <- err
+		}()
+		<-slow.loading
+		if err := h.AddUserMessage(john, "hello"); err != nil {
+			t.Fatalf("AddUserMessage: %v", err)
+		}
+		close(slow.release)
+		if err := <-loaded; err != nil {
+			t.Fatalf("Messages: %v", err)
+		}
+		if _, ok, _ := h.Store.Get(ctx, Key{UserID: "sarah"}); !ok {
+			t.Errorf("Expected the load of sarah to be cached despite the write of john")
+		}
+	})
+
+	t.Run("NoIdentity", func(t *testing.T) {
+		t.Parallel()
+		_, remote := newRemote(t)
+		store := NewLRUStore(0)
+		_ = store.Set(ctx, Key{UserID: "john"}, Entry{}, 0)
+		h := NewChatMessageHistory(remote, Key{Scope: "chat"}, WithChatHistoryStore(store))
+		if err := h.AddUserMessage(ctx, "hello"); !errors.Is(err, core.ErrNoIdentity) {
+			t.Errorf("Expected ErrNoIdentity for a key without user or session, got %v", err)
+		}
+		if store.Len() != 1 {
+			t.Errorf("Expected the entries of other users to be kept, got %d entries", store.Len())
+		}
+	})
+}
+
+func TestResolver(t *testing.T) {
+	t.Parallel()
+
+	server := mem0test.NewServer()
+	t.Cleanup(server.Close)
+	remote := mem0.NewClientChatMessageHistory(server.ClientOptions(), "", mem0.WithChatHistoryResolver(core.ResolveFromContext))
+	ctx := core.ContextWithUserID(context.Background(), "sarah")
+	if _, err := NewChatMessageHistory(remote, Key{}).Messages(ctx); !errors.Is(err, core.ErrResolverRequired) {
+		t.Errorf("Expected ErrResolverRequired without a resolver, got %v", err)
+	}
+
+	memorytest.RunUserIsolationSuite(t, func(t *testing.T) schema.ChatMessageHistory {
+		server := mem0test.NewServer()
+		t.Cleanup(server.Close)
+		remote := mem0.NewClientChatMessageHistory(server.ClientOptions(), "", mem0.WithChatHistoryResolver(core.ResolveFromContext))
+		return NewChatMessageHistory(remote, Key{}, WithChatHistoryResolver(core.ResolveFromContext))
+	})
+}
+
+func TestLRUStore(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	s := NewLRUStore(2)
+	a, b, c := Key{UserID: "a"}, Key{UserID: "b"}, Key{UserID: "c", SessionID: "1"}
+	_ = s.Set(ctx, a, Entry{Facts: []string{"a"}}, 0)
+	_ = s.Set(ctx, b, Entry{Facts: []string{"b"}}, 0)
+	if _, ok, _ := s.Get(ctx, a); !ok {
+		t.Fatalf("Expected a to be cached")
+	}
+	_ = s.Set(ctx, c, Entry{Facts: []string{"c"}}, 0)
+	if _, ok, _ := s.Get(ctx, b); ok {
+		t.Errorf("Expected the least recently used entry to be evicted")
+	}
+
+	entry, _, _ := s.Get(ctx, a)
+	entry.Facts[0] = "changed"
+	if entry, _, _ := s.Get(ctx, a); entry.Facts[0] != "a" {
+		t.Errorf("Expected entries to be copied, got %v", entry.Facts)
+	}
+
+	_ = s.Delete(ctx, Key{UserID: "c"})
+	if _, ok, _ := s.Get(ctx, c); ok || s.Len() != 1 {
+		t.Errorf("Expected the pattern to delete every session of the user, got %d entries", s.Len())
+	}
+
+	if generation, _ := s.Generation(ctx, c); generation == 0 {
+		t.Errorf("Expected the deletion to advance the generation of the session")
+	}
+	_ = s.Set(ctx, c, Entry{}, 0)
+	if _, ok, _ := s.Get(ctx, c); ok {
+		t.Errorf("Expected an entry loaded before the deletion to be dropped")
+	}
+	if generation, _ := s.Generation(ctx, b); generation != 0 {
+		t.Errorf("Expected the generation of other users to stay, got %d", generation)
+	}
+}
+
+func TestWebhookHandler(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	store := NewLRUStore(0)
+	_ = store.Set(ctx, Key{UserID: "sarah", Scope: "chat"}, Entry{}, 0)
+	_ = store.Set(ctx, Key{UserID: "bob"}, Entry{}, 0)
+	handler := NewWebhookHandler(store, nil)
+
+	for body, expected := range map[string]int{
+		`{"event":"ADD","data":{"user_id":"sarah"}}`: http.StatusNoContent,
+		`{"event":"ADD"}`: http.StatusBadRequest,
+		`not json`:        http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		if rec.Code != expected {
+			t.Errorf("Expected %d for %s, got %d", expected, body, rec.Code)
+		}
+	}
+	if _, ok, _ := store.Get(ctx, Key{UserID: "sarah", Scope: "chat"}); ok || store.Len() != 1 {
+		t.Errorf("Expected only the entries of sarah to be invalidated, got %d entries", store.Len())
+	}
+}
+
+func TestMemory(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	server, remote := newRemote(t)
+	store := NewLRUStore(0)
+	m := NewMemory(remote, Key{UserID: "sarah"}, WithStore(store), WithTTL(time.Hour),
+		WithMemoryOptions(core.WithMemoryKey("chat")))
+
+	if err := m.SaveContext(ctx, map[string]any{"input": "hello"}, map[string]any{"output": "hi"}); err != nil {
+		t.Fatalf("SaveContext: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		variables, err := m.LoadMemoryVariables(ctx, nil)
+		if err != nil {
+			t.Fatalf("LoadMemoryVariables: %v", err)
+		}
+		if _, ok := variables["chat"]; !ok {
+			t.Errorf("Expected the memory key of the options, got %v", variables)
+		}
+	}
+	if n := server.RequestCount(http.MethodGet, memoriesPath); n != 1 {
+		t.Errorf("Expected a single load from mem0, got %d", n)
+	}
+	if m.History.TTL != time.Hour || store.Len() == 0 {
+		t.Errorf("Expected the loads cached in the store of the options for its TTL, got %v and %d entries", m.History.TTL, store.Len())
+	}
+}
+
+func TestConformance(t *testing.T) {
+	t.Parallel()
+
+	memorytest.RunChatMessageHistorySuite(t, func(t *testing.T) schema.ChatMessageHistory {
+		_, remote := newRemote(t)
+		return NewChatMessageHistory(remote, Key{UserID: "sarah"})
+	}, memorytest.WithoutSetMessages())
+	memorytest.RunMemorySuite(t, func(t *testing.T) schema.Memory {
+		_, remote := newRemote(t)
+		return NewMemory(remote, Key{UserID: "sarah"})
+	})
+}
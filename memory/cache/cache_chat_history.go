@@ -0,0 +1,223 @@
+// Package cache adds a read-through cache to a chat message history, so that an agent loading
+// its memory several times per turn only waits for mem0 or Zep once. Entries expire after a
+// TTL and are invalidated by the writes of the history, and can be invalidated by backend
+// events through a webhook handler.
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// factsScope is appended to the scope of the key of a history to cache its facts.
+const factsScope = "#facts"
+
+// ChatMessageHistory caches the loads of History in Store under Key for TTL. The writes of the
+// history invalidate every entry of the user and session of Key, so that a history always
+// reads its own writes; writes made elsewhere are seen once the entries expire or are
+// invalidated through Store.
+type ChatMessageHistory struct {
+	History schema.ChatMessageHistory
+	Store   Store
+	Key     Key
+	TTL     time.Duration
+	// Resolver, if set, resolves the user and session of the key of every call from its
+	// context, for a History that serves every user with a resolver of its own. The user and
+	// session of Key are used when it resolves none.
+	Resolver core.Resolver
+}
+
+// Statically assert that ChatMessageHistory implement the chat message history interface.
+var _ schema.ChatMessageHistory = &ChatMessageHistory{}
+
+// Statically assert that ChatMessageHistory can be used as a core memory backend.
+var _ core.Backend = &ChatMessageHistory{}
+
+// Statically assert that ChatMessageHistory can provide facts.
+var _ core.FactSource = &ChatMessageHistory{}
+
+// Statically assert that ChatMessageHistory can tell wrappers whether it resolves identities.
+var _ core.IdentityResolver = &ChatMessageHistory{}
+
+// NewChatMessageHistory creates a history caching the loads of history under key, such as
+// Key{UserID: userID} for a mem0 history or Key{SessionID: sessionID} for a graphiti history.
+func NewChatMessageHistory(history schema.ChatMessageHistory, key Key, options ...ChatMessageHistoryOption) *ChatMessageHistory {
+	h := applyChatHistoryOptions(options...)
+	h.History = history
+	h.Key = key
+	return h
+}
+
+// ResolvesIdentity reports whether the history has a Resolver.
+func (h *ChatMessageHistory) ResolvesIdentity() bool {
+	return h.Resolver != nil
+}
+
+// key returns the key of a call: Key, with the user and session resolved from ctx if the
+// history has a Resolver. A key without a user or a session would invalidate every entry of
+// the store, and fails with core.ErrNoIdentity.
+func (h *ChatMessageHistory) key(ctx context.Context) (Key, error) {
+	if h.Resolver == nil {
+		if core.ResolvesIdentity(h.History) {
+			return Key{}, fmt.Errorf("cache: %w", core.ErrResolverRequired)
+		}
+		if h.Key.UserID == "" && h.Key.SessionID == "" {
+			return Key{}, fmt.Errorf("cache: %w", core.ErrNoIdentity)
+		}
+		return h.Key, nil
+	}
+	userID, sessionID, err := core.Resolve(ctx, h.Resolver, h.Key.UserID, h.Key.SessionID)
+	if err != nil {
+		return Key{}, fmt.Errorf("cache: resolving key: %w", err)
+	}
+	if userID == "" && sessionID == "" {
+		return Key{}, fmt.Errorf("cache: %w", core.ErrNoIdentity)
+	}
+	return Key{UserID: userID, SessionID: sessionID, Scope: h.Key.Scope}, nil
+}
+
+// Messages returns the cached messages, or loads and caches them on a miss. Errors of Store
+// are ignored in favor of History.
+func (h *ChatMessageHistory) Messages(ctx context.Context) ([]llms.ChatMessage, error) {
+	key, err := h.key(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := h.load(ctx, key, func(ctx context.Context) (Entry, error) {
+		messages, err := h.History.Messages(ctx)
+		return Entry{Messages: messages}, err
+	})
+	return entry.Messages, err
+}
+
+// Facts returns the cached facts, or loads and caches them on a miss. If History is not a
+// core.FactSource, the facts are the lines of the system messages of its messages.
+func (h *ChatMessageHistory) Facts(ctx context.Context) ([]string, error) {
+	key, err := h.key(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key.Scope += factsScope
+	entry, err := h.load(ctx, key, func(ctx context.Context) (Entry, error) {
+		if source, ok := h.History.(core.FactSource); ok {
+			facts, err := source.Facts(ctx)
+			return Entry{Facts: facts}, err
+		}
+		messages, err := h.History.Messages(ctx)
+		return Entry{Facts: systemLines(messages)}, err
+	})
+	return entry.Facts, err
+}
+
+func (h *ChatMessageHistory) load(ctx context.Context, key Key, fetch func(ctx context.Context) (Entry, error)) (Entry, error) {
+	if err := ctx.Err(); err != nil {
+		return Entry{}, err
+	}
+	if entry, ok, err := h.Store.Get(ctx, key); err == nil && ok {
+		return entry, nil
+	}
+
+	// A load that raced with a write is not cached.
+	generation, generationErr := h.Store.Generation(ctx, key)
+	entry, err := fetch(ctx)
+	if err != nil {
+		return Entry{}, err
+	}
+	if generationErr == nil {
+		if h.TTL > 0 {
+			entry.Expires = time.Now().Add(h.TTL)
+		}
+		_ = h.Store.Set(ctx, key, entry, generation)
+	}
+	return entry, nil
+}
+
+// invalidate removes the entries of the user and session of key after a write, even a failed
+// one, which may have been applied. A key without either would remove every entry of the
+// store, and is rejected instead.
+func (h *ChatMessageHistory) invalidate(ctx context.Context, key Key) error {
+	pattern := Key{UserID: key.UserID, SessionID: key.SessionID}
+	if pattern == (Key{}) {
+		return fmt.Errorf("cache: invalidating without a user or session: %w", core.ErrNoIdentity)
+	}
+	return h.Store.Delete(context.WithoutCancel(ctx), pattern)
+}
+
+func systemLines(messages []llms.ChatMessage) []string {
+	var lines []string
+	for _, message := range messages {
+		if message.GetType() != llms.ChatMessageTypeSystem {
+			continue
+		}
+		for _, line := range strings.Split(message.GetContent(), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				lines = append(lines, line)
+			}
+		}
+	}
+	return lines
+}
+
+// AddMessage adds a message to History and invalidates the cache.
+func (h *ChatMessageHistory) AddMessage(ctx context.Context, message llms.ChatMessage) error {
+	return h.AddMessages(ctx, []llms.ChatMessage{message})
+}
+
+// AddUserMessage adds a user message to History and invalidates the cache.
+func (h *ChatMessageHistory) AddUserMessage(ctx context.Context, text string) error {
+	return h.AddMessage(ctx, llms.HumanChatMessage{Content: text})
+}
+
+// AddAIMessage adds an AI message to History and invalidates the cache.
+func (h *ChatMessageHistory) AddAIMessage(ctx context.Context, text string) error {
+	return h.AddMessage(ctx, llms.AIChatMessage{Content: text})
+}
+
+// AddMessages adds messages to History, in a single request if it is a core.Backend, and
+// invalidates the cache.
+func (h *ChatMessageHistory) AddMessages(ctx context.Context, messages []llms.ChatMessage) error {
+	return h.write(ctx, func(ctx context.Context) error {
+		if backend, ok := h.History.(core.Backend); ok {
+			return backend.AddMessages(ctx, messages)
+		}
+		for _, message := range messages {
+			if err := h.History.AddMessage(ctx, message); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Clear clears History and invalidates the cache.
+func (h *ChatMessageHistory) Clear(ctx context.Context) error {
+	return h.write(ctx, h.History.Clear)
+}
+
+// SetMessages replaces the messages of History and invalidates the cache.
+func (h *ChatMessageHistory) SetMessages(ctx context.Context, messages []llms.ChatMessage) error {
+	return h.write(ctx, func(ctx context.Context) error {
+		return h.History.SetMessages(ctx, messages)
+	})
+}
+
+func (h *ChatMessageHistory) write(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	key, err := h.key(ctx)
+	if err != nil {
+		return err
+	}
+	err = fn(ctx)
+	if invalidateErr := h.invalidate(ctx, key); err == nil {
+		err = invalidateErr
+	}
+	return err
+}
@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+)
+
+// ChatMessageHistoryOption is a function for creating a new cached chat message history
+// with other than the default values.
+type ChatMessageHistoryOption func(h *ChatMessageHistory)
+
+// WithChatHistoryStore is an option for specifying the store of the cache, such as an
+// LRUStore shared by the histories of every session. Defaults to an LRUStore of the history.
+func WithChatHistoryStore(store Store) ChatMessageHistoryOption {
+	return func(h *ChatMessageHistory) {
+		h.Store = store
+	}
+}
+
+// WithChatHistoryTTL is an option for specifying how long loads are cached, which bounds how
+// stale the writes made elsewhere can be. Zero or less caches until invalidated. Defaults to
+// 30s.
+func WithChatHistoryTTL(ttl time.Duration) ChatMessageHistoryOption {
+	return func(h *ChatMessageHistory) {
+		h.TTL = ttl
+	}
+}
+
+// WithChatHistoryResolver is an option for resolving the user and session of the key of every
+// call from its context, such as with core.ResolveFromContext, for a history that serves every
+// user with a resolver of its own.
+func WithChatHistoryResolver(resolver core.Resolver) ChatMessageHistoryOption {
+	return func(h *ChatMessageHistory) {
+		h.Resolver = resolver
+	}
+}
+
+func applyChatHistoryOptions(options ...ChatMessageHistoryOption) *ChatMessageHistory {
+	h := &ChatMessageHistory{
+		TTL: 30 * time.Second,
+	}
+
+	for _, option := range options {
+		option(h)
+	}
+	if h.Store == nil {
+		h.Store = NewLRUStore(0)
+	}
+
+	return h
+}
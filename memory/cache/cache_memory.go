@@ -0,0 +1,26 @@
+package cache
+
+import (
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// Memory is a core.Memory whose loads are cached. The schema.Memory implementation comes from
+// the embedded core.Memory.
+type Memory struct {
+	core.Memory
+	History *ChatMessageHistory
+}
+
+// Statically assert that Memory implement the memory interface.
+var _ schema.Memory = &Memory{}
+
+// NewMemory creates a memory whose loads are cached under key, in an LRUStore of its own
+// unless WithStore shares one with the memories of other sessions.
+func NewMemory(history schema.ChatMessageHistory, key Key, options ...MemoryOption) *Memory {
+	m := applyCacheMemoryOptions(options...)
+	m.History.History = history
+	m.History.Key = key
+	m.ChatHistory = m.History
+	return m
+}
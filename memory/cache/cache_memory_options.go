@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+)
+
+// MemoryOption is a function for creating a new cached memory
+// with other than the default values.
+type MemoryOption func(m *Memory)
+
+// WithStore is an option for specifying the store of the cache, such as an LRUStore shared by
+// the memories of every session.
+func WithStore(store Store) MemoryOption {
+	return func(m *Memory) {
+		WithChatHistoryStore(store)(m.History)
+	}
+}
+
+// WithTTL is an option for specifying how long loads are cached. Zero or less caches until
+// invalidated. Defaults to 30s.
+func WithTTL(ttl time.Duration) MemoryOption {
+	return func(m *Memory) {
+		WithChatHistoryTTL(ttl)(m.History)
+	}
+}
+
+// WithResolver is an option for resolving the user and session of the key of every call from
+// its context, for a history that serves every user with a resolver of its own.
+func WithResolver(resolver core.Resolver) MemoryOption {
+	return func(m *Memory) {
+		WithChatHistoryResolver(resolver)(m.History)
+	}
+}
+
+// WithMemoryOptions is an option for applying core.Memory options, such as the memory key or
+// a token budget.
+func WithMemoryOptions(options ...core.Option) MemoryOption {
+	return func(m *Memory) {
+		for _, option := range options {
+			option(&m.Memory)
+		}
+	}
+}
+
+func applyCacheMemoryOptions(options ...MemoryOption) *Memory {
+	m := &Memory{
+		Memory:  *core.ApplyOptions(),
+		History: applyChatHistoryOptions(),
+	}
+
+	for _, option := range options {
+		option(m)
+	}
+
+	return m
+}
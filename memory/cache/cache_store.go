@@ -0,0 +1,211 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// Key identifies a cached load. Scope tells apart loads of the same session that return
+// different results, such as different memory types or the facts of a history.
+type Key struct {
+	UserID    string
+	SessionID string
+	Scope     string
+}
+
+// matches reports whether key matches pattern, whose empty fields match any value.
+func (key Key) matches(pattern Key) bool {
+	return (pattern.UserID == "" || pattern.UserID == key.UserID) &&
+		(pattern.SessionID == "" || pattern.SessionID == key.SessionID) &&
+		(pattern.Scope == "" || pattern.Scope == key.Scope)
+}
+
+// patterns returns every pattern that key matches, but the zero Key.
+func (key Key) patterns() []Key {
+	patterns := make([]Key, 0, 7)
+	for _, userID := range []string{key.UserID, ""} {
+		for _, sessionID := range []string{key.SessionID, ""} {
+			for _, scope := range []string{key.Scope, ""} {
+				pattern := Key{UserID: userID, SessionID: sessionID, Scope: scope}
+				if pattern != (Key{}) && !slices.Contains(patterns, pattern) {
+					patterns = append(patterns, pattern)
+				}
+			}
+		}
+	}
+	return patterns
+}
+
+// Entry is a cached load.
+type Entry struct {
+	Messages []llms.ChatMessage
+	Facts    []string
+	// Expires is when the entry stops being returned. The zero time never expires.
+	Expires time.Time
+}
+
+// Store holds cached loads. Get reports false for missing and expired entries. Delete removes
+// every entry matching pattern, whose empty fields match any value, so that
+// Key{UserID: "sarah"} removes every entry of the user, and advances the generation of every
+// key matching it, including keys without an entry. A load takes the generation of its key
+// before it fetches, and Set drops its entry if the generation advanced in the meantime, as
+// the entry may predate a write that invalidated the key through any history sharing the
+// store.
+type Store interface {
+	Get(ctx context.Context, key Key) (Entry, bool, error)
+	Generation(ctx context.Context, key Key) (uint64, error)
+	Set(ctx context.Context, key Key, entry Entry, generation uint64) error
+	Delete(ctx context.Context, pattern Key) error
+}
+
+// DefaultMaxEntries is the number of entries an LRUStore keeps by default.
+const DefaultMaxEntries = 1024
+
+// LRUStore keeps entries in process, evicting the least recently used entry when it is full.
+type LRUStore struct {
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[Key]*list.Element
+	// deletions counts the calls of Delete, and deleted holds the count at the last deletion
+	// of every pattern. Once it holds maxEntries patterns, it is cleared and floor, the
+	// generation of every key, is raised to deletions instead.
+	deletions uint64
+	deleted   map[Key]uint64
+	floor     uint64
+}
+
+type lruItem struct {
+	key   Key
+	entry Entry
+}
+
+// Statically assert that LRUStore implement the store interface.
+var _ Store = &LRUStore{}
+
+// NewLRUStore returns an empty store of at most maxEntries entries. Zero or less uses
+// DefaultMaxEntries.
+func NewLRUStore(maxEntries int) *LRUStore {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	return &LRUStore{
+		maxEntries: maxEntries,
+		now:        time.Now,
+		order:      list.New(),
+		entries:    make(map[Key]*list.Element),
+		deleted:    make(map[Key]uint64),
+	}
+}
+
+// Get returns the entry of key and marks it as recently used.
+func (s *LRUStore) Get(ctx context.Context, key Key) (Entry, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return Entry{}, false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	element, ok := s.entries[key]
+	if !ok {
+		return Entry{}, false, nil
+	}
+	item := element.Value.(*lruItem)
+	if !item.entry.Expires.IsZero() && !s.now().Before(item.entry.Expires) {
+		s.remove(element)
+		return Entry{}, false, nil
+	}
+	s.order.MoveToFront(element)
+	return copyEntry(item.entry), true, nil
+}
+
+// Generation returns the generation of key.
+func (s *LRUStore) Generation(ctx context.Context, key Key) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.generation(key), nil
+}
+
+// generation returns the count of deletions at the last deletion matching key. The caller
+// must hold s.mu.
+func (s *LRUStore) generation(key Key) uint64 {
+	generation := s.floor
+	for _, pattern := range key.patterns() {
+		generation = max(generation, s.deleted[pattern])
+	}
+	return generation
+}
+
+// Set replaces the entry of key unless the generation of key is no longer generation,
+// evicting the least recently used entry if the store is full.
+func (s *LRUStore) Set(ctx context.Context, key Key, entry Entry, generation uint64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.generation(key) != generation {
+		return nil
+	}
+	entry = copyEntry(entry)
+	if element, ok := s.entries[key]; ok {
+		element.Value.(*lruItem).entry = entry
+		s.order.MoveToFront(element)
+		return nil
+	}
+	s.entries[key] = s.order.PushFront(&lruItem{key: key, entry: entry})
+	for s.order.Len() > s.maxEntries {
+		s.remove(s.order.Back())
+	}
+	return nil
+}
+
+// Delete removes the entries matching pattern.
+func (s *LRUStore) Delete(ctx context.Context, pattern Key) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, element := range s.entries {
+		if key.matches(pattern) {
+			s.remove(element)
+		}
+	}
+	s.deletions++
+	if len(s.deleted) >= s.maxEntries {
+		clear(s.deleted)
+		s.floor = s.deletions
+	}
+	s.deleted[pattern] = s.deletions
+	return nil
+}
+
+// Len returns the number of entries, including expired entries that were not evicted yet.
+func (s *LRUStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+// remove removes an entry. The caller must hold s.mu.
+func (s *LRUStore) remove(element *list.Element) {
+	s.order.Remove(element)
+	delete(s.entries, element.Value.(*lruItem).key)
+}
+
+// copyEntry copies the slices of an entry, so that callers cannot change cached entries.
+func copyEntry(entry Entry) Entry {
+	entry.Messages = append([]llms.ChatMessage(nil), entry.Messages...)
+	entry.Facts = append([]string(nil), entry.Facts...)
+	return entry
+}
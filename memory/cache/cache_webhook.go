@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// maxEventSize limits the body of the events read by DefaultKeys.
+const maxEventSize = 1 << 20
+
+// KeysFunc returns the key patterns of the entries an event invalidates.
+type KeysFunc func(r *http.Request) ([]Key, error)
+
+// NewWebhookHandler returns a handler that invalidates the entries of store named by the
+// events posted to it, such as the webhooks of mem0 or Zep or the events of a message bus,
+// so that writes made by other processes are seen before the entries expire. keys reads the
+// events; nil uses DefaultKeys. The handler does not authenticate requests, so wrap it in the
+// authentication of the webhook.
+func NewWebhookHandler(store Store, keys KeysFunc) http.Handler {
+	if keys == nil {
+		keys = DefaultKeys
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		patterns, err := keys(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, pattern := range patterns {
+			if pattern == (Key{}) {
+				continue
+			}
+			if err := store.Delete(r.Context(), pattern); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// event is the part of a webhook event read by DefaultKeys.
+type event struct {
+	UserID    string `json:"user_id"`
+	SessionID string `json:"session_id"`
+	Data      *event `json:"data"`
+}
+
+// DefaultKeys reads a JSON event with a user_id and/or a session_id, at the top level or in
+// its data object, and invalidates the entries of that user and session.
+func DefaultKeys(r *http.Request) ([]Key, error) {
+	var e event
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxEventSize)).Decode(&e); err != nil {
+		return nil, err
+	}
+	var keys []Key
+	for current := &e; current != nil; current = current.Data {
+		if current.UserID != "" || current.SessionID != "" {
+			keys = append(keys, Key{UserID: current.UserID, SessionID: current.SessionID})
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("cache: event names no user_id or session_id")
+	}
+	return keys, nil
+}
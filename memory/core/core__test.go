@@ -0,0 +1,361 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// mockChatHistory implements schema.ChatMessageHistory for testing
+type mockChatHistory struct {
+	messages         []llms.ChatMessage
+	messagesErr      error
+	userMessageAdded string
+	aiMessageAdded   string
+	addUserErr       error
+	addAIErr         error
+	cleared          bool
+}
+
+func (m *mockChatHistory) Messages(_ context.Context) ([]llms.ChatMessage, error) {
+	if m.messagesErr != nil {
+		return nil, m.messagesErr
+	}
+	return m.messages, nil
+}
+
+func (m *mockChatHistory) AddUserMessage(_ context.Context, text string) error {
+	m.userMessageAdded = text
+	return m.addUserErr
+}
+
+func (m *mockChatHistory) AddAIMessage(_ context.Context, text string) error {
+	m.aiMessageAdded = text
+	return m.addAIErr
+}
+
+func (m *mockChatHistory) AddMessage(_ context.Context, _ llms.ChatMessage) error {
+	return nil
+}
+
+func (m *mockChatHistory) SetMessages(_ context.Context, _ []llms.ChatMessage) error {
+	return nil
+}
+
+func (m *mockChatHistory) Clear(_ context.Context) error {
+	m.cleared = true
+	return nil
+}
+
+// TestSaveAndLoadContext tests the combined save and load
+func TestSaveAndLoadContext(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("AppendsSavedTurn", func(t *testing.T) {
+		m := ApplyOptions()
+		mockHistory := &mockChatHistory{
+			messages: []llms.ChatMessage{
+				llms.HumanChatMessage{Content: "Hello"},
+				llms.AIChatMessage{Content: "Hi there"},
+			},
+		}
+		m.ChatHistory = mockHistory
+
+		result, err := m.SaveAndLoadContext(ctx,
+			map[string]any{"input": "How are you?"},
+			map[string]any{"output": "Fine"},
+		)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if mockHistory.userMessageAdded != "How are you?" || mockHistory.aiMessageAdded != "Fine" {
+			t.Errorf("Expected turn to be saved, got %q and %q", mockHistory.userMessageAdded, mockHistory.aiMessageAdded)
+		}
+		messages, ok := result["history"].([]llms.ChatMessage)
+		if !ok {
+			t.Fatalf("Expected result to contain messages slice")
+		}
+		if len(messages) != 4 || messages[3].GetContent() != "Fine" {
+			t.Errorf("Expected saved turn to be appended, got %v", messages)
+		}
+	})
+
+	t.Run("ObservedTurn", func(t *testing.T) {
+		m := ApplyOptions(WithReturnMessages(false))
+		// The load ran late enough to observe the saved user message.
+		m.ChatHistory = &mockChatHistory{
+			messages: []llms.ChatMessage{
+				llms.HumanChatMessage{Content: "Hello"},
+				llms.AIChatMessage{Content: "Hi there"},
+				llms.HumanChatMessage{Content: "How are you?"},
+			},
+		}
+
+		result, err := m.SaveAndLoadContext(ctx,
+			map[string]any{"input": "How are you?"},
+			map[string]any{"output": "Fine"},
+		)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if expected := "Human: Hello\nAI: Hi there\nHuman: How are you?\nAI: Fine"; result["history"] != expected {
+			t.Errorf("Expected only the unobserved part of the turn to be appended, got %q", result["history"])
+		}
+	})
+
+	t.Run("LoadsWhileSaving", func(t *testing.T) {
+		m := ApplyOptions()
+		m.ChatHistory = &overlapHistory{loading: make(chan struct{}), saving: make(chan struct{})}
+
+		result, err := m.SaveAndLoadContext(ctx,
+			map[string]any{"input": "Hello"},
+			map[string]any{"output": "Hi"},
+		)
+		if err != nil {
+			t.Fatalf("Expected the load and the save to overlap, got %v", err)
+		}
+		if messages := result["history"].([]llms.ChatMessage); len(messages) != 2 {
+			t.Errorf("Expected the saved turn, got %v", messages)
+		}
+	})
+
+	t.Run("FactsFromAdd", func(t *testing.T) {
+		m := ApplyOptions()
+		m.ChatHistory = &factHistory{
+			batchChatHistory: batchChatHistory{mockChatHistory: mockChatHistory{
+				messages: []llms.ChatMessage{llms.SystemChatMessage{Content: "Likes tea\n"}},
+			}},
+			facts: []string{"Likes tea", "Lives in Berlin"},
+		}
+
+		result, err := m.SaveAndLoadContext(ctx,
+			map[string]any{"input": "I live in Berlin"},
+			map[string]any{"output": "Nice"},
+		)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		messages := result["history"].([]llms.ChatMessage)
+		if len(messages) != 3 || messages[0].GetContent() != "Likes tea\nLives in Berlin\n" {
+			t.Errorf("Expected the new facts of the add response in the system message, got %v", messages)
+		}
+	})
+
+	t.Run("SaveError", func(t *testing.T) {
+		m := ApplyOptions()
+		m.ChatHistory = &mockChatHistory{addAIErr: context.DeadlineExceeded}
+
+		_, err := m.SaveAndLoadContext(ctx,
+			map[string]any{"input": "Hello"},
+			map[string]any{"output": "Hi"},
+		)
+		if err == nil {
+			t.Errorf("Expected error from AddAIMessage")
+		}
+	})
+}
+
+// batchChatHistory is a mockChatHistory that also supports adding a whole turn at once
+type batchChatHistory struct {
+	mockChatHistory
+	batches  [][]llms.ChatMessage
+	batchErr error
+}
+
+func (m *batchChatHistory) AddMessages(_ context.Context, messages []llms.ChatMessage) error {
+	if m.batchErr != nil {
+		return m.batchErr
+	}
+	m.batches = append(m.batches, messages)
+	return nil
+}
+
+// overlapHistory fails a load or a save that does not run while the other one is in flight.
+type overlapHistory struct {
+	batchChatHistory
+	loading, saving chan struct{}
+}
+
+func (m *overlapHistory) Messages(_ context.Context) ([]llms.ChatMessage, error) {
+	close(m.loading)
+	select {
+	case <-m.saving:
+		return nil, nil
+	case <-time.After(time.Second):
+		return nil, errors.New("load finished before the save started")
+	}
+}
+
+func (m *overlapHistory) AddMessages(ctx context.Context, messages []llms.ChatMessage) error {
+	close(m.saving)
+	select {
+	case <-m.loading:
+		return m.batchChatHistory.AddMessages(ctx, messages)
+	case <-time.After(time.Second):
+		return errors.New("save finished before the load started")
+	}
+}
+
+// factHistory is a batchChatHistory whose add response returns facts.
+type factHistory struct {
+	batchChatHistory
+	facts []string
+}
+
+func (m *factHistory) AddMessagesWithFacts(ctx context.Context, messages []llms.ChatMessage) ([]string, error) {
+	return m.facts, m.AddMessages(ctx, messages)
+}
+
+// TestSaveContextAtomic tests that SaveContext saves a turn in one batch and reports partial saves
+func TestSaveContextAtomic(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("SingleBatch", func(t *testing.T) {
+		m := ApplyOptions()
+		history := &batchChatHistory{}
+		m.ChatHistory = history
+
+		err := m.SaveContext(ctx,
+			map[string]any{"input": "Hello"},
+			map[string]any{"output": "Hi there"},
+		)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(history.batches) != 1 || len(history.batches[0]) != 2 {
+			t.Fatalf("Expected one batch with two messages, got %v", history.batches)
+		}
+		if history.userMessageAdded != "" || history.aiMessageAdded != "" {
+			t.Errorf("Expected no separate AddUserMessage or AddAIMessage calls")
+		}
+	})
+
+	t.Run("BatchError", func(t *testing.T) {
+		m := ApplyOptions()
+		m.ChatHistory = &batchChatHistory{batchErr: context.DeadlineExceeded}
+
+		err := m.SaveContext(ctx,
+			map[string]any{"input": "Hello"},
+			map[string]any{"output": "Hi there"},
+		)
+		var saveErr *SaveContextError
+		if !errors.As(err, &saveErr) || saveErr.UserSaved {
+			t.Fatalf("Expected SaveContextError with nothing saved, got %v", err)
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected error to wrap the backend error")
+		}
+	})
+
+	t.Run("PartialSave", func(t *testing.T) {
+		m := ApplyOptions()
+		m.ChatHistory = &mockChatHistory{addAIErr: context.DeadlineExceeded}
+
+		err := m.SaveContext(ctx,
+			map[string]any{"input": "Hello"},
+			map[string]any{"output": "Hi there"},
+		)
+		var saveErr *SaveContextError
+		if !errors.As(err, &saveErr) || !saveErr.UserSaved {
+			t.Errorf("Expected SaveContextError reporting the saved user message, got %v", err)
+		}
+	})
+
+	t.Run("MissingOutputKeySavesNothing", func(t *testing.T) {
+		m := ApplyOptions()
+		history := &mockChatHistory{}
+		m.ChatHistory = history
+
+		err := m.SaveContext(ctx, map[string]any{"input": "Hello"}, map[string]any{})
+		if err == nil {
+			t.Fatalf("Expected error for missing output value")
+		}
+		if history.userMessageAdded != "" {
+			t.Errorf("Expected user message not to be saved")
+		}
+	})
+}
+
+func TestNewMemory(t *testing.T) {
+	t.Parallel()
+
+	backend := &batchChatHistory{}
+	m := NewMemory(backend,
+		WithReturnMessages(false),
+		WithMemoryKey("custom_history"),
+		WithHumanPrefix("User"),
+		WithAIPrefix("Assistant"),
+		WithInputKey("input"),
+		WithOutputKey("output"),
+	)
+
+	if m.ChatHistory != backend {
+		t.Errorf("Expected ChatHistory to be the backend")
+	}
+	if m.ReturnMessages {
+		t.Errorf("Expected ReturnMessages to be false")
+	}
+	if m.MemoryKey != "custom_history" {
+		t.Errorf("Expected MemoryKey to be 'custom_history', got %s", m.MemoryKey)
+	}
+	if m.HumanPrefix != "User" || m.AIPrefix != "Assistant" {
+		t.Errorf("Expected prefixes 'User' and 'Assistant', got %s and %s", m.HumanPrefix, m.AIPrefix)
+	}
+	if m.InputKey != "input" || m.OutputKey != "output" {
+		t.Errorf("Expected keys 'input' and 'output', got %s and %s", m.InputKey, m.OutputKey)
+	}
+	if vars := m.MemoryVariables(context.Background()); len(vars) != 1 || vars[0] != "custom_history" {
+		t.Errorf("Expected memory variables [custom_history], got %v", vars)
+	}
+}
+
+func TestLoadMemoryVariables(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	history := &mockChatHistory{
+		messages: []llms.ChatMessage{
+			llms.HumanChatMessage{Content: "Hello"},
+			llms.AIChatMessage{Content: "Hi there"},
+		},
+	}
+
+	m := ApplyOptions(WithReturnMessages(false), WithHumanPrefix("User"))
+	m.ChatHistory = history
+	result, err := m.LoadMemoryVariables(ctx, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result["history"] != "User: Hello\nAI: Hi there" {
+		t.Errorf("Expected buffer string with custom prefix, got %q", result["history"])
+	}
+
+	m = ApplyOptions()
+	m.ChatHistory = &mockChatHistory{messagesErr: context.Canceled}
+	if _, err := m.LoadMemoryVariables(ctx, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestClear(t *testing.T) {
+	t.Parallel()
+
+	history := &mockChatHistory{}
+	m := ApplyOptions()
+	m.ChatHistory = history
+
+	if err := m.Clear(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !history.cleared {
+		t.Errorf("Expected Clear to be called on chat history")
+	}
+}
@@ -0,0 +1,221 @@
+package core
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// EncryptionKeyIDMetadataKey is the metadata key under which backends store the ID of the key
+// that encrypted their messages, so that the messages of a rotated key can be found.
+const EncryptionKeyIDMetadataKey = "encryption_key_id"
+
+// envelopePrefix starts the content of encrypted messages.
+const envelopePrefix = "enc:v1:"
+
+var (
+	// ErrUnknownKey is returned for messages encrypted with a key the key provider does not
+	// have.
+	ErrUnknownKey = errors.New("unknown encryption key")
+	// ErrNotEncrypted is returned for messages that are not encrypted, unless plaintext is
+	// allowed with WithAllowPlaintext.
+	ErrNotEncrypted = errors.New("message is not encrypted")
+)
+
+// KeyProvider provides the key encryption keys of an Encryptor: 32 byte AES-256 keys,
+// identified by IDs that are stored with the messages they encrypt.
+type KeyProvider interface {
+	// CurrentKey returns the key that encrypts new messages and its ID.
+	CurrentKey(ctx context.Context) (id string, key []byte, err error)
+	// Key returns the key with the given ID, or an error wrapping ErrUnknownKey.
+	Key(ctx context.Context, id string) ([]byte, error)
+}
+
+// StaticKeyProvider is a KeyProvider with fixed keys. Keys are rotated by adding a key and
+// making it current, while keeping the old keys to read older messages.
+type StaticKeyProvider struct {
+	CurrentID string
+	Keys      map[string][]byte
+}
+
+// Statically assert that StaticKeyProvider implement the key provider interface.
+var _ KeyProvider = &StaticKeyProvider{}
+
+// NewStaticKeyProvider returns a key provider encrypting with the key currentID of keys.
+func NewStaticKeyProvider(currentID string, keys map[string][]byte) *StaticKeyProvider {
+	return &StaticKeyProvider{CurrentID: currentID, Keys: keys}
+}
+
+// CurrentKey returns the key CurrentID.
+func (p *StaticKeyProvider) CurrentKey(ctx context.Context) (string, []byte, error) {
+	key, err := p.Key(ctx, p.CurrentID)
+	return p.CurrentID, key, err
+}
+
+// Key returns the key with the given ID.
+func (p *StaticKeyProvider) Key(_ context.Context, id string) ([]byte, error) {
+	key, ok := p.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	return key, nil
+}
+
+// Encryptor encrypts the content of messages on the client with envelope encryption: every
+// message is encrypted with AES-256-GCM under a data key of its own, which is encrypted with
+// the current key of Keys. The encrypted data key and the ID of the key are stored in the
+// content, so messages stay readable after the keys are rotated. A nil Encryptor does not
+// encrypt.
+type Encryptor struct {
+	Keys KeyProvider
+	// AllowPlaintext lets Decrypt return messages that are not encrypted as they are, such as
+	// messages written before encryption was turned on. Otherwise they are rejected, so that
+	// the backend cannot inject messages.
+	AllowPlaintext bool
+}
+
+// EncryptorOption is a function for creating a new encryptor with other than the default
+// values.
+type EncryptorOption func(e *Encryptor)
+
+// WithAllowPlaintext is an option for reading messages that are not encrypted, while the
+// messages written before encryption was turned on are migrated.
+func WithAllowPlaintext() EncryptorOption {
+	return func(e *Encryptor) {
+		e.AllowPlaintext = true
+	}
+}
+
+// NewEncryptor returns an encryptor with the keys of provider.
+func NewEncryptor(provider KeyProvider, options ...EncryptorOption) *Encryptor {
+	e := &Encryptor{Keys: provider}
+	for _, option := range options {
+		option(e)
+	}
+	return e
+}
+
+// AssociatedData returns the data that binds an encrypted message to the user or session that
+// owns it and to its role, so that the backend cannot move a message to another session or
+// turn it into a message of another role without Decrypt failing.
+func AssociatedData(ownerID, role string) []byte {
+	return fmt.Appendf(nil, "%d:%s%s", len(ownerID), ownerID, role)
+}
+
+// Encrypt encrypts plaintext, bound to associatedData, and returns it with the ID of the key
+// that encrypted its data key. See AssociatedData.
+func (e *Encryptor) Encrypt(ctx context.Context, plaintext string, associatedData []byte) (ciphertext, keyID string, err error) {
+	if e == nil {
+		return plaintext, "", nil
+	}
+	keyID, key, err := e.Keys.CurrentKey(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	if strings.Contains(keyID, ".") {
+		return "", "", fmt.Errorf("encrypting message: key ID %q contains a dot", keyID)
+	}
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", "", err
+	}
+	wrappedKey, err := seal(key, dataKey, []byte(keyID))
+	if err != nil {
+		return "", "", fmt.Errorf("encrypting message: %w", err)
+	}
+	sealed, err := seal(dataKey, []byte(plaintext), associatedData)
+	if err != nil {
+		return "", "", fmt.Errorf("encrypting message: %w", err)
+	}
+	encoding := base64.RawURLEncoding
+	return envelopePrefix + keyID + "." + encoding.EncodeToString(wrappedKey) + "." + encoding.EncodeToString(sealed),
+		keyID, nil
+}
+
+// Decrypt decrypts content encrypted by Encrypt with the same associatedData. Content that is
+// not encrypted fails with ErrNotEncrypted, unless AllowPlaintext is set.
+func (e *Encryptor) Decrypt(ctx context.Context, content string, associatedData []byte) (string, error) {
+	if e == nil {
+		return content, nil
+	}
+	if !IsEncrypted(content) {
+		if !e.AllowPlaintext {
+			return "", fmt.Errorf("decrypting message: %w", ErrNotEncrypted)
+		}
+		return content, nil
+	}
+	parts := strings.Split(strings.TrimPrefix(content, envelopePrefix), ".")
+	if len(parts) != 3 {
+		return "", errors.New("decrypting message: malformed envelope")
+	}
+	keyID := parts[0]
+	encoding := base64.RawURLEncoding
+	wrappedKey, err := encoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("decrypting message: %w", err)
+	}
+	sealed, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("decrypting message: %w", err)
+	}
+	key, err := e.Keys.Key(ctx, keyID)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := open(key, wrappedKey, []byte(keyID))
+	if err != nil {
+		return "", fmt.Errorf("decrypting message with key %q: %w", keyID, err)
+	}
+	plaintext, err := open(dataKey, sealed, associatedData)
+	if err != nil {
+		return "", fmt.Errorf("decrypting message with key %q: %w", keyID, err)
+	}
+	return string(plaintext), nil
+}
+
+// IsEncrypted reports whether content was encrypted by an Encryptor.
+func IsEncrypted(content string) bool {
+	return strings.HasPrefix(content, envelopePrefix)
+}
+
+// seal encrypts plaintext with AES-GCM under key, and returns it after its random nonce.
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts the output of seal.
+func open(key, sealed, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonceSize := aead.NonceSize()
+	return aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, errors.New("keys must be 32 bytes long")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
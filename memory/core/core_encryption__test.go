@@ -0,0 +1,67 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncryptor(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	keys := NewStaticKeyProvider("2024", map[string][]byte{"2024": bytes.Repeat([]byte{1}, 32)})
+	e := NewEncryptor(keys)
+	sarah := AssociatedData("sarah", "user")
+	ciphertext, keyID, err := e.Encrypt(ctx, "I live in Berlin", sarah)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if keyID != "2024" || !IsEncrypted(ciphertext) || strings.Contains(ciphertext, "Berlin") {
+		t.Fatalf("Expected an envelope of key 2024, got %q of key %q", ciphertext, keyID)
+	}
+	if again, _, _ := e.Encrypt(ctx, "I live in Berlin", sarah); again == ciphertext {
+		t.Errorf("Expected every message to have its own data key and nonce")
+	}
+
+	// Rotating the key keeps older messages readable.
+	keys.Keys["2025"] = bytes.Repeat([]byte{2}, 32)
+	keys.CurrentID = "2025"
+	if _, keyID, _ := e.Encrypt(ctx, "text", sarah); keyID != "2025" {
+		t.Errorf("Expected the rotated key 2025, got %q", keyID)
+	}
+	plaintext, err := e.Decrypt(ctx, ciphertext, sarah)
+	if err != nil || plaintext != "I live in Berlin" {
+		t.Errorf("Expected the message of the old key, got %q, %v", plaintext, err)
+	}
+
+	if _, err := e.Decrypt(ctx, ciphertext, AssociatedData("bob", "user")); err == nil {
+		t.Errorf("Expected a message moved to another user to fail")
+	}
+	if _, err := e.Decrypt(ctx, ciphertext, AssociatedData("sarah", "assistant")); err == nil {
+		t.Errorf("Expected a message moved to another role to fail")
+	}
+
+	if _, err := e.Decrypt(ctx, "written before encryption", sarah); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("Expected plaintext to be rejected, got %v", err)
+	}
+	migrating := NewEncryptor(keys, WithAllowPlaintext())
+	if plaintext, err := migrating.Decrypt(ctx, "written before encryption", sarah); err != nil || plaintext != "written before encryption" {
+		t.Errorf("Expected plaintext to pass through while migrating, got %q, %v", plaintext, err)
+	}
+	delete(keys.Keys, "2024")
+	if _, err := e.Decrypt(ctx, ciphertext, sarah); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+	keys.Keys["2024"] = bytes.Repeat([]byte{3}, 32)
+	if _, err := e.Decrypt(ctx, ciphertext, sarah); err == nil {
+		t.Errorf("Expected decryption with the wrong key to fail")
+	}
+
+	var disabled *Encryptor
+	if ciphertext, _, err := disabled.Encrypt(ctx, "text", nil); err != nil || ciphertext != "text" {
+		t.Errorf("Expected a nil encryptor not to encrypt, got %q, %v", ciphertext, err)
+	}
+}
@@ -0,0 +1,116 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// The kinds of the errors of memory backends. Backends translate the errors of their clients
+// into a *BackendError matching one of them with errors.Is, so that callers can tell a missing
+// session from a rejected API key without knowing the client of the backend.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrUnavailable  = errors.New("backend unavailable")
+	ErrInvalidInput = errors.New("invalid input")
+)
+
+// BackendError is a failed request of a memory backend. It matches its Kind and the original
+// error of the client with errors.Is and errors.As.
+type BackendError struct {
+	// Backend names the backend, such as "mem0" or "graphiti".
+	Backend string
+	// Kind is one of ErrNotFound, ErrUnauthorized, ErrRateLimited, ErrUnavailable and
+	// ErrInvalidInput.
+	Kind error
+	// StatusCode is the status code of the failed response, 0 if there was no response.
+	StatusCode int
+	// Wait is the Retry-After of the failed response, 0 if it is not known.
+	Wait time.Duration
+	// Err is the original error of the client.
+	Err error
+}
+
+func (e *BackendError) Error() string {
+	return fmt.Sprintf("%s: %v: %v", e.Backend, e.Kind, e.Err)
+}
+
+// Unwrap returns the kind and the original error.
+func (e *BackendError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// RetryAfter returns Wait, so that a RetryPolicy waits that long before retrying.
+func (e *BackendError) RetryAfter() time.Duration {
+	return e.Wait
+}
+
+// PartialWriteError is returned by the AddMessages of backends that write a batch of messages
+// in several requests, when only some of them were written. Sending the batch again would
+// duplicate the written messages.
+type PartialWriteError struct {
+	// Written are the messages of the batch that were written.
+	Written []llms.ChatMessage
+	Err     error
+}
+
+func (e *PartialWriteError) Error() string {
+	return fmt.Sprintf("%d messages written before: %v", len(e.Written), e.Err)
+}
+
+func (e *PartialWriteError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorKind returns the kind of the errors of responses with the given status code, nil for
+// status codes of no known kind.
+func ErrorKind(statusCode int) error {
+	switch {
+	case statusCode == http.StatusNotFound || statusCode == http.StatusGone:
+		return ErrNotFound
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return ErrUnauthorized
+	case statusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case statusCode == http.StatusRequestTimeout || statusCode >= 500:
+		return ErrUnavailable
+	case statusCode >= 400:
+		return ErrInvalidInput
+	}
+	return nil
+}
+
+// NewBackendError translates err, the error of a request of backend, into a *BackendError. The
+// status code of the failed response is 0 if there was no response, in which case only network
+// errors are translated, into ErrUnavailable. Errors of no known kind, context errors and
+// errors that are already translated are returned as is.
+//
+// Called with the context given to a RetryPolicy attempt, the Retry-After seen by a
+// RetryAfterTransport is kept in the error.
+func NewBackendError(ctx context.Context, backend string, statusCode int, err error) error {
+	var backendErr *BackendError
+	if err == nil || errors.As(err, &backendErr) ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	kind := ErrorKind(statusCode)
+	if statusCode == 0 && ClassifyNetworkError(err) != NotRetryable {
+		kind = ErrUnavailable
+	}
+	if kind == nil {
+		return err
+	}
+	backendErr = &BackendError{Backend: backend, Kind: kind, StatusCode: statusCode, Err: err}
+	if slot, ok := ctx.Value(retryAfterKey{}).(*retryAfterSlot); ok {
+		slot.mu.Lock()
+		backendErr.Wait = slot.after
+		slot.mu.Unlock()
+	}
+	return backendErr
+}
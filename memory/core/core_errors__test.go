@@ -0,0 +1,88 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestErrorKind(t *testing.T) {
+	t.Parallel()
+
+	kinds := map[int]error{
+		http.StatusOK:                  nil,
+		http.StatusBadRequest:          ErrInvalidInput,
+		http.StatusUnprocessableEntity: ErrInvalidInput,
+		http.StatusUnauthorized:        ErrUnauthorized,
+		http.StatusForbidden:           ErrUnauthorized,
+		http.StatusNotFound:            ErrNotFound,
+		http.StatusRequestTimeout:      ErrUnavailable,
+		http.StatusTooManyRequests:     ErrRateLimited,
+		http.StatusBadGateway:          ErrUnavailable,
+	}
+	for status, expected := range kinds {
+		if got := ErrorKind(status); got != expected {
+			t.Errorf("Expected %d to be of kind %v, got %v", status, expected, got)
+		}
+	}
+}
+
+func TestNewBackendError(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	original := &statusError{status: http.StatusNotFound}
+	err := NewBackendError(ctx, "test", http.StatusNotFound, original)
+	var backendErr *BackendError
+	var status *statusError
+	if !errors.Is(err, ErrNotFound) || !errors.As(err, &backendErr) || !errors.As(err, &status) || status != original {
+		t.Fatalf("Expected a not found error wrapping the original error, got %v", err)
+	}
+	if backendErr.Backend != "test" || backendErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected the backend and the status code, got %q, %d", backendErr.Backend, backendErr.StatusCode)
+	}
+	if again := NewBackendError(ctx, "other", http.StatusBadRequest, err); again != err {
+		t.Errorf("Expected a translated error to be returned as is, got %v", again)
+	}
+
+	dial := &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}
+	if err := NewBackendError(ctx, "test", 0, dial); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected a network error to be unavailable, got %v", err)
+	}
+	for _, err := range []error{nil, context.Canceled, context.DeadlineExceeded, errors.New("invalid character")} {
+		if got := NewBackendError(ctx, "test", 0, err); got != err {
+			t.Errorf("Expected %v to be returned as is, got %v", err, got)
+		}
+	}
+}
+
+func TestNewBackendErrorRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: RetryAfterTransport(nil)}
+
+	var policy *RetryPolicy
+	err := policy.Do(context.Background(), false, func(ctx context.Context) error {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return NewBackendError(ctx, "test", resp.StatusCode, &statusError{status: resp.StatusCode})
+	})
+	var backendErr *BackendError
+	if !errors.Is(err, ErrRateLimited) || !errors.As(err, &backendErr) || backendErr.RetryAfter() != 7*time.Second {
+		t.Errorf("Expected a rate limit with the Retry-After, got %v", err)
+	}
+}
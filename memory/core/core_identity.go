@@ -0,0 +1,93 @@
+package core
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrNoIdentity is wrapped by the errors of backends whose resolver found no user or
+	// session for a request.
+	ErrNoIdentity = errors.New("no user or session for the request")
+	// ErrResolverRequired is wrapped by the errors of wrappers that key their state by user or
+	// session, such as a cache, around a history that resolves the identity of every call,
+	// while the wrapper has no Resolver of its own: it would mix the state of every user.
+	ErrResolverRequired = errors.New("history resolves the user and session of every call, give the wrapper a resolver")
+	// ErrResolverUnsupported is wrapped by the errors of wrappers that keep state for a single
+	// user or session, such as a local buffer, around a history that resolves the identity of
+	// every call.
+	ErrResolverUnsupported = errors.New("history resolves the user and session of every call, which the wrapper does not support")
+)
+
+// Resolver resolves the user and session of a request from its context, so that a single
+// memory can serve the requests of every user. Empty identifiers leave those of the memory.
+type Resolver func(ctx context.Context) (userID, sessionID string, err error)
+
+type (
+	userIDKey    struct{}
+	sessionIDKey struct{}
+)
+
+// ContextWithUserID returns a context carrying the ID of the user of a request.
+func ContextWithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// ContextWithSessionID returns a context carrying the ID of the session of a request.
+func ContextWithSessionID(ctx context.Context, sessionID string) context.Context {
+	return context.WithValue(ctx, sessionIDKey{}, sessionID)
+}
+
+// UserIDFromContext returns the user ID stored by ContextWithUserID, if any.
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(userIDKey{}).(string)
+	return userID, ok
+}
+
+// SessionIDFromContext returns the session ID stored by ContextWithSessionID, if any.
+func SessionIDFromContext(ctx context.Context) (string, bool) {
+	sessionID, ok := ctx.Value(sessionIDKey{}).(string)
+	return sessionID, ok
+}
+
+// ResolveFromContext is a Resolver returning the IDs stored by ContextWithUserID and
+// ContextWithSessionID.
+func ResolveFromContext(ctx context.Context) (userID, sessionID string, err error) {
+	userID, _ = UserIDFromContext(ctx)
+	sessionID, _ = SessionIDFromContext(ctx)
+	return userID, sessionID, nil
+}
+
+// Resolve returns the user and session of a request: those returned by resolver, or userID
+// and sessionID where it returns none. A nil resolver returns userID and sessionID.
+func Resolve(ctx context.Context, resolver Resolver, userID, sessionID string) (string, string, error) {
+	if resolver == nil {
+		return userID, sessionID, nil
+	}
+	resolvedUserID, resolvedSessionID, err := resolver(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	if resolvedUserID != "" {
+		userID = resolvedUserID
+	}
+	if resolvedSessionID != "" {
+		sessionID = resolvedSessionID
+	}
+	return userID, sessionID, nil
+}
+
+// IdentityResolver is implemented by the histories that can resolve the user and session of
+// every call from its context, so that wrappers keeping state of their own can tell that a
+// single history serves several users.
+type IdentityResolver interface {
+	// ResolvesIdentity reports whether the history resolves the identity of every call.
+	ResolvesIdentity() bool
+}
+
+// ResolvesIdentity reports whether history is an IdentityResolver resolving the identity of
+// every call.
+func ResolvesIdentity(history any) bool {
+	resolver, ok := history.(IdentityResolver)
+	return ok && resolver.ResolvesIdentity()
+}
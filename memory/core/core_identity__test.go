@@ -0,0 +1,34 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	t.Parallel()
+	ctx := ContextWithSessionID(ContextWithUserID(context.Background(), "sarah"), "sarah-1")
+
+	if userID, ok := UserIDFromContext(ctx); !ok || userID != "sarah" {
+		t.Errorf("Expected user sarah, got %q", userID)
+	}
+	if _, ok := SessionIDFromContext(context.Background()); ok {
+		t.Errorf("Expected no session in an empty context")
+	}
+
+	userID, sessionID, err := Resolve(ctx, ResolveFromContext, "default", "default-1")
+	if err != nil || userID != "sarah" || sessionID != "sarah-1" {
+		t.Errorf("Expected sarah and sarah-1, got %q, %q, %v", userID, sessionID, err)
+	}
+	userID, sessionID, err = Resolve(context.Background(), ResolveFromContext, "default", "default-1")
+	if err != nil || userID != "default" || sessionID != "default-1" {
+		t.Errorf("Expected the defaults for an empty context, got %q, %q, %v", userID, sessionID, err)
+	}
+
+	errUnauthenticated := errors.New("unauthenticated")
+	failing := func(context.Context) (string, string, error) { return "", "", errUnauthenticated }
+	if _, _, err := Resolve(ctx, failing, "default", ""); !errors.Is(err, errUnauthenticated) {
+		t.Errorf("Expected the error of the resolver, got %v", err)
+	}
+}
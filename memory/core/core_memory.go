@@ -0,0 +1,329 @@
+// Package core implements schema.Memory on top of any chat message history backend. The
+// mem0 and graphiti packages are thin backends around it.
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/memory"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// ErrUnknownRole is wrapped by the errors of backends rejecting messages whose role they cannot
+// store or read, instead of dropping them.
+var ErrUnknownRole = errors.New("unknown message role")
+
+// Backend is a chat message history that can also add several messages in one request. A
+// Memory accepts any schema.ChatMessageHistory, but only saves a turn atomically when the
+// history is a Backend.
+type Backend interface {
+	schema.ChatMessageHistory
+	AddMessages(ctx context.Context, messages []llms.ChatMessage) error
+}
+
+// FactSource is a backend that can return its long-term knowledge, such as extracted facts
+// and summaries, without fetching the transcript.
+type FactSource interface {
+	Facts(ctx context.Context) ([]string, error)
+}
+
+// FactAdder is a backend whose add response returns the facts extracted from the added
+// messages, such as the memories mem0 creates from them. SaveAndLoadContext uses those facts
+// rather than waiting for a load that may have started before they existed.
+type FactAdder interface {
+	AddMessagesWithFacts(ctx context.Context, messages []llms.ChatMessage) ([]string, error)
+}
+
+// Memory is a simple form of memory that remembers previous conversational back and forth directly.
+type Memory struct {
+	ChatHistory    schema.ChatMessageHistory
+	ReturnMessages bool
+	InputKey       string
+	OutputKey      string
+	HumanPrefix    string
+	AIPrefix       string
+	MemoryKey      string
+
+	// MaxTokens limits the tokens of the loaded history, as counted by Tokenizer. Zero
+	// returns the history unchanged.
+	MaxTokens int
+	// SystemMaxTokens limits the tokens of the system messages carrying facts and summaries
+	// within MaxTokens. Zero lets them use the whole budget.
+	SystemMaxTokens int
+	Tokenizer       Tokenizer
+	// OnTrim is called whenever loading trimmed the history to fit MaxTokens.
+	OnTrim func(ctx context.Context, report TrimReport)
+
+	// Telemetry, if set, traces and measures LoadMemoryVariables and SaveContext.
+	Telemetry *Telemetry
+}
+
+// Statically assert that Memory implement the memory interface.
+var _ schema.Memory = &Memory{}
+
+// NewMemory is a function for creating a new memory on top of a backend.
+func NewMemory(backend Backend, options ...Option) *Memory {
+	m := ApplyOptions(options...)
+	m.ChatHistory = backend
+	return m
+}
+
+// MemoryVariables gets the input key the buffer memory class will load dynamically.
+func (m *Memory) MemoryVariables(context.Context) []string {
+	return []string{m.MemoryKey}
+}
+
+// LoadMemoryVariables returns the previous chat messages stored in memory
+// as well as any system message with conversation facts or summaries the backend provides.
+// If MaxTokens is set, the oldest messages are dropped or truncated to fit the budget.
+// Previous chat messages are returned in a map with the key specified in the MemoryKey field. This key defaults to
+// "history". If ReturnMessages is set to true the output is a slice of schema.ChatMessage. Otherwise,
+// the output is a buffer string of the chat messages.
+func (m *Memory) LoadMemoryVariables(
+	ctx context.Context, _ map[string]any,
+) (_ map[string]any, err error) {
+	ctx, op := m.Telemetry.Start(ctx, "LoadMemoryVariables")
+	defer func() { op.End(ctx, err) }()
+
+	messages, err := m.ChatHistory.Messages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return m.memoryVariables(ctx, op, messages)
+}
+
+func (m *Memory) memoryVariables(ctx context.Context, op *Operation, messages []llms.ChatMessage) (map[string]any, error) {
+	messages = m.trim(ctx, messages)
+	op.RecordMessages(messages)
+	if m.ReturnMessages {
+		return map[string]any{
+			m.MemoryKey: messages,
+		}, nil
+	}
+
+	bufferString, err := llms.GetBufferString(messages, m.HumanPrefix, m.AIPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]any{
+		m.MemoryKey: bufferString,
+	}, nil
+}
+
+// SaveContext uses the input values to the llm to save a user message, and the output values
+// of the llm to save an AI message. If the input or output key is not set, the input values or
+// output values must contain only one key such that the function can know what string to
+// add as a user and AI message. On the other hand, if the output key or input key is set, the
+// input key must be a key in the input values and the output key must be a key in the output
+// values. The values in the input and output values used to save a user and AI message must
+// be strings. Both messages are sent in a single request when the chat history supports it;
+// if the turn is not saved completely, a *SaveContextError reports which parts were persisted.
+func (m *Memory) SaveContext(
+	ctx context.Context,
+	inputValues map[string]any,
+	outputValues map[string]any,
+) (err error) {
+	ctx, op := m.Telemetry.Start(ctx, "SaveContext")
+	defer func() { op.End(ctx, err) }()
+
+	userInputValue, err := memory.GetInputValue(inputValues, m.InputKey)
+	if err != nil {
+		return err
+	}
+	aiOutputValue, err := memory.GetInputValue(outputValues, m.OutputKey)
+	if err != nil {
+		return err
+	}
+	op.RecordMessages([]llms.ChatMessage{
+		llms.HumanChatMessage{Content: userInputValue},
+		llms.AIChatMessage{Content: aiOutputValue},
+	})
+
+	if backend, ok := m.ChatHistory.(Backend); ok {
+		err = backend.AddMessages(ctx, []llms.ChatMessage{
+			llms.HumanChatMessage{Content: userInputValue},
+			llms.AIChatMessage{Content: aiOutputValue},
+		})
+		if err != nil {
+			return &SaveContextError{UserSaved: userSaved(err), Err: err}
+		}
+		return nil
+	}
+
+	err = m.ChatHistory.AddUserMessage(ctx, userInputValue)
+	if err != nil {
+		return &SaveContextError{Err: err}
+	}
+	err = m.ChatHistory.AddAIMessage(ctx, aiOutputValue)
+	if err != nil {
+		return &SaveContextError{UserSaved: true, Err: err}
+	}
+
+	return nil
+}
+
+// userSaved reports whether err reports a partial write that included the user message.
+func userSaved(err error) bool {
+	var partial *PartialWriteError
+	if !errors.As(err, &partial) {
+		return false
+	}
+	return slices.ContainsFunc(partial.Written, func(message llms.ChatMessage) bool {
+		return message.GetType() == llms.ChatMessageTypeHuman
+	})
+}
+
+// SaveContextError is returned by SaveContext when a turn was not saved completely.
+// UserSaved reports whether the user message was persisted before the failure.
+type SaveContextError struct {
+	UserSaved bool
+	Err       error
+}
+
+func (e *SaveContextError) Error() string {
+	if e.UserSaved {
+		return fmt.Sprintf("save context: user message saved, AI message not saved: %v", e.Err)
+	}
+	return fmt.Sprintf("save context: nothing saved: %v", e.Err)
+}
+
+func (e *SaveContextError) Unwrap() error {
+	return e.Err
+}
+
+// SaveAndLoadContext saves the turn like SaveContext and returns the memory variables for the
+// next turn like LoadMemoryVariables. Backends do not return the whole updated context when
+// messages are added, so the messages of a Backend are loaded while the turn is saved, and the
+// saved turn is appended to them unless the load already observed it. The facts a FactAdder
+// returns for the turn are added to the facts of the load. Other histories, such as the
+// in-process history of langchaingo, are not safe for concurrent use and are loaded first.
+func (m *Memory) SaveAndLoadContext(
+	ctx context.Context,
+	inputValues map[string]any,
+	outputValues map[string]any,
+) (_ map[string]any, err error) {
+	ctx, op := m.Telemetry.Start(ctx, "SaveAndLoadContext")
+	defer func() { op.End(ctx, err) }()
+
+	userInputValue, err := memory.GetInputValue(inputValues, m.InputKey)
+	if err != nil {
+		return nil, err
+	}
+	aiOutputValue, err := memory.GetInputValue(outputValues, m.OutputKey)
+	if err != nil {
+		return nil, err
+	}
+	turn := []llms.ChatMessage{
+		llms.HumanChatMessage{Content: userInputValue},
+		llms.AIChatMessage{Content: aiOutputValue},
+	}
+
+	type load struct {
+		messages []llms.ChatMessage
+		err      error
+	}
+	loaded := make(chan load, 1)
+	loadMessages := func() {
+		messages, err := m.ChatHistory.Messages(ctx)
+		loaded <- load{messages, err}
+	}
+	if _, ok := m.ChatHistory.(Backend); ok {
+		go loadMessages()
+	} else {
+		loadMessages()
+	}
+
+	var facts []string
+	if adder, ok := m.ChatHistory.(FactAdder); ok {
+		op.RecordMessages(turn)
+		facts, err = adder.AddMessagesWithFacts(ctx, turn)
+		if err != nil {
+			err = &SaveContextError{UserSaved: userSaved(err), Err: err}
+		}
+	} else {
+		err = m.SaveContext(ctx, inputValues, outputValues)
+	}
+	l := <-loaded
+	if err != nil {
+		return nil, err
+	}
+	if l.err != nil {
+		return nil, l.err
+	}
+	return m.memoryVariables(ctx, op, addFacts(appendTurn(l.messages, turn), facts))
+}
+
+// appendTurn returns messages followed by the messages of turn that they do not already end
+// with, as a load running concurrently with the save may or may not observe the turn.
+func appendTurn(messages, turn []llms.ChatMessage) []llms.ChatMessage {
+	observed := len(turn)
+	for observed > 0 && !endsWith(messages, turn[:observed]) {
+		observed--
+	}
+	merged := make([]llms.ChatMessage, 0, len(messages)+len(turn)-observed)
+	merged = append(merged, messages...)
+	return append(merged, turn[observed:]...)
+}
+
+// endsWith reports whether the last messages have the types and contents of suffix.
+func endsWith(messages, suffix []llms.ChatMessage) bool {
+	if len(suffix) > len(messages) {
+		return false
+	}
+	for i, message := range messages[len(messages)-len(suffix):] {
+		if message.GetType() != suffix[i].GetType() || message.GetContent() != suffix[i].GetContent() {
+			return false
+		}
+	}
+	return true
+}
+
+// addFacts adds the facts that the system messages do not contain yet to the leading system
+// message, creating it if needed.
+func addFacts(messages []llms.ChatMessage, facts []string) []llms.ChatMessage {
+	known := make(map[string]bool)
+	for _, message := range messages {
+		if message.GetType() != llms.ChatMessageTypeSystem {
+			continue
+		}
+		for _, line := range strings.Split(message.GetContent(), "\n") {
+			known[strings.TrimSpace(line)] = true
+		}
+	}
+	var content strings.Builder
+	for _, fact := range facts {
+		if fact = strings.TrimSpace(fact); fact != "" && !known[fact] {
+			known[fact] = true
+			content.WriteString(fact + "\n")
+		}
+	}
+	if content.Len() == 0 {
+		return messages
+	}
+	if len(messages) > 0 && messages[0].GetType() == llms.ChatMessageTypeSystem {
+		system := messages[0].GetContent()
+		if !strings.HasSuffix(system, "\n") {
+			system += "\n"
+		}
+		merged := slices.Clone(messages)
+		merged[0] = llms.SystemChatMessage{Content: system + content.String()}
+		return merged
+	}
+	return append([]llms.ChatMessage{llms.SystemChatMessage{Content: content.String()}}, messages...)
+}
+
+// Clear sets the chat messages to a new and empty chat message history.
+func (m *Memory) Clear(ctx context.Context) error {
+	return m.ChatHistory.Clear(ctx)
+}
+
+// GetMemoryKey returns the key the chat history is stored under in the memory variables.
+func (m *Memory) GetMemoryKey(context.Context) string {
+	return m.MemoryKey
+}
@@ -0,0 +1,100 @@
+package core
+
+import "context"
+
+// Option is a function for creating new memory
+// with other than the default values.
+type Option func(b *Memory)
+
+// WithReturnMessages is an option for specifying should it return messages.
+func WithReturnMessages(returnMessages bool) Option {
+	return func(b *Memory) {
+		b.ReturnMessages = returnMessages
+	}
+}
+
+// WithInputKey is an option for specifying the input key.
+func WithInputKey(inputKey string) Option {
+	return func(b *Memory) {
+		b.InputKey = inputKey
+	}
+}
+
+// WithOutputKey is an option for specifying the output key.
+func WithOutputKey(outputKey string) Option {
+	return func(b *Memory) {
+		b.OutputKey = outputKey
+	}
+}
+
+// WithHumanPrefix is an option for specifying the human prefix.
+func WithHumanPrefix(humanPrefix string) Option {
+	return func(b *Memory) {
+		b.HumanPrefix = humanPrefix
+	}
+}
+
+// WithAIPrefix is an option for specifying the AI prefix.
+func WithAIPrefix(aiPrefix string) Option {
+	return func(b *Memory) {
+		b.AIPrefix = aiPrefix
+	}
+}
+
+// WithMemoryKey is an option for specifying the memory key.
+func WithMemoryKey(memoryKey string) Option {
+	return func(b *Memory) {
+		b.MemoryKey = memoryKey
+	}
+}
+
+// WithMaxTokens is an option for limiting the loaded history to maxTokens tokens, as counted
+// by tokenizer. The oldest messages are dropped or truncated first.
+func WithMaxTokens(maxTokens int, tokenizer Tokenizer) Option {
+	return func(b *Memory) {
+		b.MaxTokens = maxTokens
+		b.Tokenizer = tokenizer
+	}
+}
+
+// WithSystemMaxTokens is an option for limiting the system messages carrying facts and
+// summaries to their own budget within the one set by WithMaxTokens.
+func WithSystemMaxTokens(systemMaxTokens int) Option {
+	return func(b *Memory) {
+		b.SystemMaxTokens = systemMaxTokens
+	}
+}
+
+// WithOnTrim is an option for specifying a function that is told what was trimmed whenever
+// the loaded history did not fit the budget set by WithMaxTokens.
+func WithOnTrim(onTrim func(ctx context.Context, report TrimReport)) Option {
+	return func(b *Memory) {
+		b.OnTrim = onTrim
+	}
+}
+
+// WithTelemetry is an option for tracing and measuring LoadMemoryVariables and SaveContext.
+func WithTelemetry(telemetry *Telemetry) Option {
+	return func(b *Memory) {
+		b.Telemetry = telemetry
+	}
+}
+
+// ApplyOptions returns a Memory with the default values overridden by the given options.
+// Backends use it to build the Memory they embed.
+func ApplyOptions(opts ...Option) *Memory {
+	m := &Memory{
+		ReturnMessages: true,
+		InputKey:       "",
+		OutputKey:      "",
+		HumanPrefix:    "Human",
+		AIPrefix:       "AI",
+		MemoryKey:      "history",
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
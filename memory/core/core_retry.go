@@ -0,0 +1,258 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Retryability classifies a failed request for a RetryPolicy.
+type Retryability int
+
+const (
+	// NotRetryable errors fail the same way again, like invalid requests or missing sessions.
+	NotRetryable Retryability = iota
+	// RetryableIfIdempotent errors may be transient, but the request may have been processed,
+	// like a 503 or a timeout. Only idempotent requests are retried.
+	RetryableIfIdempotent
+	// Retryable errors show that the request was not processed, like a 429 or a refused
+	// connection, so any request can be retried.
+	Retryable
+)
+
+// ClassifyStatus classifies a failed request by its HTTP status code.
+func ClassifyStatus(statusCode int) Retryability {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusRequestTimeout:
+		return Retryable
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return RetryableIfIdempotent
+	default:
+		return NotRetryable
+	}
+}
+
+// ClassifyNetworkError classifies errors that happened before a response was received.
+// Failing to connect is always retryable, while timeouts and dropped connections are only
+// retryable for idempotent requests. Cancelled contexts and other errors are not retryable.
+func ClassifyNetworkError(err error) Retryability {
+	if errors.Is(err, context.Canceled) {
+		return NotRetryable
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return Retryable
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return RetryableIfIdempotent
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return RetryableIfIdempotent
+	}
+	return NotRetryable
+}
+
+// ClassifyError classifies the errors of backends once they are translated into the errors of
+// core, for callers that do not know the backend, such as the wrappers of histories. Writes
+// that were partly applied are never retryable.
+func ClassifyError(err error) Retryability {
+	var partialErr *PartialWriteError
+	if errors.As(err, &partialErr) {
+		return NotRetryable
+	}
+	if errors.Is(err, ErrRateLimited) {
+		return Retryable
+	}
+	var backendErr *BackendError
+	if errors.As(err, &backendErr) {
+		if backendErr.StatusCode != 0 {
+			return ClassifyStatus(backendErr.StatusCode)
+		}
+		return ClassifyNetworkError(backendErr.Err)
+	}
+	return ClassifyNetworkError(err)
+}
+
+// RetryPolicy retries failed backend requests with exponential backoff and jitter. A nil
+// policy does not retry.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first one.
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry. It grows by Multiplier for every
+	// following retry, up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Jitter is the fraction of every wait that is randomized away, between 0 and 1, so that
+	// clients failing together do not retry together.
+	Jitter float64
+	// MaxRetryAfter is the longest Retry-After the policy waits for. Requests asked to wait
+	// longer fail right away.
+	MaxRetryAfter time.Duration
+	// Classify tells which errors are retryable. The backends default it to a classifier that
+	// knows their errors.
+	Classify func(err error) Retryability
+	// OnRetry, if set, is called before every wait.
+	OnRetry func(ctx context.Context, attempt int, err error, wait time.Duration)
+}
+
+// DefaultRetryPolicy returns a policy of 3 attempts waiting 200ms, then 400ms, with 20% jitter.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		MaxRetryAfter:  30 * time.Second,
+	}
+}
+
+// Do calls fn until it succeeds or fails with an error that is not retryable, MaxAttempts is
+// reached, or ctx is done. Errors classified as RetryableIfIdempotent are only retried if
+// idempotent is set. The error of the last attempt is returned as is.
+//
+// The wait before a retry is at least the Retry-After of the failed response when it is known:
+// either the error has a RetryAfter() time.Duration method, or the request went through a
+// RetryAfterTransport with the context passed to fn.
+func (p *RetryPolicy) Do(ctx context.Context, idempotent bool, fn func(ctx context.Context) error) error {
+	if p == nil {
+		// The slot still lets NewBackendError see the Retry-After of the response.
+		return fn(context.WithValue(ctx, retryAfterKey{}, &retryAfterSlot{}))
+	}
+	for attempt := 1; ; attempt++ {
+		slot := &retryAfterSlot{}
+		err := fn(context.WithValue(ctx, retryAfterKey{}, slot))
+		if err == nil {
+			return nil
+		}
+		if attempt >= p.MaxAttempts || ctx.Err() != nil {
+			return err
+		}
+		classify := p.Classify
+		if classify == nil {
+			classify = ClassifyNetworkError
+		}
+		switch classify(err) {
+		case NotRetryable:
+			return err
+		case RetryableIfIdempotent:
+			if !idempotent {
+				return err
+			}
+		}
+
+		wait := p.backoff(attempt)
+		if after, ok := retryAfter(err, slot); ok {
+			if p.MaxRetryAfter > 0 && after > p.MaxRetryAfter {
+				return err
+			}
+			wait = max(wait, after)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return err
+		}
+		if p.OnRetry != nil {
+			p.OnRetry(ctx, attempt, err, wait)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// backoff returns the wait before the retry following the given attempt.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	wait := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && wait > float64(p.MaxBackoff) {
+		wait = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		wait -= wait * min(p.Jitter, 1) * rand.Float64()
+	}
+	return time.Duration(wait)
+}
+
+type retryAfterKey struct{}
+
+// retryAfterSlot receives the Retry-After of a response from a RetryAfterTransport.
+type retryAfterSlot struct {
+	mu    sync.Mutex
+	after time.Duration
+	ok    bool
+}
+
+func retryAfter(err error, slot *retryAfterSlot) (time.Duration, bool) {
+	var withRetryAfter interface{ RetryAfter() time.Duration }
+	if errors.As(err, &withRetryAfter) {
+		return withRetryAfter.RetryAfter(), true
+	}
+	slot.mu.Lock()
+	defer slot.mu.Unlock()
+	return slot.after, slot.ok
+}
+
+// RetryAfterTransport returns a transport that lets a RetryPolicy see the Retry-After header
+// of failed responses, which the backend clients drop from their errors. Install it in the
+// HTTP client of the zep-go client, e.g. with
+// option.WithHTTPClient(&http.Client{Transport: core.RetryAfterTransport(nil)}). A nil next
+// uses http.DefaultTransport.
+func RetryAfterTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return retryAfterTransport{next: next}
+}
+
+type retryAfterTransport struct {
+	next http.RoundTripper
+}
+
+func (t retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	slot, ok := req.Context().Value(retryAfterKey{}).(*retryAfterSlot)
+	if !ok {
+		return resp, nil
+	}
+	if after, ok := ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+		slot.mu.Lock()
+		slot.after, slot.ok = after, true
+		slot.mu.Unlock()
+	}
+	return resp, nil
+}
+
+// ParseRetryAfter parses a Retry-After header, given either in seconds or as an HTTP date.
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
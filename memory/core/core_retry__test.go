@@ -0,0 +1,246 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// statusError is a failed response for the tests.
+type statusError struct {
+	status int
+	after  time.Duration
+}
+
+func (e *statusError) Error() string { return fmt.Sprintf("status %d", e.status) }
+
+type retryAfterError struct{ statusError }
+
+func (e *retryAfterError) RetryAfter() time.Duration { return e.after }
+
+func classifyStatusError(err error) Retryability {
+	var status *statusError
+	if errors.As(err, &status) {
+		return ClassifyStatus(status.status)
+	}
+	var withRetryAfter *retryAfterError
+	if errors.As(err, &withRetryAfter) {
+		return ClassifyStatus(withRetryAfter.status)
+	}
+	return ClassifyNetworkError(err)
+}
+
+func testPolicy() *RetryPolicy {
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	policy.Classify = classifyStatusError
+	return &policy
+}
+
+// failing returns a function failing with the given errors, then succeeding, and a pointer to
+// the number of calls.
+func failing(errs ...error) (func(context.Context) error, *int) {
+	calls := 0
+	return func(context.Context) error {
+		calls++
+		if calls <= len(errs) {
+			return errs[calls-1]
+		}
+		return nil
+	}, &calls
+}
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+
+	statuses := map[int]Retryability{
+		http.StatusBadRequest:          NotRetryable,
+		http.StatusNotFound:            NotRetryable,
+		http.StatusRequestTimeout:      Retryable,
+		http.StatusTooManyRequests:     Retryable,
+		http.StatusInternalServerError: RetryableIfIdempotent,
+		http.StatusServiceUnavailable:  RetryableIfIdempotent,
+		http.StatusNotImplemented:      NotRetryable,
+	}
+	for status, expected := range statuses {
+		if got := ClassifyStatus(status); got != expected {
+			t.Errorf("Expected %d to be classified %d, got %d", status, expected, got)
+		}
+	}
+
+	errs := map[error]Retryability{
+		context.Canceled: NotRetryable,
+		&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}: Retryable,
+		&net.OpError{Op: "read", Err: syscall.ECONNRESET}:   RetryableIfIdempotent,
+		errors.New("invalid character"):                     NotRetryable,
+	}
+	for err, expected := range errs {
+		if got := ClassifyNetworkError(err); got != expected {
+			t.Errorf("Expected %v to be classified %d, got %d", err, expected, got)
+		}
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	t.Parallel()
+
+	refused := &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}
+	errs := map[error]Retryability{
+		&BackendError{Kind: ErrRateLimited, StatusCode: http.StatusTooManyRequests}:   Retryable,
+		&BackendError{Kind: ErrUnavailable, StatusCode: http.StatusBadGateway}:        RetryableIfIdempotent,
+		&BackendError{Kind: ErrInvalidInput, StatusCode: http.StatusBadRequest}:       NotRetryable,
+		&BackendError{Kind: ErrUnauthorized, StatusCode: http.StatusUnauthorized}:     NotRetryable,
+		&BackendError{Kind: ErrUnavailable, Err: refused}:                             Retryable,
+		&PartialWriteError{Err: &BackendError{Kind: ErrRateLimited, StatusCode: 429}}: NotRetryable,
+		fmt.Errorf("limiter: %w", ErrRateLimited):                                     Retryable,
+		context.DeadlineExceeded: RetryableIfIdempotent,
+		context.Canceled:         NotRetryable,
+	}
+	for err, expected := range errs {
+		if got := ClassifyError(err); got != expected {
+			t.Errorf("Expected %v to be classified %d, got %d", err, expected, got)
+		}
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("NilPolicy", func(t *testing.T) {
+		var policy *RetryPolicy
+		fn, calls := failing(&statusError{status: 503})
+		if err := policy.Do(ctx, true, fn); err == nil || *calls != 1 {
+			t.Errorf("Expected a single call, got %d calls, %v", *calls, err)
+		}
+	})
+
+	t.Run("Idempotent", func(t *testing.T) {
+		fn, calls := failing(&statusError{status: 503}, &statusError{status: 429})
+		if err := testPolicy().Do(ctx, true, fn); err != nil || *calls != 3 {
+			t.Errorf("Expected success on the third attempt, got %d calls, %v", *calls, err)
+		}
+	})
+
+	t.Run("NotIdempotent", func(t *testing.T) {
+		fn, calls := failing(&statusError{status: 503})
+		if err := testPolicy().Do(ctx, false, fn); err == nil || *calls != 1 {
+			t.Errorf("Expected a 503 not to be retried, got %d calls, %v", *calls, err)
+		}
+		fn, calls = failing(&statusError{status: 429})
+		if err := testPolicy().Do(ctx, false, fn); err != nil || *calls != 2 {
+			t.Errorf("Expected a 429 to be retried, got %d calls, %v", *calls, err)
+		}
+	})
+
+	t.Run("MaxAttempts", func(t *testing.T) {
+		last := &statusError{status: 500}
+		fn, calls := failing(&statusError{status: 500}, &statusError{status: 500}, last)
+		if err := testPolicy().Do(ctx, true, fn); err != last || *calls != 3 {
+			t.Errorf("Expected the last error after 3 attempts, got %d calls, %v", *calls, err)
+		}
+	})
+
+	t.Run("NotRetryable", func(t *testing.T) {
+		fn, calls := failing(&statusError{status: 400})
+		if err := testPolicy().Do(ctx, true, fn); err == nil || *calls != 1 {
+			t.Errorf("Expected a 400 not to be retried, got %d calls, %v", *calls, err)
+		}
+	})
+
+	t.Run("RetryAfterError", func(t *testing.T) {
+		policy := testPolicy()
+		var waits []time.Duration
+		policy.OnRetry = func(_ context.Context, _ int, _ error, wait time.Duration) {
+			waits = append(waits, wait)
+		}
+		fn, _ := failing(&retryAfterError{statusError{status: 429, after: 20 * time.Millisecond}})
+		if err := policy.Do(ctx, false, fn); err != nil {
+			t.Fatalf("Do: %v", err)
+		}
+		if len(waits) != 1 || waits[0] != 20*time.Millisecond {
+			t.Errorf("Expected to wait for the Retry-After, got %v", waits)
+		}
+	})
+
+	t.Run("Deadline", func(t *testing.T) {
+		policy := testPolicy()
+		policy.InitialBackoff = time.Hour
+		ctx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		fn, calls := failing(&statusError{status: 503})
+		start := time.Now()
+		if err := policy.Do(ctx, true, fn); err == nil || *calls != 1 || time.Since(start) > 500*time.Millisecond {
+			t.Errorf("Expected to give up at once when the wait outlasts the deadline, got %d calls, %v", *calls, err)
+		}
+	})
+
+	t.Run("Backoff", func(t *testing.T) {
+		policy := DefaultRetryPolicy()
+		policy.Jitter = 0
+		if got := policy.backoff(1); got != 200*time.Millisecond {
+			t.Errorf("Expected 200ms, got %v", got)
+		}
+		if got := policy.backoff(3); got != 800*time.Millisecond {
+			t.Errorf("Expected 800ms, got %v", got)
+		}
+		if got := policy.backoff(20); got != 5*time.Second {
+			t.Errorf("Expected the backoff to be capped at 5s, got %v", got)
+		}
+		policy.Jitter = 0.5
+		for i := 0; i < 100; i++ {
+			if got := policy.backoff(1); got < 100*time.Millisecond || got > 200*time.Millisecond {
+				t.Fatalf("Expected a jittered backoff between 100ms and 200ms, got %v", got)
+			}
+		}
+	})
+}
+
+func TestRetryAfterTransport(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: RetryAfterTransport(nil)}
+
+	policy := testPolicy()
+	policy.MaxRetryAfter = time.Second
+	calls := 0
+	err := policy.Do(context.Background(), false, func(ctx context.Context) error {
+		calls++
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return &statusError{status: resp.StatusCode}
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("Expected a Retry-After over MaxRetryAfter to fail at once, got %d calls, %v", calls, err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if d, ok := ParseRetryAfter("3", now); !ok || d != 3*time.Second {
+		t.Errorf("Expected 3s, got %v, %v", d, ok)
+	}
+	if d, ok := ParseRetryAfter("Mon, 01 Jan 2024 00:00:10 GMT", now); !ok || d != 10*time.Second {
+		t.Errorf("Expected 10s, got %v, %v", d, ok)
+	}
+	if _, ok := ParseRetryAfter("soon", now); ok {
+		t.Errorf("Expected an invalid Retry-After to be ignored")
+	}
+}
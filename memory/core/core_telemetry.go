@@ -0,0 +1,194 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+)
+
+// instrumentationName is the OpenTelemetry instrumentation scope of the memories.
+const instrumentationName = "github.com/0xDezzy/langchaingo-memory/memory"
+
+// Attributes of the memory spans and metrics.
+const (
+	AttributeBackend       = attribute.Key("memory.backend")
+	AttributeOperation     = attribute.Key("memory.operation")
+	AttributeUserHash      = attribute.Key("memory.user.hash")
+	AttributeSessionHash   = attribute.Key("memory.session.hash")
+	AttributeMessagesCount = attribute.Key("memory.messages.count")
+	AttributePayloadSize   = attribute.Key("memory.payload.size")
+	AttributeFactsCount    = attribute.Key("memory.facts.count")
+	AttributeError         = attribute.Key("memory.error")
+)
+
+// Telemetry traces and measures the operations of a memory backend with OpenTelemetry. Every
+// operation gets a span named after the backend and the operation, carrying hashes of the user
+// and session, the number and size of the messages and the error status. The latency and
+// payload size of operations are recorded in the memory.operation.duration and
+// memory.payload.size histograms, and the facts they return in the memory.facts.returned
+// counter. A nil Telemetry records nothing.
+type Telemetry struct {
+	backend    string
+	tracer     trace.Tracer
+	duration   metric.Float64Histogram
+	payload    metric.Int64Histogram
+	facts      metric.Int64Counter
+	attributes []attribute.KeyValue
+}
+
+// NewTelemetry creates the telemetry of a backend, such as "mem0" or "graphiti". Nil providers
+// record nothing.
+func NewTelemetry(backend string, tracerProvider trace.TracerProvider, meterProvider metric.MeterProvider) *Telemetry {
+	if tracerProvider == nil {
+		tracerProvider = tracenoop.NewTracerProvider()
+	}
+	if meterProvider == nil {
+		meterProvider = metricnoop.NewMeterProvider()
+	}
+	meter := meterProvider.Meter(instrumentationName)
+	t := &Telemetry{
+		backend:    backend,
+		tracer:     tracerProvider.Tracer(instrumentationName),
+		attributes: []attribute.KeyValue{AttributeBackend.String(backend)},
+	}
+
+	var err error
+	t.duration, err = meter.Float64Histogram("memory.operation.duration",
+		metric.WithDescription("Duration of memory operations."), metric.WithUnit("s"))
+	if err != nil {
+		otel.Handle(err)
+		t.duration = metricnoop.Float64Histogram{}
+	}
+	t.payload, err = meter.Int64Histogram("memory.payload.size",
+		metric.WithDescription("Size of the message contents loaded or saved by memory operations."), metric.WithUnit("By"))
+	if err != nil {
+		otel.Handle(err)
+		t.payload = metricnoop.Int64Histogram{}
+	}
+	t.facts, err = meter.Int64Counter("memory.facts.returned",
+		metric.WithDescription("Facts returned by memory operations."), metric.WithUnit("{fact}"))
+	if err != nil {
+		otel.Handle(err)
+		t.facts = metricnoop.Int64Counter{}
+	}
+	return t
+}
+
+// Identify returns a copy of the telemetry whose spans carry hashes of the user and session.
+// Empty identifiers are left out. The hashes are unsalted, so that the traces of a user can be
+// found across processes; they keep identifiers out of traces, but do not make them anonymous.
+func (t *Telemetry) Identify(userID, sessionID string) *Telemetry {
+	if t == nil {
+		return nil
+	}
+	identified := *t
+	identified.attributes = append(append([]attribute.KeyValue(nil), t.attributes...),
+		identityAttributes(userID, sessionID)...)
+	return &identified
+}
+
+// identityAttributes returns the attributes of the hashes of the non-empty identifiers.
+func identityAttributes(userID, sessionID string) []attribute.KeyValue {
+	var attributes []attribute.KeyValue
+	if userID != "" {
+		attributes = append(attributes, AttributeUserHash.String(hash(userID)))
+	}
+	if sessionID != "" {
+		attributes = append(attributes, AttributeSessionHash.String(hash(sessionID)))
+	}
+	return attributes
+}
+
+func hash(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Start starts the span of an operation, such as "Messages" or "SaveContext". The returned
+// Operation must be ended.
+func (t *Telemetry) Start(ctx context.Context, operation string) (context.Context, *Operation) {
+	if t == nil {
+		return ctx, nil
+	}
+	attributes := append(append([]attribute.KeyValue(nil), t.attributes...), AttributeOperation.String(operation))
+	ctx, span := t.tracer.Start(ctx, t.backend+"."+operation,
+		trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+	return ctx, &Operation{
+		telemetry: t,
+		span:      span,
+		operation: operation,
+		start:     time.Now(),
+	}
+}
+
+// Operation is an operation in progress. A nil Operation records nothing.
+type Operation struct {
+	telemetry *Telemetry
+	span      trace.Span
+	operation string
+	start     time.Time
+	payload   int64
+	recorded  bool
+}
+
+// Identify adds hashes of the user and session to the span of the operation, for operations
+// whose identifiers are resolved per request rather than known to the Telemetry.
+func (o *Operation) Identify(userID, sessionID string) {
+	if o == nil {
+		return
+	}
+	o.span.SetAttributes(identityAttributes(userID, sessionID)...)
+}
+
+// RecordMessages records the messages loaded or saved by the operation.
+func (o *Operation) RecordMessages(messages []llms.ChatMessage) {
+	if o == nil {
+		return
+	}
+	for _, message := range messages {
+		o.payload += int64(len(message.GetContent()))
+	}
+	o.recorded = true
+	o.span.SetAttributes(AttributeMessagesCount.Int(len(messages)), AttributePayloadSize.Int64(o.payload))
+}
+
+// RecordFacts records the facts returned by the operation.
+func (o *Operation) RecordFacts(ctx context.Context, facts int) {
+	if o == nil {
+		return
+	}
+	o.span.SetAttributes(AttributeFactsCount.Int(facts))
+	o.telemetry.facts.Add(ctx, int64(facts), metric.WithAttributes(o.metricAttributes()...))
+}
+
+// End ends the span of the operation with the status of err and records its metrics.
+func (o *Operation) End(ctx context.Context, err error) {
+	if o == nil {
+		return
+	}
+	if err != nil {
+		o.span.RecordError(err)
+		o.span.SetStatus(codes.Error, err.Error())
+	}
+	attributes := metric.WithAttributes(append(o.metricAttributes(), AttributeError.Bool(err != nil))...)
+	o.telemetry.duration.Record(ctx, time.Since(o.start).Seconds(), attributes)
+	if o.recorded {
+		o.telemetry.payload.Record(ctx, o.payload, attributes)
+	}
+	o.span.End()
+}
+
+// metricAttributes leaves out the user and session, which would make too many time series.
+func (o *Operation) metricAttributes() []attribute.KeyValue {
+	return []attribute.KeyValue{AttributeBackend.String(o.telemetry.backend), AttributeOperation.String(o.operation)}
+}
@@ -0,0 +1,136 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/memory"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newTestTelemetry returns telemetry recording to an in-memory span recorder and metric
+// reader.
+func newTestTelemetry(t *testing.T, backend string) (*Telemetry, *tracetest.SpanRecorder, *sdkmetric.ManualReader) {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	reader := sdkmetric.NewManualReader()
+	telemetry := NewTelemetry(backend,
+		sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)),
+		sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	return telemetry, recorder, reader
+}
+
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTelemetry(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	telemetry, recorder, reader := newTestTelemetry(t, "test")
+	m := ApplyOptions(WithTelemetry(telemetry.Identify("sarah", "session")))
+	m.ChatHistory = memory.NewChatMessageHistory()
+	if err := m.SaveContext(ctx, map[string]any{"input": "hello"}, map[string]any{"output": "hi"}); err != nil {
+		t.Fatalf("SaveContext: %v", err)
+	}
+	if _, err := m.LoadMemoryVariables(ctx, nil); err != nil {
+		t.Fatalf("LoadMemoryVariables: %v", err)
+	}
+	if err := m.SaveContext(ctx, map[string]any{}, map[string]any{}); err == nil {
+		t.Fatalf("Expected SaveContext to fail without input")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("Expected 3 spans, got %d", len(spans))
+	}
+	load := spans[1]
+	if load.Name() != "test.LoadMemoryVariables" {
+		t.Errorf("Expected the span of LoadMemoryVariables, got %s", load.Name())
+	}
+	if got := spanAttribute(load, AttributeMessagesCount).AsInt64(); got != 2 {
+		t.Errorf("Expected 2 messages, got %d", got)
+	}
+	if got := spanAttribute(load, AttributePayloadSize).AsInt64(); got != int64(len("hello")+len("hi")) {
+		t.Errorf("Expected the payload size of the contents, got %d", got)
+	}
+	if got := spanAttribute(load, AttributeUserHash).AsString(); got == "" || got == "sarah" || got != hash("sarah") {
+		t.Errorf("Expected the user to be hashed, got %q", got)
+	}
+	if spans[2].Status().Code != codes.Error {
+		t.Errorf("Expected the failed span to have the error status, got %v", spans[2].Status())
+	}
+
+	var metrics metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &metrics); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	found := map[string]bool{}
+	for _, scope := range metrics.ScopeMetrics {
+		for _, metric := range scope.Metrics {
+			found[metric.Name] = true
+			if metric.Name != "memory.operation.duration" {
+				continue
+			}
+			histogram := metric.Data.(metricdata.Histogram[float64])
+			var count uint64
+			for _, point := range histogram.DataPoints {
+				if _, ok := point.Attributes.Value(AttributeUserHash); ok {
+					t.Errorf("Expected no user attribute on metrics")
+				}
+				count += point.Count
+			}
+			if count != 3 {
+				t.Errorf("Expected 3 durations, got %d", count)
+			}
+		}
+	}
+	if !found["memory.operation.duration"] || !found["memory.payload.size"] {
+		t.Errorf("Expected the duration and payload size histograms, got %v", found)
+	}
+}
+
+func TestTelemetryNil(t *testing.T) {
+	t.Parallel()
+
+	var telemetry *Telemetry
+	ctx, op := telemetry.Identify("sarah", "").Start(context.Background(), "Messages")
+	op.RecordMessages([]llms.ChatMessage{llms.HumanChatMessage{Content: "hello"}})
+	op.RecordFacts(ctx, 1)
+	op.End(ctx, errors.New("failed"))
+}
+
+func TestSaveAndLoadContextTelemetry(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	telemetry, recorder, _ := newTestTelemetry(t, "test")
+	m := ApplyOptions(WithTelemetry(telemetry))
+	m.ChatHistory = memory.NewChatMessageHistory()
+	if _, err := m.SaveAndLoadContext(ctx, map[string]any{"input": "hello"}, map[string]any{"output": "hi"}); err != nil {
+		t.Fatalf("SaveAndLoadContext: %v", err)
+	}
+
+	spans := recorder.Ended()
+	last := spans[len(spans)-1]
+	if last.Name() != "test.SaveAndLoadContext" {
+		t.Fatalf("Expected the span of SaveAndLoadContext to end last, got %s", last.Name())
+	}
+	if got := spanAttribute(last, AttributeMessagesCount).AsInt64(); got != 2 {
+		t.Errorf("Expected the 2 returned messages, got %d", got)
+	}
+}
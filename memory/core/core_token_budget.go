@@ -0,0 +1,188 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkoukk/tiktoken-go"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// Tokenizer counts the tokens of a text the way the target model does.
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+// TokenizerFunc adapts a function to the Tokenizer interface.
+type TokenizerFunc func(text string) int
+
+// CountTokens calls f.
+func (f TokenizerFunc) CountTokens(text string) int {
+	return f(text)
+}
+
+// ModelTokenizer counts tokens with the tiktoken encoding of the given model, falling back to
+// an approximation when the encoding is not available, like llms.CountTokens.
+func ModelTokenizer(model string) Tokenizer {
+	return TokenizerFunc(func(text string) int {
+		return llms.CountTokens(model, text)
+	})
+}
+
+// TiktokenTokenizer counts tokens with the named tiktoken encoding, e.g. "cl100k_base". The
+// encoding is downloaded on first use unless it is already cached.
+func TiktokenTokenizer(encoding string) (Tokenizer, error) {
+	e, err := tiktoken.GetEncoding(encoding)
+	if err != nil {
+		return nil, err
+	}
+	return TokenizerFunc(func(text string) int {
+		return len(e.Encode(text, nil, nil))
+	}), nil
+}
+
+// TrimReport describes what LoadMemoryVariables trimmed to fit the token budget.
+type TrimReport struct {
+	// DroppedMessages is the number of conversation messages left out entirely.
+	DroppedMessages int
+	// DroppedSystemMessages is the number of system messages, carrying facts and summaries,
+	// left out entirely.
+	DroppedSystemMessages int
+	// TruncatedMessages is the number of messages, including system messages, whose content
+	// was cut short.
+	TruncatedMessages int
+	// DroppedTokens is the number of tokens left out.
+	DroppedTokens int
+}
+
+// trim fits messages into the token budget of the memory. System messages carrying facts and
+// summaries are kept first, truncated to the system budget; the rest of the budget is filled
+// with the newest conversation messages, and the oldest message that only partly fits keeps
+// its most recent content.
+func (m *Memory) trim(ctx context.Context, messages []llms.ChatMessage) []llms.ChatMessage {
+	if m.MaxTokens <= 0 || m.Tokenizer == nil {
+		return messages
+	}
+
+	var report TrimReport
+	systemBudget := m.MaxTokens
+	if m.SystemMaxTokens > 0 && m.SystemMaxTokens < systemBudget {
+		systemBudget = m.SystemMaxTokens
+	}
+	var system, conversation []llms.ChatMessage
+	used := 0
+	for _, message := range messages {
+		if message.GetType() != llms.ChatMessageTypeSystem {
+			conversation = append(conversation, message)
+			continue
+		}
+		tokens := m.Tokenizer.CountTokens(message.GetContent())
+		if used+tokens <= systemBudget {
+			system = append(system, message)
+			used += tokens
+			continue
+		}
+		if remaining := systemBudget - used; remaining > 0 {
+			content := truncate(m.Tokenizer, message.GetContent(), remaining, false)
+			kept := m.Tokenizer.CountTokens(content)
+			if content == "" {
+				report.DroppedSystemMessages++
+				report.DroppedTokens += tokens
+				continue
+			}
+			system = append(system, withContent(message, content))
+			used += kept
+			report.TruncatedMessages++
+			report.DroppedTokens += tokens - kept
+			continue
+		}
+		report.DroppedSystemMessages++
+		report.DroppedTokens += tokens
+	}
+
+	kept := make([]llms.ChatMessage, 0, len(conversation))
+	i := len(conversation) - 1
+	for ; i >= 0; i-- {
+		tokens := m.Tokenizer.CountTokens(conversation[i].GetContent())
+		if used+tokens > m.MaxTokens {
+			break
+		}
+		kept = append(kept, conversation[i])
+		used += tokens
+	}
+	if remaining := m.MaxTokens - used; i >= 0 && remaining > 0 {
+		content := truncate(m.Tokenizer, conversation[i].GetContent(), remaining, true)
+		if content != "" {
+			kept = append(kept, withContent(conversation[i], content))
+			report.TruncatedMessages++
+			report.DroppedTokens += m.Tokenizer.CountTokens(conversation[i].GetContent()) - m.Tokenizer.CountTokens(content)
+			i--
+		}
+	}
+	for ; i >= 0; i-- {
+		report.DroppedTokens += m.Tokenizer.CountTokens(conversation[i].GetContent())
+		report.DroppedMessages++
+	}
+
+	trimmed := make([]llms.ChatMessage, 0, len(system)+len(kept))
+	trimmed = append(trimmed, system...)
+	for j := len(kept) - 1; j >= 0; j-- {
+		trimmed = append(trimmed, kept[j])
+	}
+	if m.OnTrim != nil && (report.DroppedMessages > 0 || report.DroppedSystemMessages > 0 || report.TruncatedMessages > 0) {
+		m.OnTrim(ctx, report)
+	}
+	return trimmed
+}
+
+// truncate returns the longest beginning of text, or end of text if keepEnd is set, that
+// fits in budget tokens.
+func truncate(tokenizer Tokenizer, text string, budget int, keepEnd bool) string {
+	cut := func(n int) string {
+		if keepEnd {
+			return text[len(text)-n:]
+		}
+		return text[:n]
+	}
+	low, high := 0, len(text)
+	for low < high {
+		mid := (low + high + 1) / 2
+		if tokenizer.CountTokens(cut(mid)) <= budget {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+	// Do not split a multi-byte character.
+	for low > 0 && !utf8.ValidString(cut(low)) {
+		low--
+	}
+	return strings.TrimSpace(cut(low))
+}
+
+// withContent returns a copy of message with its content replaced.
+func withContent(message llms.ChatMessage, content string) llms.ChatMessage {
+	switch m := message.(type) {
+	case llms.HumanChatMessage:
+		m.Content = content
+		return m
+	case llms.AIChatMessage:
+		m.Content = content
+		return m
+	case llms.SystemChatMessage:
+		m.Content = content
+		return m
+	case llms.ToolChatMessage:
+		m.Content = content
+		return m
+	case llms.FunctionChatMessage:
+		m.Content = content
+		return m
+	case llms.GenericChatMessage:
+		m.Content = content
+		return m
+	default:
+		return llms.GenericChatMessage{Role: string(message.GetType()), Content: content}
+	}
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// wordTokenizer counts one token per word.
+var wordTokenizer = TokenizerFunc(func(text string) int {
+	return len(strings.Fields(text))
+})
+
+func budgetHistory() *mockChatHistory {
+	return &mockChatHistory{
+		messages: []llms.ChatMessage{
+			llms.SystemChatMessage{Content: "Sarah lives in Berlin"},
+			llms.HumanChatMessage{Content: "one two three"},
+			llms.AIChatMessage{Content: "four five six"},
+			llms.HumanChatMessage{Content: "seven eight nine"},
+			llms.AIChatMessage{Content: "ten eleven twelve"},
+		},
+	}
+}
+
+func loadBudgeted(t *testing.T, options ...Option) ([]llms.ChatMessage, []TrimReport) {
+	t.Helper()
+
+	var reports []TrimReport
+	options = append(options, WithOnTrim(func(_ context.Context, report TrimReport) {
+		reports = append(reports, report)
+	}))
+	m := ApplyOptions(options...)
+	m.ChatHistory = budgetHistory()
+	result, err := m.LoadMemoryVariables(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return result["history"].([]llms.ChatMessage), reports
+}
+
+func contents(messages []llms.ChatMessage) []string {
+	var c []string
+	for _, message := range messages {
+		c = append(c, message.GetContent())
+	}
+	return c
+}
+
+func TestMaxTokens(t *testing.T) {
+	t.Parallel()
+
+	t.Run("WithinBudget", func(t *testing.T) {
+		messages, reports := loadBudgeted(t, WithMaxTokens(100, wordTokenizer))
+		if len(messages) != 5 || len(reports) != 0 {
+			t.Errorf("Expected the history unchanged and no report, got %v and %v", contents(messages), reports)
+		}
+	})
+
+	t.Run("DropsOldestTurns", func(t *testing.T) {
+		messages, reports := loadBudgeted(t, WithMaxTokens(10, wordTokenizer))
+		expected := []string{"Sarah lives in Berlin", "seven eight nine", "ten eleven twelve"}
+		if strings.Join(contents(messages), "|") != strings.Join(expected, "|") {
+			t.Errorf("Expected %v, got %v", expected, contents(messages))
+		}
+		if len(reports) != 1 || reports[0] != (TrimReport{DroppedMessages: 2, DroppedTokens: 6}) {
+			t.Errorf("Expected 2 dropped messages and 6 dropped tokens, got %+v", reports)
+		}
+	})
+
+	t.Run("TruncatesBoundaryMessage", func(t *testing.T) {
+		messages, reports := loadBudgeted(t, WithMaxTokens(12, wordTokenizer))
+		if len(messages) != 4 {
+			t.Fatalf("Expected system, a truncated message and 2 turns, got %v", contents(messages))
+		}
+		if messages[1].GetType() != llms.ChatMessageTypeAI || messages[1].GetContent() != "five six" {
+			t.Errorf("Expected the end of the AI message to be kept, got %s %q", messages[1].GetType(), messages[1].GetContent())
+		}
+		if len(reports) != 1 || reports[0] != (TrimReport{DroppedMessages: 1, TruncatedMessages: 1, DroppedTokens: 4}) {
+			t.Errorf("Expected 1 dropped and 1 truncated message, got %+v", reports)
+		}
+	})
+
+	t.Run("SystemBudget", func(t *testing.T) {
+		messages, _ := loadBudgeted(t, WithMaxTokens(8, wordTokenizer), WithSystemMaxTokens(2))
+		if messages[0].GetContent() != "Sarah lives" {
+			t.Errorf("Expected the system message to be cut to its budget, got %q", messages[0].GetContent())
+		}
+		if strings.Join(contents(messages[1:]), "|") != "seven eight nine|ten eleven twelve" {
+			t.Errorf("Expected the rest of the budget to go to the newest turns, got %v", contents(messages[1:]))
+		}
+	})
+
+	t.Run("DropsSystemMessages", func(t *testing.T) {
+		m := ApplyOptions(WithMaxTokens(20, wordTokenizer), WithSystemMaxTokens(4))
+		var reports []TrimReport
+		m.OnTrim = func(_ context.Context, report TrimReport) {
+			reports = append(reports, report)
+		}
+		history := budgetHistory()
+		history.messages = append([]llms.ChatMessage{history.messages[0], llms.SystemChatMessage{Content: "Summary"}}, history.messages[1:]...)
+		m.ChatHistory = history
+		result, err := m.LoadMemoryVariables(context.Background(), nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if messages := result["history"].([]llms.ChatMessage); len(messages) != 5 {
+			t.Errorf("Expected the second system message to be left out, got %v", contents(messages))
+		}
+		if len(reports) != 1 || reports[0] != (TrimReport{DroppedSystemMessages: 1, DroppedTokens: 1}) {
+			t.Errorf("Expected a report of the dropped system message, got %+v", reports)
+		}
+	})
+
+	t.Run("BufferString", func(t *testing.T) {
+		m := ApplyOptions(WithReturnMessages(false), WithMaxTokens(7, wordTokenizer))
+		m.ChatHistory = budgetHistory()
+		result, err := m.LoadMemoryVariables(context.Background(), nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if buffer := result["history"].(string); strings.Contains(buffer, "nine") || !strings.Contains(buffer, "twelve") {
+			t.Errorf("Expected only the newest turn in the buffer, got %q", buffer)
+		}
+	})
+}
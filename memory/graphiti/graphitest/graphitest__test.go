@@ -0,0 +1,184 @@
+package graphitest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/graphiti"
+	"github.com/0xDezzy/langchaingo-memory/memory/memorytest"
+	"github.com/getzep/zep-go"
+	zepClient "github.com/getzep/zep-go/client"
+	"github.com/getzep/zep-go/core"
+	"github.com/getzep/zep-go/option"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func newTestServer(t *testing.T, options ...ServerOption) *Server {
+	t.Helper()
+
+	s := NewServer(options...)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestServerUsersAndSessions(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	client := s.NewClient()
+	ctx := context.Background()
+
+	if _, err := client.User.Add(ctx, &zep.CreateUserRequest{UserID: zep.String("sarah")}); err != nil {
+		t.Fatalf("User.Add: %v", err)
+	}
+	_, err := client.Memory.AddSession(ctx, &zep.CreateSessionRequest{SessionID: "s1", UserID: zep.String("sarah")})
+	if err != nil {
+		t.Fatalf("Memory.AddSession: %v", err)
+	}
+	session, err := client.Memory.GetSession(ctx, "s1")
+	if err != nil {
+		t.Fatalf("Memory.GetSession: %v", err)
+	}
+	if session.UserID == nil || *session.UserID != "sarah" {
+		t.Errorf("Expected session for sarah, got %+v", session)
+	}
+	user, err := client.User.Get(ctx, "sarah")
+	if err != nil {
+		t.Fatalf("User.Get: %v", err)
+	}
+	if user.SessionCount == nil || *user.SessionCount != 1 {
+		t.Errorf("Expected 1 session for sarah, got %v", user.SessionCount)
+	}
+
+	if _, err := client.User.Delete(ctx, "sarah"); err != nil {
+		t.Fatalf("User.Delete: %v", err)
+	}
+	var notFound *zep.NotFoundError
+	if _, err := client.Memory.GetSession(ctx, "s1"); !errors.As(err, &notFound) {
+		t.Errorf("Expected the user's sessions to be deleted, got %v", err)
+	}
+}
+
+func TestServerMemory(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	ctx := context.Background()
+	history := graphiti.NewZepChatMessageHistory(s.NewClient(), "s1")
+
+	if err := history.AddUserMessage(ctx, "I moved to Berlin"); err != nil {
+		t.Fatalf("AddUserMessage: %v", err)
+	}
+	if err := history.AddAIMessage(ctx, "Welcome to Berlin!"); err != nil {
+		t.Fatalf("AddAIMessage: %v", err)
+	}
+	s.SetFacts("s1", "Sarah lives in Berlin")
+	s.SetSummary("s1", "Sarah talked about moving.")
+
+	messages, err := history.Messages(ctx)
+	if err != nil {
+		t.Fatalf("Messages: %v", err)
+	}
+	if len(messages) != 3 || messages[0].GetType() != llms.ChatMessageTypeSystem {
+		t.Fatalf("Expected a system message followed by 2 messages, got %v", messages)
+	}
+	expected := "Sarah lives in Berlin\nSarah talked about moving.\n"
+	if messages[0].GetContent() != expected {
+		t.Errorf("Expected facts and summary %q, got %q", expected, messages[0].GetContent())
+	}
+	if stored := s.Messages("s1"); len(stored) != 2 || *stored[0].UUID != "message-0002" {
+		t.Errorf("Expected 2 stored messages with deterministic UUIDs, got %+v", stored)
+	}
+
+	lastn, err := s.NewClient().Memory.Get(ctx, "s1", &zep.MemoryGetRequest{Lastn: zep.Int(1)})
+	if err != nil {
+		t.Fatalf("Memory.Get: %v", err)
+	}
+	if len(lastn.Messages) != 1 || *lastn.Messages[0].Content != "Welcome to Berlin!" {
+		t.Errorf("Expected only the last message, got %+v", lastn.Messages)
+	}
+}
+
+func TestServerStrictSessions(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t, WithStrictSessions())
+	err := graphiti.NewZepChatMessageHistory(s.NewClient(), "unknown").AddUserMessage(context.Background(), "hi")
+	var apiError *core.APIError
+	if !errors.As(err, &apiError) || apiError.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for a session that was not created, got %v", err)
+	}
+}
+
+func TestServerGraph(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t)
+	s.SetUserGraph("sarah", "n1",
+		[]Node{{UUID: "n1", Name: "Sarah"}, {UUID: "n2", Name: "TechCorp"}, {UUID: "n3", Name: "Berlin"}},
+		[]Edge{
+			{UUID: "e1", Name: "WORKS_AT", SourceNodeUUID: "n1", TargetNodeUUID: "n2"},
+			{UUID: "e2", Name: "LOCATED_IN", SourceNodeUUID: "n2", TargetNodeUUID: "n3"},
+		})
+	s.SetGroupGraph("team", []Node{{UUID: "g1", Name: "Team"}}, nil)
+	graph := graphiti.NewGraphClient(s.ClientOptions()...)
+	ctx := context.Background()
+
+	paths, err := graph.Walk(ctx, "sarah", 2)
+	if err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+	if len(paths) != 2 || paths[1].String() != "Sarah -[WORKS_AT]-> TechCorp -[LOCATED_IN]-> Berlin" {
+		t.Errorf("Expected a two hop path to Berlin, got %v", paths)
+	}
+
+	userGraph, err := graph.UserGraph(ctx, "sarah")
+	if err != nil {
+		t.Fatalf("UserGraph: %v", err)
+	}
+	if len(userGraph.Nodes) != 3 || len(userGraph.Edges) != 2 {
+		t.Errorf("Expected 3 nodes and 2 edges, got %d and %d", len(userGraph.Nodes), len(userGraph.Edges))
+	}
+	groupGraph, err := graph.GroupGraph(ctx, "team")
+	if err != nil {
+		t.Fatalf("GroupGraph: %v", err)
+	}
+	if len(groupGraph.Nodes) != 1 {
+		t.Errorf("Expected the group graph to be separate, got %d nodes", len(groupGraph.Nodes))
+	}
+
+	err = graph.AddEpisode(ctx, graphiti.Episode{UserID: "sarah", Type: graphiti.EpisodeTypeText, Data: "notes"})
+	if err != nil {
+		t.Fatalf("AddEpisode: %v", err)
+	}
+	if episodes := s.Episodes(); len(episodes) != 1 || episodes[0].Data != "notes" {
+		t.Errorf("Expected the episode to be recorded, got %+v", episodes)
+	}
+}
+
+func TestServerFaults(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer(t, WithFault(memorytest.Fault{
+		Method:     http.MethodGet,
+		Path:       "/sessions/",
+		StatusCode: http.StatusInternalServerError,
+		Times:      1,
+	}))
+	client := zepClient.NewClient(append(s.ClientOptions(), option.WithMaxAttempts(1))...)
+	history := graphiti.NewZepChatMessageHistory(client, "s1")
+	ctx := context.Background()
+
+	var serverError *zep.InternalServerError
+	if _, err := history.Messages(ctx); !errors.As(err, &serverError) {
+		t.Errorf("Expected the injected 500, got %v", err)
+	}
+	if _, err := history.Messages(ctx); err != nil {
+		t.Errorf("Expected the fault to be used up, got %v", err)
+	}
+	if count := s.RequestCount(http.MethodGet, "/sessions/s1/memory"); count != 2 {
+		t.Errorf("Expected 2 requests, got %d", count)
+	}
+}
@@ -0,0 +1,303 @@
+package graphitest
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// Node is an entity node served by the fake graph endpoints. It has the same JSON shape as
+// graphiti.Node.
+type Node struct {
+	UUID       string         `json:"uuid"`
+	Name       string         `json:"name"`
+	Summary    string         `json:"summary,omitempty"`
+	Labels     []string       `json:"labels,omitempty"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+	CreatedAt  *time.Time     `json:"created_at,omitempty"`
+}
+
+// Edge is a fact served by the fake graph endpoints. It has the same JSON shape as
+// graphiti.Edge.
+type Edge struct {
+	UUID           string         `json:"uuid"`
+	Name           string         `json:"name"`
+	Fact           string         `json:"fact"`
+	SourceNodeUUID string         `json:"source_node_uuid"`
+	TargetNodeUUID string         `json:"target_node_uuid"`
+	Episodes       []string       `json:"episodes,omitempty"`
+	Attributes     map[string]any `json:"attributes,omitempty"`
+	CreatedAt      *time.Time     `json:"created_at,omitempty"`
+	ValidAt        *time.Time     `json:"valid_at,omitempty"`
+	InvalidAt      *time.Time     `json:"invalid_at,omitempty"`
+	ExpiredAt      *time.Time     `json:"expired_at,omitempty"`
+}
+
+// Episode is an episode added to the fake graph. It has the same JSON shape as
+// graphiti.Episode.
+type Episode struct {
+	UserID            string `json:"user_id,omitempty"`
+	GroupID           string `json:"group_id,omitempty"`
+	Type              string `json:"type"`
+	Data              string `json:"data"`
+	SourceDescription string `json:"source_description,omitempty"`
+}
+
+type ownerKind int
+
+const (
+	userOwner ownerKind = iota
+	groupOwner
+)
+
+// owner identifies the user or group a graph belongs to.
+type owner struct {
+	kind ownerKind
+	id   string
+}
+
+// ownedGraph lists the nodes and edges of one owner in the order they were set.
+type ownedGraph struct {
+	nodes []string
+	edges []string
+}
+
+// graph holds the nodes and edges of every user and group graph.
+type graph struct {
+	nodes     map[string]Node
+	edges     map[string]Edge
+	owned     map[owner]*ownedGraph
+	userNodes map[string]string
+	episodes  []Episode
+}
+
+func newGraph() graph {
+	return graph{
+		nodes:     map[string]Node{},
+		edges:     map[string]Edge{},
+		owned:     map[owner]*ownedGraph{},
+		userNodes: map[string]string{},
+	}
+}
+
+func (g *graph) set(o owner, nodes []Node, edges []Edge) {
+	g.deleteOwner(o)
+	owned := &ownedGraph{}
+	for _, n := range nodes {
+		g.nodes[n.UUID] = n
+		owned.nodes = append(owned.nodes, n.UUID)
+	}
+	for _, e := range edges {
+		g.edges[e.UUID] = e
+		owned.edges = append(owned.edges, e.UUID)
+	}
+	g.owned[o] = owned
+}
+
+func (g *graph) deleteOwner(o owner) {
+	owned, ok := g.owned[o]
+	if !ok {
+		return
+	}
+	for _, uuid := range owned.nodes {
+		delete(g.nodes, uuid)
+	}
+	for _, uuid := range owned.edges {
+		delete(g.edges, uuid)
+	}
+	delete(g.owned, o)
+	if o.kind == userOwner {
+		delete(g.userNodes, o.id)
+	}
+}
+
+// SetUserGraph replaces the nodes and edges of the user's graph. If userNodeUUID is not
+// empty, that node is served as the node representing the user.
+func (s *Server) SetUserGraph(userID, userNodeUUID string, nodes []Node, edges []Edge) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.graph.set(owner{kind: userOwner, id: userID}, nodes, edges)
+	if userNodeUUID != "" {
+		s.graph.userNodes[userID] = userNodeUUID
+	}
+}
+
+// SetGroupGraph replaces the nodes and edges of the group's graph.
+func (s *Server) SetGroupGraph(groupID string, nodes []Node, edges []Edge) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.graph.set(owner{kind: groupOwner, id: groupID}, nodes, edges)
+}
+
+// Episodes returns the episodes added to the graph, in the order they were added.
+func (s *Server) Episodes() []Episode {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Episode(nil), s.graph.episodes...)
+}
+
+func (s *Server) handleAddEpisode(w http.ResponseWriter, r *http.Request) {
+	var episode Episode
+	if err := json.NewDecoder(r.Body).Decode(&episode); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if (episode.UserID == "") == (episode.GroupID == "") {
+		writeError(w, http.StatusBadRequest, "exactly one of user_id or group_id is required")
+		return
+	}
+	if episode.Type != "text" && episode.Type != "json" && episode.Type != "message" {
+		writeError(w, http.StatusBadRequest, "unknown episode type")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.graph.episodes = append(s.graph.episodes, episode)
+	writeJSON(w, http.StatusOK, map[string]string{"uuid": s.nextID("episode")})
+}
+
+func (s *Server) handleUserNode(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	userID := r.PathValue("userID")
+	node, ok := s.graph.nodes[s.graph.userNodes[userID]]
+	if !ok {
+		writeError(w, http.StatusNotFound, "user node not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]Node{"node": node})
+}
+
+func (s *Server) handleNode(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	node, ok := s.graph.nodes[r.PathValue("uuid")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "node not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, node)
+}
+
+func (s *Server) handleEdge(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	edge, ok := s.graph.edges[r.PathValue("uuid")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "edge not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, edge)
+}
+
+func (s *Server) handleNodeEdges(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	uuid := r.PathValue("uuid")
+	if _, ok := s.graph.nodes[uuid]; !ok {
+		writeError(w, http.StatusNotFound, "node not found")
+		return
+	}
+	edges := []Edge{}
+	for _, owned := range s.sortedOwned() {
+		for _, edgeUUID := range owned.edges {
+			edge := s.graph.edges[edgeUUID]
+			if edge.SourceNodeUUID == uuid || edge.TargetNodeUUID == uuid {
+				edges = append(edges, edge)
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, edges)
+}
+
+// sortedOwned returns every owned graph in a stable order, so that responses that span
+// several graphs are deterministic. The caller must hold s.mu.
+func (s *Server) sortedOwned() []*ownedGraph {
+	owners := make([]owner, 0, len(s.graph.owned))
+	for o := range s.graph.owned {
+		owners = append(owners, o)
+	}
+	slices.SortFunc(owners, func(a, b owner) int {
+		if a.kind != b.kind {
+			return int(a.kind) - int(b.kind)
+		}
+		if a.id < b.id {
+			return -1
+		}
+		if a.id > b.id {
+			return 1
+		}
+		return 0
+	})
+	graphs := make([]*ownedGraph, 0, len(owners))
+	for _, o := range owners {
+		graphs = append(graphs, s.graph.owned[o])
+	}
+	return graphs
+}
+
+type pageRequest struct {
+	Limit      *int    `json:"limit"`
+	UUIDCursor *string `json:"uuid_cursor"`
+}
+
+// page returns the UUIDs after the cursor, up to the requested limit.
+func page(uuids []string, r *http.Request) ([]string, bool) {
+	var request pageRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, false
+	}
+	start := 0
+	if request.UUIDCursor != nil {
+		start = slices.Index(uuids, *request.UUIDCursor) + 1
+	}
+	end := len(uuids)
+	if request.Limit != nil && *request.Limit > 0 {
+		end = min(start+*request.Limit, end)
+	}
+	return uuids[start:end], true
+}
+
+func (s *Server) handleListNodes(kind ownerKind) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		var uuids []string
+		if owned, ok := s.graph.owned[owner{kind: kind, id: r.PathValue("id")}]; ok {
+			uuids = owned.nodes
+		}
+		uuids, ok := page(uuids, r)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "invalid page request")
+			return
+		}
+		nodes := make([]Node, 0, len(uuids))
+		for _, uuid := range uuids {
+			nodes = append(nodes, s.graph.nodes[uuid])
+		}
+		writeJSON(w, http.StatusOK, nodes)
+	}
+}
+
+func (s *Server) handleListEdges(kind ownerKind) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		var uuids []string
+		if owned, ok := s.graph.owned[owner{kind: kind, id: r.PathValue("id")}]; ok {
+			uuids = owned.edges
+		}
+		uuids, ok := page(uuids, r)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "invalid page request")
+			return
+		}
+		edges := make([]Edge, 0, len(uuids))
+		for _, uuid := range uuids {
+			edges = append(edges, s.graph.edges[uuid])
+		}
+		writeJSON(w, http.StatusOK, edges)
+	}
+}
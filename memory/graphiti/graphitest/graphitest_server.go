@@ -0,0 +1,364 @@
+// Package graphitest provides an in-memory fake of the Zep API for tests. It serves the
+// memory, session, user and graph endpoints used by the graphiti package from an
+// httptest.Server, so a real zep-go client and graphiti.GraphClient can be exercised
+// end-to-end without the hosted service.
+package graphitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/memorytest"
+	"github.com/getzep/zep-go"
+	zepClient "github.com/getzep/zep-go/client"
+	"github.com/getzep/zep-go/option"
+)
+
+// Server is a fake Zep API. Messages, sessions and users get deterministic UUIDs and
+// timestamps. Facts and summaries are never derived from messages; tests script them with
+// SetFacts and SetSummary instead.
+type Server struct {
+	*httptest.Server
+	memorytest.FaultInjector
+
+	apiKey         string
+	clock          func() time.Time
+	strictSessions bool
+
+	mu       sync.Mutex
+	sessions map[string]*session
+	users    map[string]*zep.User
+	graph    graph
+	ids      int
+	requests map[string]int
+}
+
+type session struct {
+	zep.Session
+	messages []*zep.Message
+	summary  string
+}
+
+// NewServer starts a fake Zep API. The caller must call Close when done.
+func NewServer(options ...ServerOption) *Server {
+	s := applyServerOptions(options...)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /sessions", s.handleAddSession)
+	mux.HandleFunc("GET /sessions/{sessionID}", s.handleGetSession)
+	mux.HandleFunc("PATCH /sessions/{sessionID}", s.handleUpdateSession)
+	mux.HandleFunc("GET /sessions/{sessionID}/memory", s.handleGetMemory)
+	mux.HandleFunc("POST /sessions/{sessionID}/memory", s.handleAddMemory)
+	mux.HandleFunc("DELETE /sessions/{sessionID}/memory", s.handleDeleteMemory)
+	mux.HandleFunc("POST /users", s.handleAddUser)
+	mux.HandleFunc("GET /users/{userID}", s.handleGetUser)
+	mux.HandleFunc("DELETE /users/{userID}", s.handleDeleteUser)
+	mux.HandleFunc("GET /users/{userID}/node", s.handleUserNode)
+	mux.HandleFunc("POST /graph", s.handleAddEpisode)
+	mux.HandleFunc("GET /graph/node/{uuid}", s.handleNode)
+	mux.HandleFunc("GET /graph/edge/{uuid}", s.handleEdge)
+	mux.HandleFunc("GET /graph/node/{uuid}/entity-edges", s.handleNodeEdges)
+	mux.HandleFunc("POST /graph/node/user/{id}", s.handleListNodes(userOwner))
+	mux.HandleFunc("POST /graph/edge/user/{id}", s.handleListEdges(userOwner))
+	mux.HandleFunc("POST /graph/node/group/{id}", s.handleListNodes(groupOwner))
+	mux.HandleFunc("POST /graph/edge/group/{id}", s.handleListEdges(groupOwner))
+	faulty := s.Handler(mux)
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.apiKey != "" && r.Header.Get("Authorization") != "Api-Key "+s.apiKey {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		s.mu.Lock()
+		s.requests[r.Method+" "+r.URL.Path]++
+		s.mu.Unlock()
+		faulty.ServeHTTP(w, r)
+	}))
+	return s
+}
+
+// ClientOptions returns the request options that point a zep-go client or a
+// graphiti.GraphClient at the server.
+func (s *Server) ClientOptions() []option.RequestOption {
+	apiKey := s.apiKey
+	if apiKey == "" {
+		apiKey = "test"
+	}
+	return []option.RequestOption{option.WithBaseURL(s.URL), option.WithAPIKey(apiKey)}
+}
+
+// NewClient returns a zep-go client for the server.
+func (s *Server) NewClient() *zepClient.Client {
+	return zepClient.NewClient(s.ClientOptions()...)
+}
+
+// SetFacts replaces the facts returned with the session's memory, creating the session if
+// needed.
+func (s *Server) SetFacts(sessionID string, facts ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.session(sessionID).Facts = facts
+}
+
+// SetSummary replaces the summary returned with the session's memory, creating the session if
+// needed. An empty summary removes it.
+func (s *Server) SetSummary(sessionID, summary string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.session(sessionID).summary = summary
+}
+
+// Messages returns the messages stored in the session.
+func (s *Server) Messages(sessionID string) []*zep.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[sessionID]
+	if !ok {
+		return nil
+	}
+	return append([]*zep.Message(nil), sess.messages...)
+}
+
+// RequestCount returns how many requests with the given method and path the server has
+// received. Requests failed by an injected fault are counted.
+func (s *Server) RequestCount(method, path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[method+" "+path]
+}
+
+// nextID returns a deterministic identifier with the given prefix. The caller must hold s.mu.
+func (s *Server) nextID(prefix string) string {
+	s.ids++
+	return fmt.Sprintf("%s-%04d", prefix, s.ids)
+}
+
+// now returns the current time of the server clock as Zep formats it. The caller must hold s.mu.
+func (s *Server) now() string {
+	return s.clock().UTC().Format(time.RFC3339)
+}
+
+// session returns the session with the given ID, creating it if needed. The caller must hold s.mu.
+func (s *Server) session(sessionID string) *session {
+	sess, ok := s.sessions[sessionID]
+	if !ok {
+		now := s.now()
+		sess = &session{Session: zep.Session{
+			SessionID: zep.String(sessionID),
+			UUID:      zep.String(s.nextID("session")),
+			CreatedAt: zep.String(now),
+			UpdatedAt: zep.String(now),
+		}}
+		s.sessions[sessionID] = sess
+	}
+	return sess
+}
+
+func (s *Server) handleAddSession(w http.ResponseWriter, r *http.Request) {
+	var request zep.CreateSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.SessionID == "" {
+		writeError(w, http.StatusBadRequest, "session_id is required")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sessions[request.SessionID]; ok {
+		writeError(w, http.StatusBadRequest, "session already exists")
+		return
+	}
+	if request.UserID != nil {
+		if _, ok := s.users[*request.UserID]; !ok {
+			writeError(w, http.StatusNotFound, "user not found")
+			return
+		}
+	}
+	sess := s.session(request.SessionID)
+	sess.UserID = request.UserID
+	sess.Metadata = request.Metadata
+	writeJSON(w, http.StatusOK, sess.Session)
+}
+
+func (s *Server) handleGetSession(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[r.PathValue("sessionID")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, sess.Session)
+}
+
+// handleUpdateSession merges the request metadata into the session metadata, like Zep does.
+func (s *Server) handleUpdateSession(w http.ResponseWriter, r *http.Request) {
+	var request zep.UpdateSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[r.PathValue("sessionID")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	if sess.Metadata == nil {
+		sess.Metadata = make(map[string]any)
+	}
+	for key, value := range request.Metadata {
+		sess.Metadata[key] = value
+	}
+	sess.UpdatedAt = zep.String(s.now())
+	writeJSON(w, http.StatusOK, sess.Session)
+}
+
+func (s *Server) handleGetMemory(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[r.PathValue("sessionID")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+
+	messages := sess.messages
+	if lastn, err := strconv.Atoi(r.URL.Query().Get("lastn")); err == nil && lastn >= 0 && lastn < len(messages) {
+		messages = messages[len(messages)-lastn:]
+	}
+	memory := zep.Memory{Messages: messages, Facts: sess.Facts}
+	for _, fact := range sess.Facts {
+		memory.RelevantFacts = append(memory.RelevantFacts, &zep.Fact{Fact: zep.String(fact)})
+	}
+	if sess.summary != "" {
+		memory.Summary = &zep.Summary{Content: zep.String(sess.summary)}
+	}
+	writeJSON(w, http.StatusOK, memory)
+}
+
+func (s *Server) handleAddMemory(w http.ResponseWriter, r *http.Request) {
+	var request zep.AddMemoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sessionID := r.PathValue("sessionID")
+	if _, ok := s.sessions[sessionID]; !ok && s.strictSessions {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	sess := s.session(sessionID)
+	for _, message := range request.Messages {
+		if message.RoleType == nil || message.Content == nil {
+			writeError(w, http.StatusBadRequest, "messages need a role_type and content")
+			return
+		}
+	}
+	for _, message := range request.Messages {
+		stored := *message
+		now := s.now()
+		stored.UUID = zep.String(s.nextID("message"))
+		stored.CreatedAt = zep.String(now)
+		stored.UpdatedAt = zep.String(now)
+		sess.messages = append(sess.messages, &stored)
+	}
+	sess.UpdatedAt = zep.String(s.now())
+	writeJSON(w, http.StatusOK, zep.SuccessResponse{Message: zep.String("OK")})
+}
+
+func (s *Server) handleDeleteMemory(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sessionID := r.PathValue("sessionID")
+	if _, ok := s.sessions[sessionID]; !ok {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	delete(s.sessions, sessionID)
+	writeJSON(w, http.StatusOK, zep.SuccessResponse{Message: zep.String("OK")})
+}
+
+func (s *Server) handleAddUser(w http.ResponseWriter, r *http.Request) {
+	var request zep.CreateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.UserID == nil || *request.UserID == "" {
+		writeError(w, http.StatusBadRequest, "user_id is required")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[*request.UserID]; ok {
+		writeError(w, http.StatusBadRequest, "user already exists")
+		return
+	}
+	now := s.now()
+	user := &zep.User{
+		UserID:    request.UserID,
+		UUID:      zep.String(s.nextID("user")),
+		Email:     request.Email,
+		FirstName: request.FirstName,
+		LastName:  request.LastName,
+		Metadata:  request.Metadata,
+		CreatedAt: zep.String(now),
+		UpdatedAt: zep.String(now),
+	}
+	s.users[*request.UserID] = user
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (s *Server) handleGetUser(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[r.PathValue("userID")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	sessions := 0
+	for _, sess := range s.sessions {
+		if sess.UserID != nil && *sess.UserID == *user.UserID {
+			sessions++
+		}
+	}
+	response := *user
+	response.SessionCount = zep.Int(sessions)
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	userID := r.PathValue("userID")
+	if _, ok := s.users[userID]; !ok {
+		writeError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	delete(s.users, userID)
+	for id, sess := range s.sessions {
+		if sess.UserID != nil && *sess.UserID == userID {
+			delete(s.sessions, id)
+		}
+	}
+	s.graph.deleteOwner(owner{kind: userOwner, id: userID})
+	writeJSON(w, http.StatusOK, zep.SuccessResponse{Message: zep.String("OK")})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
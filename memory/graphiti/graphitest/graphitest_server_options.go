@@ -0,0 +1,67 @@
+package graphitest
+
+import (
+	"time"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/memorytest"
+	"github.com/getzep/zep-go"
+)
+
+// ServerOption is a function for creating a new fake server
+// with other than the default values.
+type ServerOption func(s *Server)
+
+// WithAPIKey is an option for requiring an API key. By default any key is accepted.
+func WithAPIKey(apiKey string) ServerOption {
+	return func(s *Server) {
+		s.apiKey = apiKey
+	}
+}
+
+// WithClock is an option for specifying the clock used for timestamps. The default clock
+// starts at 2024-01-01T00:00:00Z and advances one second per call.
+func WithClock(clock func() time.Time) ServerOption {
+	return func(s *Server) {
+		s.clock = clock
+	}
+}
+
+// WithStrictSessions is an option for answering 404 when messages are added to a session that
+// was not created first. By default sessions are created on their first write.
+func WithStrictSessions() ServerOption {
+	return func(s *Server) {
+		s.strictSessions = true
+	}
+}
+
+// WithFault is an option for injecting a fault from the start.
+func WithFault(fault memorytest.Fault) ServerOption {
+	return func(s *Server) {
+		s.InjectFault(fault)
+	}
+}
+
+// WithLatency is an option for delaying every response by the given duration.
+func WithLatency(latency time.Duration) ServerOption {
+	return WithFault(memorytest.Fault{Latency: latency})
+}
+
+func applyServerOptions(options ...ServerOption) *Server {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := &Server{
+		clock: func() time.Time {
+			now = now.Add(time.Second)
+			return now
+		},
+		sessions: map[string]*session{},
+		users:    map[string]*zep.User{},
+		graph:    newGraph(),
+		requests: map[string]int{},
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
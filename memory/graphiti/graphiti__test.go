@@ -1,61 +1,33 @@
 package graphiti
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
 	"testing"
 
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/0xDezzy/langchaingo-memory/memory/graphiti/graphitest"
 	"github.com/getzep/zep-go"
 	zepClient "github.com/getzep/zep-go/client"
 	"github.com/tmc/langchaingo/llms"
 )
 
-// MockZepClient implements a simple mock for testing
-type MockZepClient struct {
-	memory *zep.Memory
-}
-
-func (m *MockZepClient) Memory() MemoryService {
-	return &MockMemoryService{memory: m.memory}
-}
-
-type MemoryService interface {
-	Get(ctx context.Context, sessionID string, request *zep.MemoryGetRequest) (*zep.Memory, error)
-	Add(ctx context.Context, sessionID string, request *zep.AddMemoryRequest) (*zep.Memory, error)
-	Delete(ctx context.Context, sessionID string) (*zep.Memory, error)
-}
-
-type MockMemoryService struct {
-	memory *zep.Memory
-}
-
-func (m *MockMemoryService) Get(_ context.Context, _ string, _ *zep.MemoryGetRequest) (*zep.Memory, error) {
-	if m.memory == nil {
-		return &zep.Memory{Messages: []*zep.Message{}}, nil
-	}
-	return m.memory, nil
-}
+// createMockZepClient returns a zep client backed by a fresh fake Zep server.
+func createMockZepClient(t *testing.T) *zepClient.Client {
+	t.Helper()
 
-func (m *MockMemoryService) Add(_ context.Context, _ string, request *zep.AddMemoryRequest) (*zep.Memory, error) {
-	if m.memory == nil {
-		m.memory = &zep.Memory{Messages: []*zep.Message{}}
-	}
-	m.memory.Messages = append(m.memory.Messages, request.Messages...)
-	return m.memory, nil
-}
-
-func (m *MockMemoryService) Delete(_ context.Context, _ string) (*zep.Memory, error) {
-	m.memory = &zep.Memory{Messages: []*zep.Message{}}
-	return m.memory, nil
-}
-
-func createMockZepClient() *zepClient.Client {
-	return &zepClient.Client{}
+	server := graphitest.NewServer()
+	t.Cleanup(server.Close)
+	return server.NewClient()
 }
 
 func TestNewMemory(t *testing.T) {
 	t.Parallel()
 
-	client := createMockZepClient()
+	client := createMockZepClient(t)
 	sessionID := "test-session"
 
 	m := NewMemory(client, sessionID)
@@ -83,7 +55,7 @@ func TestNewMemory(t *testing.T) {
 func TestNewMemoryWithOptions(t *testing.T) {
 	t.Parallel()
 
-	client := createMockZepClient()
+	client := createMockZepClient(t)
 	sessionID := "test-session"
 
 	m := NewMemory(
@@ -124,7 +96,7 @@ func TestNewMemoryWithOptions(t *testing.T) {
 func TestMemoryVariables(t *testing.T) {
 	t.Parallel()
 
-	client := createMockZepClient()
+	client := createMockZepClient(t)
 	m := NewMemory(client, "test-session", WithMemoryKey("custom_key"))
 
 	ctx := context.Background()
@@ -139,7 +111,7 @@ func TestMemoryVariables(t *testing.T) {
 func TestGetMemoryKey(t *testing.T) {
 	t.Parallel()
 
-	client := createMockZepClient()
+	client := createMockZepClient(t)
 	m := NewMemory(client, "test-session", WithMemoryKey("test_key"))
 
 	ctx := context.Background()
@@ -153,7 +125,7 @@ func TestGetMemoryKey(t *testing.T) {
 func TestNewZepChatMessageHistory(t *testing.T) {
 	t.Parallel()
 
-	client := createMockZepClient()
+	client := createMockZepClient(t)
 	sessionID := "test-session"
 
 	h := NewZepChatMessageHistory(client, sessionID)
@@ -172,7 +144,7 @@ func TestNewZepChatMessageHistory(t *testing.T) {
 func TestNewZepChatMessageHistoryWithOptions(t *testing.T) {
 	t.Parallel()
 
-	client := createMockZepClient()
+	client := createMockZepClient(t)
 	sessionID := "test-session"
 
 	h := NewZepChatMessageHistory(
@@ -218,7 +190,10 @@ func TestMessagesFromZepMessages(t *testing.T) {
 		},
 	}
 
-	chatMessages := h.messagesFromZepMessages(zepMessages)
+	chatMessages, err := h.messagesFromZepMessages(context.Background(), "test-session", zepMessages)
+	if err != nil {
+		t.Fatalf("messagesFromZepMessages: %v", err)
+	}
 
 	if len(chatMessages) != 3 {
 		t.Errorf("Expected 3 messages, got %d", len(chatMessages))
@@ -251,7 +226,10 @@ func TestMessagesToZepMessages(t *testing.T) {
 		llms.FunctionChatMessage{Content: "Function result"},
 	}
 
-	zepMessages := h.messagesToZepMessages(chatMessages)
+	zepMessages, err := h.messagesToZepMessages(context.Background(), "test-session", chatMessages)
+	if err != nil {
+		t.Fatalf("messagesToZepMessages: %v", err)
+	}
 
 	if len(zepMessages) != 3 {
 		t.Errorf("Expected 3 messages, got %d", len(zepMessages))
@@ -281,7 +259,7 @@ func TestLoadMemoryVariablesReturnMessages(t *testing.T) {
 	t.Parallel()
 
 	ctx := context.Background()
-	client := createMockZepClient()
+	client := createMockZepClient(t)
 	m := NewMemory(client, "test-session", WithReturnMessages(true))
 
 	// Mock chat history with messages
@@ -313,7 +291,7 @@ func TestLoadMemoryVariablesBufferString(t *testing.T) {
 	t.Parallel()
 
 	ctx := context.Background()
-	client := createMockZepClient()
+	client := createMockZepClient(t)
 	m := NewMemory(client, "test-session", WithReturnMessages(false))
 
 	mockHistory := &mockChatHistory{
@@ -345,7 +323,7 @@ func TestLoadMemoryVariablesCustomKey(t *testing.T) {
 	t.Parallel()
 
 	ctx := context.Background()
-	client := createMockZepClient()
+	client := createMockZepClient(t)
 	m := NewMemory(client, "test-session", WithMemoryKey("custom_history"))
 
 	mockHistory := &mockChatHistory{
@@ -368,7 +346,7 @@ func TestLoadMemoryVariablesError(t *testing.T) {
 	t.Parallel()
 
 	ctx := context.Background()
-	client := createMockZepClient()
+	client := createMockZepClient(t)
 	m := NewMemory(client, "test-session")
 
 	mockHistory := &mockChatHistory{
@@ -389,7 +367,7 @@ func TestSaveContext(t *testing.T) {
 	ctx := context.Background()
 
 	t.Run("BasicSave", func(t *testing.T) {
-		client := createMockZepClient()
+		client := createMockZepClient(t)
 		m := NewMemory(client, "test-session")
 
 		mockHistory := &mockChatHistory{}
@@ -412,7 +390,7 @@ func TestSaveContext(t *testing.T) {
 	})
 
 	t.Run("WithInputOutputKeys", func(t *testing.T) {
-		client := createMockZepClient()
+		client := createMockZepClient(t)
 		m := NewMemory(client, "test-session",
 			WithInputKey("user_input"),
 			WithOutputKey("ai_output"),
@@ -438,7 +416,7 @@ func TestSaveContext(t *testing.T) {
 	})
 
 	t.Run("MissingInputKey", func(t *testing.T) {
-		client := createMockZepClient()
+		client := createMockZepClient(t)
 		m := NewMemory(client, "test-session", WithInputKey("missing_key"))
 
 		mockHistory := &mockChatHistory{}
@@ -454,7 +432,7 @@ func TestSaveContext(t *testing.T) {
 	})
 
 	t.Run("ErrorFromAddUserMessage", func(t *testing.T) {
-		client := createMockZepClient()
+		client := createMockZepClient(t)
 		m := NewMemory(client, "test-session")
 
 		mockHistory := &mockChatHistory{
@@ -477,7 +455,7 @@ func TestClear(t *testing.T) {
 	t.Parallel()
 
 	ctx := context.Background()
-	client := createMockZepClient()
+	client := createMockZepClient(t)
 	m := NewMemory(client, "test-session")
 
 	mockHistory := &mockChatHistory{}
@@ -549,3 +527,132 @@ func TestChatMessageHistoryMethods(t *testing.T) {
 		}
 	})
 }
+
+func TestFacts(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	server := graphitest.NewServer()
+	t.Cleanup(server.Close)
+	h := NewZepChatMessageHistory(server.NewClient(), "test-session")
+	if facts, err := h.Facts(ctx); err != nil || len(facts) != 0 {
+		t.Errorf("Expected no facts for a missing session, got %v, %v", facts, err)
+	}
+
+	server.SetFacts("test-session", "Sarah lives in Berlin")
+	server.SetSummary("test-session", "Sarah talked about moving.")
+	facts, err := h.Facts(ctx)
+	if err != nil {
+		t.Fatalf("Facts: %v", err)
+	}
+	if len(facts) != 2 || facts[0] != "Sarah lives in Berlin" || facts[1] != "Sarah talked about moving." {
+		t.Errorf("Expected the fact and the summary, got %v", facts)
+	}
+}
+
+func TestUnknownRoles(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	userRole, systemRole := zep.RoleTypeUserRole, zep.RoleTypeSystemRole
+	zepMessages := []*zep.Message{
+		{Content: zep.String("Hello"), RoleType: &userRole},
+		{Content: zep.String("Be brief"), RoleType: &systemRole},
+	}
+
+	var buf bytes.Buffer
+	h := NewZepChatMessageHistory(nil, "test-session", WithChatHistoryLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+	chatMessages, err := h.messagesFromZepMessages(ctx, "test-session", zepMessages)
+	if err != nil || len(chatMessages) != 1 {
+		t.Fatalf("Expected the unknown message to be dropped, got %d messages, %v", len(chatMessages), err)
+	}
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a JSON log record, got %q", buf.String())
+	}
+	if record["level"] != "WARN" || record["role"] != "system" || record["session_id"] != "test-session" || record["operation"] != "Messages" {
+		t.Errorf("Expected a warning with the role, session and operation, got %v", record)
+	}
+
+	h = NewZepChatMessageHistory(nil, "test-session", WithChatHistoryRejectUnknownRoles())
+	if _, err := h.messagesFromZepMessages(ctx, "test-session", zepMessages); !errors.Is(err, core.ErrUnknownRole) {
+		t.Errorf("Expected ErrUnknownRole, got %v", err)
+	}
+}
+
+func TestEncryption(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	keys := core.NewStaticKeyProvider("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+
+	server := graphitest.NewServer()
+	t.Cleanup(server.Close)
+	h := NewZepChatMessageHistory(server.NewClient(), "test-session", WithChatHistoryEncryption(keys))
+	if err := h.AddUserMessage(ctx, "I live in Berlin"); err != nil {
+		t.Fatalf("AddUserMessage: %v", err)
+	}
+	stored := server.Messages("test-session")
+	if len(stored) != 1 || !core.IsEncrypted(*stored[0].Content) {
+		t.Fatalf("Expected Zep to store ciphertext, got %v", stored)
+	}
+	if stored[0].Metadata[core.EncryptionKeyIDMetadataKey] != "k1" {
+		t.Errorf("Expected the key ID in the metadata, got %v", stored[0].Metadata)
+	}
+
+	server.SetFacts("test-session", "enc:v1:gibberish")
+	messages, err := h.Messages(ctx)
+	if err != nil {
+		t.Fatalf("Messages: %v", err)
+	}
+	if len(messages) != 1 || messages[0].GetContent() != "I live in Berlin" {
+		t.Errorf("Expected the decrypted message without facts, got %v", messages)
+	}
+	if facts, err := h.Facts(ctx); err != nil || len(facts) != 0 {
+		t.Errorf("Expected no facts, got %v, %v", facts, err)
+	}
+
+	moved := NewZepChatMessageHistory(server.NewClient(), "other-session", WithChatHistoryEncryption(keys))
+	if _, err := server.NewClient().Memory.Add(ctx, "other-session", &zep.AddMemoryRequest{Messages: stored}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if _, err := moved.Messages(ctx); err == nil {
+		t.Errorf("Expected a message moved to another session to fail")
+	}
+
+	plain := NewZepChatMessageHistory(server.NewClient(), "test-session")
+	if err := plain.AddUserMessage(ctx, "injected"); err != nil {
+		t.Fatalf("AddUserMessage: %v", err)
+	}
+	if _, err := h.Messages(ctx); !errors.Is(err, core.ErrNotEncrypted) {
+		t.Errorf("Expected a message that is not encrypted to be rejected, got %v", err)
+	}
+	migrating := NewZepChatMessageHistory(server.NewClient(), "test-session",
+		WithChatHistoryEncryption(keys, core.WithAllowPlaintext()))
+	if messages, err := migrating.Messages(ctx); err != nil || len(messages) != 2 {
+		t.Errorf("Expected both messages while migrating, got %v, %v", messages, err)
+	}
+}
+
+func TestResolver(t *testing.T) {
+	t.Parallel()
+
+	server := graphitest.NewServer()
+	t.Cleanup(server.Close)
+	m := NewMemory(server.NewClient(), "", WithResolver(core.ResolveFromContext))
+
+	if _, err := m.LoadMemoryVariables(context.Background(), nil); !errors.Is(err, core.ErrNoIdentity) {
+		t.Errorf("Expected ErrNoIdentity without a session, got %v", err)
+	}
+	for _, sessionID := range []string{"sarah-1", "john-1"} {
+		ctx := core.ContextWithSessionID(context.Background(), sessionID)
+		err := m.SaveContext(ctx, map[string]any{"input": "Hello from " + sessionID}, map[string]any{"output": "Hi"})
+		if err != nil {
+			t.Fatalf("SaveContext: %v", err)
+		}
+	}
+	for _, sessionID := range []string{"sarah-1", "john-1"} {
+		stored := server.Messages(sessionID)
+		if len(stored) != 2 || *stored[0].Content != "Hello from "+sessionID {
+			t.Errorf("Expected the turn of %s in its own session, got %v", sessionID, stored)
+		}
+	}
+}
@@ -2,60 +2,167 @@ package graphiti
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"slices"
 
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
 	"github.com/getzep/zep-go"
 	zepClient "github.com/getzep/zep-go/client"
+	zepcore "github.com/getzep/zep-go/core"
+	"github.com/getzep/zep-go/option"
 	"github.com/tmc/langchaingo/llms"
 	"github.com/tmc/langchaingo/schema"
 )
 
+// ErrGraphEpisodesDisabled is returned when an operation needs the graph but no GraphClient
+// has been configured with WithChatHistoryGraphEpisodes.
+var ErrGraphEpisodesDisabled = errors.New("graphiti: graph episodes are not enabled")
+
+// telemetryBackend names Zep in the spans and metrics of the memories.
+const telemetryBackend = "graphiti"
+
+// Config is the configuration of a chat message history that a Memory passes on to its
+// history.
+type Config struct {
+	MemoryType zep.MemoryType
+	// Resolver, if set, resolves the user and session of every call from its context, so that
+	// one history serves every session. UserID and SessionID are used when it resolves none.
+	Resolver     core.Resolver
+	GraphClient  *GraphClient
+	UserID       string
+	EpisodeRoles []llms.ChatMessageType
+	IgnoredRoles []llms.ChatMessageType
+	// HTTPClient is the HTTP client that ZepClient was created with. Requests that tell Zep
+	// which roles to keep out of the graph are sent with it, as zep-go has no field for them.
+	// Nil sends them with http.DefaultClient, the default of zep-go.
+	HTTPClient  zepcore.HTTPClient
+	RetryPolicy *core.RetryPolicy
+	// Logger receives the events of the history, such as dropped messages. Nil logs to
+	// slog.Default().
+	Logger *slog.Logger
+	// RejectUnknownRoles fails reads and writes with messages of unknown roles, with an error
+	// wrapping core.ErrUnknownRole, instead of dropping and logging those messages.
+	RejectUnknownRoles bool
+	// Encryptor encrypts the content of messages before they are sent to Zep, for transcript
+	// only storage: Zep is told to keep them out of the graph and no message is added to the
+	// graph as an episode, so the history has no facts or summary.
+	Encryptor *core.Encryptor
+}
+
 // ChatMessageHistory is a struct that stores chat messages.
 type ChatMessageHistory struct {
+	Config
 	ZepClient   *zepClient.Client
 	SessionID   string
-	MemoryType  zep.MemoryType
 	HumanPrefix string
 	AIPrefix    string
+	Telemetry   *core.Telemetry
 }
 
 // Statically assert that ZepChatMessageHistory implement the chat message history interface.
 var _ schema.ChatMessageHistory = &ChatMessageHistory{}
 
+// Statically assert that ChatMessageHistory can be used as a core memory backend.
+var _ core.Backend = &ChatMessageHistory{}
+
+// Statically assert that ChatMessageHistory can return facts on their own.
+var _ core.FactSource = &ChatMessageHistory{}
+
+// Statically assert that ChatMessageHistory can tell wrappers whether it resolves identities.
+var _ core.IdentityResolver = &ChatMessageHistory{}
+
 // NewZepChatMessageHistory creates a new ZepChatMessageHistory using chat message options.
 func NewZepChatMessageHistory(zep *zepClient.Client, sessionID string, options ...ChatMessageHistoryOption) *ChatMessageHistory {
 	messageHistory := applyZepChatHistoryOptions(options...)
 	messageHistory.ZepClient = zep
 	messageHistory.SessionID = sessionID
+	messageHistory.Telemetry = messageHistory.Telemetry.Identify(messageHistory.UserID, sessionID)
 	return messageHistory
 }
 
-func (h *ChatMessageHistory) messagesFromZepMessages(zepMessages []*zep.Message) []llms.ChatMessage {
+// ResolvesIdentity reports whether the history has a Resolver.
+func (h *ChatMessageHistory) ResolvesIdentity() bool {
+	return h.Resolver != nil
+}
+
+// identity returns the user and session of a call, resolved from ctx if the history has a
+// Resolver. Only the session is required; calls that add to the user graph check for the user
+// with graphUser.
+func (h *ChatMessageHistory) identity(ctx context.Context, op *core.Operation) (userID, sessionID string, err error) {
+	if h.Resolver == nil {
+		return h.UserID, h.SessionID, nil
+	}
+	userID, sessionID, err = core.Resolve(ctx, h.Resolver, h.UserID, h.SessionID)
+	if err != nil {
+		return "", "", fmt.Errorf("graphiti: resolving session: %w", err)
+	}
+	if sessionID == "" {
+		return "", "", fmt.Errorf("graphiti: %w", core.ErrNoIdentity)
+	}
+	op.Identify(userID, sessionID)
+	return userID, sessionID, nil
+}
+
+// graphUser returns userID, the user whose graph episodes are added to, or an error wrapping
+// core.ErrNoIdentity if there is none.
+func graphUser(userID string) (string, error) {
+	if userID == "" {
+		return "", fmt.Errorf("graphiti: graph episodes need a user: %w", core.ErrNoIdentity)
+	}
+	return userID, nil
+}
+
+// unknownRole drops a message of an unknown role of the session, or rejects it if
+// RejectUnknownRoles is set.
+func (h *ChatMessageHistory) unknownRole(ctx context.Context, sessionID, operation, role string) error {
+	if h.RejectUnknownRoles {
+		return fmt.Errorf("graphiti: %s: %w: %q", operation, core.ErrUnknownRole, role)
+	}
+	logger := h.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.LogAttrs(ctx, slog.LevelWarn, "graphiti: dropped message with unknown role",
+		slog.String("operation", operation),
+		slog.String("session_id", sessionID),
+		slog.String("role", role))
+	return nil
+}
+
+func (h *ChatMessageHistory) messagesFromZepMessages(ctx context.Context, sessionID string, zepMessages []*zep.Message) ([]llms.ChatMessage, error) {
 	var chatMessages []llms.ChatMessage
 	for _, zepMessage := range zepMessages {
-		switch *zepMessage.RoleType { // nolint We do not store other message types in zep memory
+		var roleType zep.RoleType
+		if zepMessage.RoleType != nil {
+			roleType = *zepMessage.RoleType
+		}
+		content, err := h.Encryptor.Decrypt(ctx, *zepMessage.Content, core.AssociatedData(sessionID, string(roleType)))
+		if err != nil {
+			return nil, fmt.Errorf("graphiti: %w", err)
+		}
+		switch roleType { // nolint We do not store other message types in zep memory
 		case zep.RoleTypeUserRole:
-			chatMessages = append(chatMessages, llms.HumanChatMessage{Content: *zepMessage.Content})
+			chatMessages = append(chatMessages, llms.HumanChatMessage{Content: content})
 		case zep.RoleTypeAssistantRole:
-			chatMessages = append(chatMessages, llms.AIChatMessage{Content: *zepMessage.Content})
-		case zep.RoleTypeToolRole:
-		case zep.RoleTypeFunctionRole:
-			chatMessages = append(chatMessages, llms.ToolChatMessage{Content: *zepMessage.Content})
+			chatMessages = append(chatMessages, llms.AIChatMessage{Content: content})
+		case zep.RoleTypeToolRole, zep.RoleTypeFunctionRole:
+			chatMessages = append(chatMessages, llms.ToolChatMessage{Content: content})
 		default:
-			log.Print(fmt.Errorf("unknown role: %s", *zepMessage.RoleType))
-			continue
+			if err := h.unknownRole(ctx, sessionID, "Messages", string(roleType)); err != nil {
+				return nil, err
+			}
 		}
 	}
-	return chatMessages
+	return chatMessages, nil
 }
 
-func (h *ChatMessageHistory) messagesToZepMessages(messages []llms.ChatMessage) []*zep.Message {
+func (h *ChatMessageHistory) messagesToZepMessages(ctx context.Context, sessionID string, messages []llms.ChatMessage) ([]*zep.Message, error) {
 	var zepMessages []*zep.Message //nolint We don't know the final size of the messages as some might be skipped due to unsupported role.
 	for _, m := range messages {
-		zepMessage := zep.Message{
-			Content: zep.String(m.GetContent()),
-		}
+		zepMessage := zep.Message{}
 		switch m.GetType() { // nolint We only expect to bring these three types into chat history
 		case llms.ChatMessageTypeHuman:
 			zepMessage.RoleType = zep.RoleTypeUserRole.Ptr()
@@ -72,23 +179,56 @@ func (h *ChatMessageHistory) messagesToZepMessages(messages []llms.ChatMessage)
 		case llms.ChatMessageTypeTool:
 			zepMessage.RoleType = zep.RoleTypeToolRole.Ptr()
 		default:
-			log.Print(fmt.Errorf("unknown role: %s", *zepMessage.RoleType))
+			if err := h.unknownRole(ctx, sessionID, "AddMessages", string(m.GetType())); err != nil {
+				return nil, err
+			}
 			continue
 		}
+		content, keyID, err := h.Encryptor.Encrypt(ctx, m.GetContent(), core.AssociatedData(sessionID, string(*zepMessage.RoleType)))
+		if err != nil {
+			return nil, fmt.Errorf("graphiti: %w", err)
+		}
+		zepMessage.Content = zep.String(content)
+		if h.Encryptor != nil {
+			zepMessage.Metadata = map[string]any{core.EncryptionKeyIDMetadataKey: keyID}
+		}
 		zepMessages = append(zepMessages, &zepMessage)
 	}
-	return zepMessages
+	return zepMessages, nil
 }
 
 // Messages returns all messages stored.
-func (h *ChatMessageHistory) Messages(ctx context.Context) ([]llms.ChatMessage, error) {
-	memory, err := h.ZepClient.Memory.Get(ctx, h.SessionID, &zep.MemoryGetRequest{
-		MemoryType: h.MemoryType.Ptr(),
+func (h *ChatMessageHistory) Messages(ctx context.Context) (_ []llms.ChatMessage, err error) {
+	ctx, op := h.Telemetry.Start(ctx, "Messages")
+	defer func() { op.End(ctx, err) }()
+	_, sessionID, err := h.identity(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+
+	var memory *zep.Memory
+	err = h.RetryPolicy.Do(ctx, true, func(ctx context.Context) error {
+		var err error
+		memory, err = h.ZepClient.Memory.Get(ctx, sessionID, &zep.MemoryGetRequest{
+			MemoryType: h.MemoryType.Ptr(),
+		}, h.requestOptions()...)
+		return translateError(ctx, err)
 	})
+	if errors.Is(err, core.ErrNotFound) {
+		// Zep creates sessions on first write, so a missing session is an empty history.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	messages, err := h.messagesFromZepMessages(ctx, sessionID, memory.Messages)
 	if err != nil {
 		return nil, err
 	}
-	messages := h.messagesFromZepMessages(memory.Messages)
+	if h.Encryptor != nil {
+		// Zep's facts and summary of encrypted messages are meaningless.
+		memory.Facts, memory.Summary = nil, nil
+	}
 	zepFacts := memory.Facts
 	systemPromptContent := ""
 	for _, fact := range zepFacts {
@@ -97,6 +237,7 @@ func (h *ChatMessageHistory) Messages(ctx context.Context) ([]llms.ChatMessage,
 	if memory.Summary != nil && memory.Summary.Content != nil {
 		systemPromptContent += fmt.Sprintf("%s\n", *memory.Summary.Content)
 	}
+	op.RecordFacts(ctx, len(zepFacts))
 	if systemPromptContent != "" {
 		// Add system prompt to the beginning of the messages.
 		messages = append(
@@ -108,57 +249,239 @@ func (h *ChatMessageHistory) Messages(ctx context.Context) ([]llms.ChatMessage,
 			messages...,
 		)
 	}
+	op.RecordMessages(messages)
 	return messages, nil
 }
 
-// AddAIMessage adds an AIMessage to the chat message history.
-func (h *ChatMessageHistory) AddAIMessage(ctx context.Context, text string) error {
-	_, err := h.ZepClient.Memory.Add(ctx, h.SessionID, &zep.AddMemoryRequest{
-		Messages: h.messagesToZepMessages(
-			[]llms.ChatMessage{
-				llms.AIChatMessage{Content: text},
-			},
-		),
+// Facts returns the facts and the summary Zep keeps for the session. Only the last message
+// is requested with them, as Zep always returns some messages with the memory.
+func (h *ChatMessageHistory) Facts(ctx context.Context) (_ []string, err error) {
+	ctx, op := h.Telemetry.Start(ctx, "Facts")
+	defer func() { op.End(ctx, err) }()
+	if h.Encryptor != nil {
+		return nil, nil
+	}
+	_, sessionID, err := h.identity(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+
+	var memory *zep.Memory
+	err = h.RetryPolicy.Do(ctx, true, func(ctx context.Context) error {
+		var err error
+		memory, err = h.ZepClient.Memory.Get(ctx, sessionID, &zep.MemoryGetRequest{
+			MemoryType: h.MemoryType.Ptr(),
+			Lastn:      zep.Int(1),
+		}, h.requestOptions()...)
+		return translateError(ctx, err)
 	})
+	if errors.Is(err, core.ErrNotFound) {
+		return nil, nil
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	facts := append([]string(nil), memory.Facts...)
+	if memory.Summary != nil && memory.Summary.Content != nil {
+		facts = append(facts, *memory.Summary.Content)
+	}
+	op.RecordFacts(ctx, len(facts))
+	return facts, nil
+}
+
+// AddAIMessage adds an AIMessage to the chat message history.
+func (h *ChatMessageHistory) AddAIMessage(ctx context.Context, text string) error {
+	return h.AddMessages(ctx, []llms.ChatMessage{llms.AIChatMessage{Content: text}})
 }
 
 // AddUserMessage adds a user to the chat message history.
 func (h *ChatMessageHistory) AddUserMessage(ctx context.Context, text string) error {
-	_, err := h.ZepClient.Memory.Add(ctx, h.SessionID, &zep.AddMemoryRequest{
-		Messages: h.messagesToZepMessages(
-			[]llms.ChatMessage{
-				llms.HumanChatMessage{Content: text},
-			},
-		),
-	})
+	return h.AddMessages(ctx, []llms.ChatMessage{llms.HumanChatMessage{Content: text}})
+}
+
+func (h *ChatMessageHistory) Clear(ctx context.Context) (err error) {
+	ctx, op := h.Telemetry.Start(ctx, "Clear")
+	defer func() { op.End(ctx, err) }()
+	_, sessionID, err := h.identity(ctx, op)
 	if err != nil {
 		return err
 	}
+
+	// Deleting is idempotent: a retry of a delete that went through finds no session.
+	err = h.RetryPolicy.Do(ctx, true, func(ctx context.Context) error {
+		_, err := h.ZepClient.Memory.Delete(ctx, sessionID, h.requestOptions()...)
+		return translateError(ctx, err)
+	})
+	if err != nil && !errors.Is(err, core.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// AddMessage adds a message to the chat message history. If graph episodes are enabled,
+// messages with one of the episode roles are added to the user graph instead.
+func (h *ChatMessageHistory) AddMessage(ctx context.Context, message llms.ChatMessage) error {
+	return h.AddMessages(ctx, []llms.ChatMessage{message})
+}
+
+func (*ChatMessageHistory) SetMessages(_ context.Context, _ []llms.ChatMessage) error {
 	return nil
 }
 
-func (h *ChatMessageHistory) Clear(ctx context.Context) error {
-	_, err := h.ZepClient.Memory.Delete(ctx, h.SessionID)
+// AddDocuments adds retrieved documents to the user graph as text episodes, so their facts
+// are extracted without the documents ever being replayed as chat turns.
+func (h *ChatMessageHistory) AddDocuments(ctx context.Context, documents []schema.Document) error {
+	if h.GraphClient == nil {
+		return ErrGraphEpisodesDisabled
+	}
+	userID, _, err := h.identity(ctx, nil)
 	if err != nil {
 		return err
 	}
+	if userID, err = graphUser(userID); err != nil {
+		return err
+	}
+	for _, document := range documents {
+		source, _ := document.Metadata["source"].(string)
+		if source == "" {
+			source = "retrieved document"
+		}
+		err := h.GraphClient.AddEpisode(ctx, Episode{
+			UserID:            userID,
+			Type:              EpisodeTypeText,
+			Data:              document.PageContent,
+			SourceDescription: source,
+		})
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (h *ChatMessageHistory) AddMessage(ctx context.Context, message llms.ChatMessage) error {
-	_, err := h.ZepClient.Memory.Add(ctx, h.SessionID, &zep.AddMemoryRequest{
-		Messages: h.messagesToZepMessages([]llms.ChatMessage{message}),
-	})
+// AddMessages adds several messages to the chat message history in a single request. If graph
+// episodes are enabled, messages with one of the episode roles are added to the graph instead,
+// after the other messages; a *core.PartialWriteError reports the messages written before a
+// failed episode.
+func (h *ChatMessageHistory) AddMessages(ctx context.Context, messages []llms.ChatMessage) (err error) {
+	ctx, op := h.Telemetry.Start(ctx, "AddMessages")
+	defer func() { op.End(ctx, err) }()
+	op.RecordMessages(messages)
+	userID, sessionID, err := h.identity(ctx, op)
 	if err != nil {
 		return err
 	}
+
+	var dialogue, episodes []llms.ChatMessage
+	for _, message := range messages {
+		// Episodes are not encrypted, so they are not used with encryption.
+		if h.GraphClient != nil && h.Encryptor == nil && slices.Contains(h.EpisodeRoles, message.GetType()) {
+			episodes = append(episodes, message)
+			continue
+		}
+		dialogue = append(dialogue, message)
+	}
+	if len(episodes) > 0 {
+		if userID, err = graphUser(userID); err != nil {
+			return err
+		}
+	}
+
+	var written []llms.ChatMessage
+	if len(dialogue) > 0 {
+		if err := h.addDialogue(ctx, sessionID, dialogue); err != nil {
+			return err
+		}
+		written = dialogue
+	}
+	for _, message := range episodes {
+		if err := h.addEpisode(ctx, userID, message); err != nil {
+			if len(written) > 0 {
+				return &core.PartialWriteError{Written: written, Err: err}
+			}
+			return err
+		}
+		written = append(written, message)
+	}
 	return nil
 }
 
-func (*ChatMessageHistory) SetMessages(_ context.Context, _ []llms.ChatMessage) error {
-	return nil
+// addDialogue adds messages to the session in a single request.
+func (h *ChatMessageHistory) addDialogue(ctx context.Context, sessionID string, dialogue []llms.ChatMessage) error {
+	zepMessages, err := h.messagesToZepMessages(ctx, sessionID, dialogue)
+	if err != nil {
+		return err
+	}
+	if len(zepMessages) == 0 {
+		return nil
+	}
+	options := h.requestOptions()
+	switch {
+	case h.Encryptor != nil:
+		// Encrypted messages are kept out of the graph, since there is nothing to extract from
+		// them.
+		options = append(options, option.WithHTTPClient(newIgnoreRolesClient(h.HTTPClient, allRoleTypes)))
+	case len(h.IgnoredRoles) > 0:
+		options = append(options, option.WithHTTPClient(newIgnoreRolesClient(h.HTTPClient, zepRoleTypes(h.IgnoredRoles))))
+	}
+	// Adding messages is not idempotent, so it is only retried when Zep did not process it.
+	return h.RetryPolicy.Do(ctx, false, func(ctx context.Context) error {
+		_, err := h.ZepClient.Memory.Add(ctx, sessionID, &zep.AddMemoryRequest{
+			Messages: zepMessages,
+		}, options...)
+		return translateError(ctx, err)
+	})
+}
+
+// requestOptions returns the zep-go request options of the history. Zep-go retries failed
+// requests on its own, regardless of Retry-After and of whether they are idempotent, so its
+// retries are turned off when the history has a retry policy.
+func (h *ChatMessageHistory) requestOptions() []option.RequestOption {
+	if h.RetryPolicy == nil {
+		return nil
+	}
+	return []option.RequestOption{option.WithMaxAttempts(1)}
+}
+
+func (h *ChatMessageHistory) addEpisode(ctx context.Context, userID string, message llms.ChatMessage) error {
+	episode := Episode{
+		UserID:            userID,
+		Type:              EpisodeTypeText,
+		Data:              message.GetContent(),
+		SourceDescription: fmt.Sprintf("%s output", message.GetType()),
+	}
+	if json.Valid([]byte(episode.Data)) {
+		episode.Type = EpisodeTypeJSON
+	}
+	if function, ok := message.(llms.FunctionChatMessage); ok && function.Name != "" {
+		episode.SourceDescription = fmt.Sprintf("function %s output", function.Name)
+	}
+	return h.RetryPolicy.Do(ctx, false, func(ctx context.Context) error {
+		return h.GraphClient.AddEpisode(ctx, episode)
+	})
+}
+
+// allRoleTypes are the roles of every message Zep stores.
+var allRoleTypes = []zep.RoleType{
+	zep.RoleTypeNoRole, zep.RoleTypeSystemRole, zep.RoleTypeAssistantRole,
+	zep.RoleTypeUserRole, zep.RoleTypeFunctionRole, zep.RoleTypeToolRole,
+}
+
+func zepRoleTypes(types []llms.ChatMessageType) []zep.RoleType {
+	var roles []zep.RoleType
+	for _, t := range types {
+		switch t { // nolint Generic messages have no zep role type
+		case llms.ChatMessageTypeHuman:
+			roles = append(roles, zep.RoleTypeUserRole)
+		case llms.ChatMessageTypeAI:
+			roles = append(roles, zep.RoleTypeAssistantRole)
+		case llms.ChatMessageTypeSystem:
+			roles = append(roles, zep.RoleTypeSystemRole)
+		case llms.ChatMessageTypeFunction:
+			roles = append(roles, zep.RoleTypeFunctionRole)
+		case llms.ChatMessageTypeTool:
+			roles = append(roles, zep.RoleTypeToolRole)
+		}
+	}
+	return roles
 }
@@ -1,6 +1,15 @@
 package graphiti
 
-import "github.com/getzep/zep-go"
+import (
+	"log/slog"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/getzep/zep-go"
+	zepcore "github.com/getzep/zep-go/core"
+	"github.com/tmc/langchaingo/llms"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
 
 // ChatMessageHistoryOption is a function for creating new chat message history
 // with other than the default values.
@@ -27,9 +36,107 @@ func WithChatHistoryAIPrefix(aiPrefix string) ChatMessageHistoryOption {
 	}
 }
 
+// WithChatHistoryGraphEpisodes is an option for adding tool outputs and documents to the user's
+// graph as episodes rather than as chat messages.
+func WithChatHistoryGraphEpisodes(graph *GraphClient, userID string) ChatMessageHistoryOption {
+	return func(b *ChatMessageHistory) {
+		b.GraphClient = graph
+		b.UserID = userID
+	}
+}
+
+// WithChatHistoryEpisodeRoles is an option for specifying which message types are added as
+// graph episodes. Defaults to tool and function messages.
+func WithChatHistoryEpisodeRoles(roles ...llms.ChatMessageType) ChatMessageHistoryOption {
+	return func(b *ChatMessageHistory) {
+		b.EpisodeRoles = roles
+	}
+}
+
+// WithChatHistoryIgnoredRoles is an option for specifying message types that are kept in the
+// session but never ingested into the graph. If the zep client has an HTTP client of its own,
+// pass it with WithChatHistoryHTTPClient too.
+func WithChatHistoryIgnoredRoles(roles ...llms.ChatMessageType) ChatMessageHistoryOption {
+	return func(b *ChatMessageHistory) {
+		b.IgnoredRoles = roles
+	}
+}
+
+// WithChatHistoryHTTPClient is an option for specifying the HTTP client that the zep client was
+// created with, which the history needs to send messages with ignored roles or encryption.
+func WithChatHistoryHTTPClient(httpClient zepcore.HTTPClient) ChatMessageHistoryOption {
+	return func(b *ChatMessageHistory) {
+		b.HTTPClient = httpClient
+	}
+}
+
+// WithChatHistoryRetryPolicy is an option for retrying failed requests to Zep with the given
+// policy. Messages are only re-sent when Zep did not process them. Errors are classified with
+// ClassifyError unless the policy has its own classifier.
+func WithChatHistoryRetryPolicy(policy core.RetryPolicy) ChatMessageHistoryOption {
+	return func(b *ChatMessageHistory) {
+		if policy.Classify == nil {
+			policy.Classify = ClassifyError
+		}
+		b.RetryPolicy = &policy
+	}
+}
+
+// WithChatHistoryTelemetry is an option for tracing and measuring the requests to Zep with
+// OpenTelemetry. Nil providers record nothing.
+func WithChatHistoryTelemetry(tracerProvider trace.TracerProvider, meterProvider metric.MeterProvider) ChatMessageHistoryOption {
+	return func(b *ChatMessageHistory) {
+		b.Telemetry = core.NewTelemetry(telemetryBackend, tracerProvider, meterProvider)
+	}
+}
+
+// WithChatHistoryLogger is an option for specifying the logger of the history's events, such
+// as messages dropped because of their role. Defaults to slog.Default().
+func WithChatHistoryLogger(logger *slog.Logger) ChatMessageHistoryOption {
+	return func(b *ChatMessageHistory) {
+		b.Logger = logger
+	}
+}
+
+// WithChatHistoryRejectUnknownRoles is an option for failing reads and writes with messages of roles
+// Zep does not store, instead of dropping those messages.
+func WithChatHistoryRejectUnknownRoles() ChatMessageHistoryOption {
+	return func(b *ChatMessageHistory) {
+		b.RejectUnknownRoles = true
+	}
+}
+
+// WithChatHistoryResolver is an option for resolving the user and session of every call from
+// its context, such as core.ResolveFromContext, so that one history serves every session of a
+// server. The user and session IDs of the history are used when the resolver returns none.
+func WithChatHistoryResolver(resolver core.Resolver) ChatMessageHistoryOption {
+	return func(b *ChatMessageHistory) {
+		b.Resolver = resolver
+	}
+}
+
+// WithChatHistoryEncryption is an option for encrypting the content of messages with keys
+// from provider before they leave the process, for transcripts that Zep must store but not
+// read. Zep is told to keep them out of the graph, and graph episodes are not added, so the
+// history has no facts or summary. Messages that are not encrypted are rejected on read unless
+// core.WithAllowPlaintext is given.
+func WithChatHistoryEncryption(provider core.KeyProvider, options ...core.EncryptorOption) ChatMessageHistoryOption {
+	return func(b *ChatMessageHistory) {
+		b.Encryptor = core.NewEncryptor(provider, options...)
+	}
+}
+
+// defaultConfig returns the configuration of histories and memories without options.
+func defaultConfig() Config {
+	return Config{
+		MemoryType:   zep.MemoryTypePerpetual,
+		EpisodeRoles: []llms.ChatMessageType{llms.ChatMessageTypeTool, llms.ChatMessageTypeFunction},
+	}
+}
+
 func applyZepChatHistoryOptions(options ...ChatMessageHistoryOption) *ChatMessageHistory {
 	h := &ChatMessageHistory{
-		MemoryType: zep.MemoryTypePerpetual,
+		Config: defaultConfig(),
 	}
 
 	for _, option := range options {
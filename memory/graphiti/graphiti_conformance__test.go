@@ -0,0 +1,24 @@
+package graphiti
+
+import (
+	"testing"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/memorytest"
+	"github.com/tmc/langchaingo/schema"
+)
+
+func TestChatMessageHistoryConformance(t *testing.T) {
+	t.Parallel()
+
+	memorytest.RunChatMessageHistorySuite(t, func(t *testing.T) schema.ChatMessageHistory {
+		return NewZepChatMessageHistory(createMockZepClient(t), "test-session")
+	}, memorytest.WithoutSetMessages())
+}
+
+func TestMemoryConformance(t *testing.T) {
+	t.Parallel()
+
+	memorytest.RunMemorySuite(t, func(t *testing.T) schema.Memory {
+		return NewMemory(createMockZepClient(t), "test-session")
+	})
+}
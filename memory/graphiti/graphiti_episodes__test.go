@@ -0,0 +1,291 @@
+package graphiti
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/getzep/zep-go"
+	zepClient "github.com/getzep/zep-go/client"
+	"github.com/getzep/zep-go/option"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+type addMemoryRequest struct {
+	Messages    []*zep.Message `json:"messages"`
+	IgnoreRoles []zep.RoleType `json:"ignore_roles"`
+}
+
+type episodeRecorder struct {
+	url      string
+	mu       sync.Mutex
+	episodes []Episode
+	requests []addMemoryRequest
+	// failEpisodes fails the episodes of the graph.
+	failEpisodes bool
+}
+
+func newEpisodeServer(t *testing.T) (*episodeRecorder, *zepClient.Client, *GraphClient) {
+	t.Helper()
+
+	rec := &episodeRecorder{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /graph", func(w http.ResponseWriter, r *http.Request) {
+		rec.mu.Lock()
+		fail := rec.failEpisodes
+		rec.mu.Unlock()
+		if fail {
+			http.Error(w, `{"message":"bad episode"}`, http.StatusBadRequest)
+			return
+		}
+		var episode Episode
+		_ = json.NewDecoder(r.Body).Decode(&episode)
+		rec.mu.Lock()
+		rec.episodes = append(rec.episodes, episode)
+		rec.mu.Unlock()
+		_, _ = w.Write([]byte("{}"))
+	})
+	mux.HandleFunc("POST /sessions/{sessionID}/memory", func(w http.ResponseWriter, r *http.Request) {
+		var request addMemoryRequest
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		rec.mu.Lock()
+		rec.requests = append(rec.requests, request)
+		rec.mu.Unlock()
+		_, _ = w.Write([]byte(`{"message":"OK"}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	rec.url = server.URL
+
+	opts := []option.RequestOption{option.WithBaseURL(server.URL), option.WithAPIKey("test")}
+	return rec, zepClient.NewClient(opts...), NewGraphClient(opts...)
+}
+
+func TestToolMessagesBecomeEpisodes(t *testing.T) {
+	t.Parallel()
+
+	rec, client, graph := newEpisodeServer(t)
+	ctx := context.Background()
+	m := NewMemory(client, "test-session", WithGraphEpisodes(graph, "sarah"))
+
+	if err := m.ChatHistory.AddUserMessage(ctx, "What's the weather?"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := m.ChatHistory.AddMessage(ctx, llms.ToolChatMessage{ID: "call-1", Content: `{"temp":21}`}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := m.ChatHistory.AddMessage(ctx, llms.FunctionChatMessage{Name: "lookup", Content: "sunny"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(rec.requests) != 1 || len(rec.requests[0].Messages) != 1 {
+		t.Fatalf("Expected only the user message in the session, got %v", rec.requests)
+	}
+	if len(rec.episodes) != 2 {
+		t.Fatalf("Expected 2 episodes, got %d", len(rec.episodes))
+	}
+	if rec.episodes[0].UserID != "sarah" || rec.episodes[0].Type != EpisodeTypeJSON {
+		t.Errorf("Expected a JSON episode for sarah, got %+v", rec.episodes[0])
+	}
+	if rec.episodes[1].Type != EpisodeTypeText || rec.episodes[1].SourceDescription != "function lookup output" {
+		t.Errorf("Expected a text episode from function lookup, got %+v", rec.episodes[1])
+	}
+}
+
+func TestToolMessagesWithoutGraphEpisodes(t *testing.T) {
+	t.Parallel()
+
+	rec, client, _ := newEpisodeServer(t)
+	m := NewMemory(client, "test-session")
+
+	if err := m.ChatHistory.AddMessage(context.Background(), llms.ToolChatMessage{Content: "result"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(rec.episodes) != 0 || len(rec.requests) != 1 {
+		t.Errorf("Expected tool message to be stored in the session, got %d episodes and %d requests",
+			len(rec.episodes), len(rec.requests))
+	}
+}
+
+func TestIgnoredRoles(t *testing.T) {
+	t.Parallel()
+
+	rec, client, graph := newEpisodeServer(t)
+	ctx := context.Background()
+	m := NewMemory(client, "test-session",
+		WithGraphEpisodes(graph, "sarah"),
+		WithEpisodeRoles(),
+		WithIgnoredRoles(llms.ChatMessageTypeAI, llms.ChatMessageTypeTool),
+	)
+
+	if err := m.ChatHistory.AddMessage(ctx, llms.ToolChatMessage{Content: "result"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(rec.episodes) != 0 || len(rec.requests) != 1 {
+		t.Fatalf("Expected tool message to be stored in the session, got %d episodes", len(rec.episodes))
+	}
+	roles := rec.requests[0].IgnoreRoles
+	if len(roles) != 2 || roles[0] != "assistant" || roles[1] != "tool" {
+		t.Errorf("Expected ignore_roles [assistant tool], got %v", roles)
+	}
+
+	noGraph := NewMemory(client, "test-session", WithIgnoredRoles(llms.ChatMessageTypeTool))
+	if err := noGraph.ChatHistory.AddUserMessage(ctx, "hi"); err != nil {
+		t.Fatalf("Expected ignored roles without graph episodes, got %v", err)
+	}
+	if roles := rec.requests[1].IgnoreRoles; len(roles) != 1 || roles[0] != "tool" {
+		t.Errorf("Expected ignore_roles [tool], got %v", roles)
+	}
+}
+
+// countingTransport counts the requests it sends.
+type countingTransport struct {
+	requests atomic.Int32
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.requests.Add(1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestIgnoredRolesKeepHTTPClient(t *testing.T) {
+	t.Parallel()
+
+	rec, _, _ := newEpisodeServer(t)
+	transport := &countingTransport{}
+	httpClient := &http.Client{Transport: transport}
+	client := zepClient.NewClient(option.WithBaseURL(rec.url), option.WithAPIKey("test"), option.WithHTTPClient(httpClient))
+	m := NewMemory(client, "test-session", WithIgnoredRoles(llms.ChatMessageTypeTool), WithHTTPClient(httpClient))
+
+	if err := m.ChatHistory.AddUserMessage(context.Background(), "hi"); err != nil {
+		t.Fatalf("AddUserMessage: %v", err)
+	}
+	if n := transport.requests.Load(); n != 1 || len(rec.requests) != 1 || len(rec.requests[0].IgnoreRoles) != 1 {
+		t.Errorf("Expected the request with ignore_roles to go through the HTTP client, got %d requests", n)
+	}
+}
+
+func TestIgnoreRolesClientPassesThrough(t *testing.T) {
+	t.Parallel()
+
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+	}))
+	t.Cleanup(server.Close)
+	c := newIgnoreRolesClient(nil, []zep.RoleType{zep.RoleTypeToolRole})
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/sessions", strings.NewReader(`{"session_id":"s"}`))
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	resp.Body.Close()
+	if body != `{"session_id":"s"}` {
+		t.Errorf("Expected other requests to pass through untouched, got %s", body)
+	}
+}
+
+func TestEpisodesNeedUser(t *testing.T) {
+	t.Parallel()
+
+	rec, client, graph := newEpisodeServer(t)
+	ctx := context.Background()
+	h := NewZepChatMessageHistory(client, "test-session", WithChatHistoryGraphEpisodes(graph, ""))
+
+	err := h.AddMessages(ctx, []llms.ChatMessage{llms.HumanChatMessage{Content: "hi"}, llms.ToolChatMessage{Content: "result"}})
+	if !errors.Is(err, core.ErrNoIdentity) {
+		t.Errorf("Expected ErrNoIdentity for an episode without a user, got %v", err)
+	}
+	if err := h.AddDocuments(ctx, []schema.Document{{PageContent: "doc"}}); !errors.Is(err, core.ErrNoIdentity) {
+		t.Errorf("Expected ErrNoIdentity for documents without a user, got %v", err)
+	}
+	if len(rec.episodes) != 0 || len(rec.requests) != 0 {
+		t.Errorf("Expected nothing to be written, got %d episodes and %d requests", len(rec.episodes), len(rec.requests))
+	}
+	if err := h.AddUserMessage(ctx, "hi"); err != nil {
+		t.Errorf("Expected messages of the session to need no user, got %v", err)
+	}
+}
+
+func TestEncryptionKeepsMessagesOutOfGraph(t *testing.T) {
+	t.Parallel()
+
+	rec, client, graph := newEpisodeServer(t)
+	ctx := context.Background()
+	keys := core.NewStaticKeyProvider("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+	for _, m := range []*Memory{
+		NewMemory(client, "test-session", WithEncryption(keys)),
+		NewMemory(client, "test-session", WithEncryption(keys), WithGraphEpisodes(graph, "sarah")),
+	} {
+		if err := m.ChatHistory.AddMessage(ctx, llms.ToolChatMessage{Content: "result"}); err != nil {
+			t.Fatalf("AddMessage: %v", err)
+		}
+	}
+	if len(rec.episodes) != 0 || len(rec.requests) != 2 {
+		t.Fatalf("Expected encrypted messages to be stored in the session only, got %d episodes", len(rec.episodes))
+	}
+	for _, request := range rec.requests {
+		if len(request.IgnoreRoles) != len(allRoleTypes) {
+			t.Errorf("Expected every role to be kept out of the graph, got %v", request.IgnoreRoles)
+		}
+	}
+}
+
+func TestMixedBatchPartialWrite(t *testing.T) {
+	t.Parallel()
+
+	rec, client, graph := newEpisodeServer(t)
+	rec.failEpisodes = true
+	m := NewMemory(client, "test-session", WithGraphEpisodes(graph, "sarah"))
+
+	err := m.ChatHistory.(*ChatMessageHistory).AddMessages(context.Background(), []llms.ChatMessage{
+		llms.ToolChatMessage{Content: "result"},
+		llms.HumanChatMessage{Content: "thanks"},
+	})
+	var partial *core.PartialWriteError
+	if !errors.As(err, &partial) || len(partial.Written) != 1 || partial.Written[0].GetContent() != "thanks" {
+		t.Fatalf("Expected a partial write of the dialogue, got %v", err)
+	}
+	if len(rec.requests) != 1 || len(rec.episodes) != 0 {
+		t.Errorf("Expected the dialogue to be written before the episode, got %d requests and %d episodes",
+			len(rec.requests), len(rec.episodes))
+	}
+}
+
+func TestAddDocuments(t *testing.T) {
+	t.Parallel()
+
+	rec, client, graph := newEpisodeServer(t)
+	ctx := context.Background()
+	m := NewMemory(client, "test-session", WithGraphEpisodes(graph, "sarah"))
+
+	err := m.AddDocuments(ctx, []schema.Document{
+		{PageContent: "TechCorp uses Kubernetes", Metadata: map[string]any{"source": "wiki/techcorp"}},
+		{PageContent: "Untitled"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(rec.episodes) != 2 || len(rec.requests) != 0 {
+		t.Fatalf("Expected 2 episodes and no session messages, got %d and %d", len(rec.episodes), len(rec.requests))
+	}
+	if rec.episodes[0].SourceDescription != "wiki/techcorp" {
+		t.Errorf("Expected source description from metadata, got %q", rec.episodes[0].SourceDescription)
+	}
+
+	if err := NewMemory(client, "test-session").AddDocuments(ctx, nil); !errors.Is(err, ErrGraphEpisodesDisabled) {
+		t.Errorf("Expected ErrGraphEpisodesDisabled, got %v", err)
+	}
+}
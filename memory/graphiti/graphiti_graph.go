@@ -0,0 +1,472 @@
+package graphiti
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/getzep/zep-go"
+	"github.com/getzep/zep-go/core"
+	"github.com/getzep/zep-go/option"
+)
+
+const defaultGraphBaseURL = "https://api.getzep.com/api/v2"
+
+// Node is an entity node in the Zep knowledge graph.
+type Node struct {
+	UUID       string         `json:"uuid"`
+	Name       string         `json:"name"`
+	Summary    string         `json:"summary,omitempty"`
+	Labels     []string       `json:"labels,omitempty"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+	CreatedAt  *time.Time     `json:"created_at,omitempty"`
+}
+
+// Edge is a fact connecting two entity nodes in the Zep knowledge graph. Name holds the
+// relation type, e.g. "WORKS_AT", and the validity timestamps describe when the fact held.
+type Edge struct {
+	UUID           string         `json:"uuid"`
+	Name           string         `json:"name"`
+	Fact           string         `json:"fact"`
+	SourceNodeUUID string         `json:"source_node_uuid"`
+	TargetNodeUUID string         `json:"target_node_uuid"`
+	Episodes       []string       `json:"episodes,omitempty"`
+	Attributes     map[string]any `json:"attributes,omitempty"`
+	CreatedAt      *time.Time     `json:"created_at,omitempty"`
+	ValidAt        *time.Time     `json:"valid_at,omitempty"`
+	InvalidAt      *time.Time     `json:"invalid_at,omitempty"`
+	ExpiredAt      *time.Time     `json:"expired_at,omitempty"`
+}
+
+// IsValidAt reports whether the edge held at the given time. Edges without a valid_at
+// timestamp are considered valid since the beginning of time.
+func (e Edge) IsValidAt(t time.Time) bool {
+	if e.ValidAt != nil && e.ValidAt.After(t) {
+		return false
+	}
+	if e.InvalidAt != nil && !e.InvalidAt.After(t) {
+		return false
+	}
+	if e.ExpiredAt != nil && !e.ExpiredAt.After(t) {
+		return false
+	}
+	return true
+}
+
+// Other returns the UUID of the node on the other end of the edge from nodeUUID.
+func (e Edge) Other(nodeUUID string) string {
+	if e.SourceNodeUUID == nodeUUID {
+		return e.TargetNodeUUID
+	}
+	return e.SourceNodeUUID
+}
+
+// Hop is a single step of a Path: the edge that was followed and the node it led to.
+type Hop struct {
+	Edge Edge
+	Node Node
+}
+
+// Path is a chain of hops starting from a node, e.g. user → works at → company → uses → product.
+type Path struct {
+	Start Node
+	Hops  []Hop
+}
+
+// End returns the last node on the path.
+func (p Path) End() Node {
+	if len(p.Hops) == 0 {
+		return p.Start
+	}
+	return p.Hops[len(p.Hops)-1].Node
+}
+
+// String renders the path as "start -[RELATION]-> node <-[RELATION]- node", keeping the
+// direction of every edge.
+func (p Path) String() string {
+	var sb strings.Builder
+	sb.WriteString(p.Start.Name)
+	current := p.Start.UUID
+	for _, hop := range p.Hops {
+		if hop.Edge.SourceNodeUUID == current {
+			fmt.Fprintf(&sb, " -[%s]-> %s", hop.Edge.Name, hop.Node.Name)
+		} else {
+			fmt.Fprintf(&sb, " <-[%s]- %s", hop.Edge.Name, hop.Node.Name)
+		}
+		current = hop.Node.UUID
+	}
+	return sb.String()
+}
+
+// GraphClient reads the Zep knowledge graph. The zep-go client does not expose the graph
+// endpoints, so GraphClient issues the calls itself using the same request options.
+type GraphClient struct {
+	baseURL    string
+	caller     *core.Caller
+	header     http.Header
+	httpClient core.HTTPClient
+}
+
+// NewGraphClient creates a new GraphClient. It accepts the same options as zepClient.NewClient
+// and likewise falls back to the ZEP_API_KEY environment variable.
+func NewGraphClient(opts ...option.RequestOption) *GraphClient {
+	options := core.NewRequestOptions(opts...)
+	if options.APIKey == "" {
+		options.APIKey = os.Getenv("ZEP_API_KEY")
+	}
+	baseURL := defaultGraphBaseURL
+	if options.BaseURL != "" {
+		baseURL = options.BaseURL
+	}
+	httpClient := options.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	return &GraphClient{
+		baseURL:    baseURL,
+		httpClient: httpClient,
+		caller: core.NewCaller(
+			&core.CallerParams{
+				Client:      options.HTTPClient,
+				MaxAttempts: options.MaxAttempts,
+			},
+		),
+		header: options.ToHeader(),
+	}
+}
+
+func graphErrorDecoder(statusCode int, body io.Reader) error {
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	apiError := core.NewAPIError(statusCode, errors.New(string(raw)))
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	switch statusCode {
+	case 400:
+		value := new(zep.BadRequestError)
+		value.APIError = apiError
+		if err := decoder.Decode(value); err != nil {
+			return apiError
+		}
+		return value
+	case 404:
+		value := new(zep.NotFoundError)
+		value.APIError = apiError
+		if err := decoder.Decode(value); err != nil {
+			return apiError
+		}
+		return value
+	case 500:
+		value := new(zep.InternalServerError)
+		value.APIError = apiError
+		if err := decoder.Decode(value); err != nil {
+			return apiError
+		}
+		return value
+	}
+	return apiError
+}
+
+func (c *GraphClient) call(ctx context.Context, method, path string, request, response any) error {
+	err := c.caller.Call(
+		ctx,
+		&core.CallParams{
+			URL:          c.baseURL + path,
+			Method:       method,
+			Headers:      c.header.Clone(),
+			Request:      request,
+			Response:     response,
+			ErrorDecoder: graphErrorDecoder,
+		},
+	)
+	return translateError(ctx, err)
+}
+
+type graphPageRequest struct {
+	Limit      *int    `json:"limit,omitempty"`
+	UUIDCursor *string `json:"uuid_cursor,omitempty"`
+}
+
+// graphPageSize is the page size used when listing all nodes or edges of a graph.
+const graphPageSize = 100
+
+// Node returns the node with the given UUID.
+func (c *GraphClient) Node(ctx context.Context, uuid string) (*Node, error) {
+	var node *Node
+	if err := c.call(ctx, http.MethodGet, core.EncodeURL("/graph/node/%v", uuid), nil, &node); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// Edge returns the edge with the given UUID.
+func (c *GraphClient) Edge(ctx context.Context, uuid string) (*Edge, error) {
+	var edge *Edge
+	if err := c.call(ctx, http.MethodGet, core.EncodeURL("/graph/edge/%v", uuid), nil, &edge); err != nil {
+		return nil, err
+	}
+	return edge, nil
+}
+
+// UserNode returns the node that represents the user in their graph.
+func (c *GraphClient) UserNode(ctx context.Context, userID string) (*Node, error) {
+	var response struct {
+		Node *Node `json:"node"`
+	}
+	if err := c.call(ctx, http.MethodGet, core.EncodeURL("/users/%v/node", userID), nil, &response); err != nil {
+		return nil, err
+	}
+	if response.Node == nil {
+		return nil, fmt.Errorf("no graph node for user %s", userID)
+	}
+	return response.Node, nil
+}
+
+// NodeEdges returns all edges that start or end at the given node.
+func (c *GraphClient) NodeEdges(ctx context.Context, nodeUUID string) ([]Edge, error) {
+	var edges []Edge
+	path := core.EncodeURL("/graph/node/%v/entity-edges", nodeUUID)
+	if err := c.call(ctx, http.MethodGet, path, nil, &edges); err != nil {
+		return nil, err
+	}
+	return edges, nil
+}
+
+// UserNodes returns every node in the user's graph.
+func (c *GraphClient) UserNodes(ctx context.Context, userID string) ([]Node, error) {
+	return listGraph[Node](ctx, c, core.EncodeURL("/graph/node/user/%v", userID), func(n Node) string { return n.UUID })
+}
+
+// UserEdges returns every edge in the user's graph.
+func (c *GraphClient) UserEdges(ctx context.Context, userID string) ([]Edge, error) {
+	return listGraph[Edge](ctx, c, core.EncodeURL("/graph/edge/user/%v", userID), func(e Edge) string { return e.UUID })
+}
+
+// GroupNodes returns every node in the group's graph.
+func (c *GraphClient) GroupNodes(ctx context.Context, groupID string) ([]Node, error) {
+	return listGraph[Node](ctx, c, core.EncodeURL("/graph/node/group/%v", groupID), func(n Node) string { return n.UUID })
+}
+
+// GroupEdges returns every edge in the group's graph.
+func (c *GraphClient) GroupEdges(ctx context.Context, groupID string) ([]Edge, error) {
+	return listGraph[Edge](ctx, c, core.EncodeURL("/graph/edge/group/%v", groupID), func(e Edge) string { return e.UUID })
+}
+
+// listGraph pages through a node or edge listing endpoint using the UUID cursor.
+func listGraph[T any](ctx context.Context, c *GraphClient, path string, uuidOf func(T) string) ([]T, error) {
+	var all []T
+	request := graphPageRequest{Limit: zep.Int(graphPageSize)}
+	for {
+		var page []T
+		if err := c.call(ctx, http.MethodPost, path, &request, &page); err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if len(page) < graphPageSize {
+			return all, nil
+		}
+		request.UUIDCursor = zep.String(uuidOf(page[len(page)-1]))
+	}
+}
+
+// EpisodeType is the kind of data an Episode carries.
+type EpisodeType string
+
+const (
+	// EpisodeTypeText is free-form text such as a retrieved document.
+	EpisodeTypeText EpisodeType = "text"
+	// EpisodeTypeJSON is a JSON document such as a structured tool result.
+	EpisodeTypeJSON EpisodeType = "json"
+)
+
+// Episode is non-message data added to a graph. Zep extracts facts from it like it does
+// from chat messages, but it never shows up in a session's messages.
+type Episode struct {
+	UserID            string      `json:"user_id,omitempty"`
+	GroupID           string      `json:"group_id,omitempty"`
+	Type              EpisodeType `json:"type"`
+	Data              string      `json:"data"`
+	SourceDescription string      `json:"source_description,omitempty"`
+}
+
+// AddEpisode adds an episode to the user or group graph named in the episode.
+func (c *GraphClient) AddEpisode(ctx context.Context, episode Episode) error {
+	return c.call(ctx, http.MethodPost, "/graph", &episode, nil)
+}
+
+// ignoreRolesClient adds the roles Zep keeps out of the graph to the requests of
+// zepClient.Memory.Add, whose zep.AddMemoryRequest has no field for them, and sends them with
+// next. Every other request is passed to next untouched.
+type ignoreRolesClient struct {
+	next  core.HTTPClient
+	roles []zep.RoleType
+}
+
+// newIgnoreRolesClient returns a client sending the requests of zepClient.Memory.Add with
+// next, or with http.DefaultClient, which zep-go defaults to, if next is nil.
+func newIgnoreRolesClient(next core.HTTPClient, roles []zep.RoleType) *ignoreRolesClient {
+	if next == nil {
+		next = http.DefaultClient
+	}
+	return &ignoreRolesClient{next: next, roles: roles}
+}
+
+// addsMemory reports whether req is a request of zepClient.Memory.Add, a POST to
+// /sessions/{sessionId}/memory.
+func addsMemory(req *http.Request) bool {
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	return req.Method == http.MethodPost && strings.HasSuffix(path, "/memory") &&
+		strings.Contains(path, "/sessions/") && req.Body != nil
+}
+
+func (c *ignoreRolesClient) Do(req *http.Request) (*http.Response, error) {
+	if !addsMemory(req) {
+		return c.next.Do(req)
+	}
+	var body map[string]json.RawMessage
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("adding ignore_roles: %w", err)
+	}
+	req.Body.Close()
+	roles, err := json.Marshal(c.roles)
+	if err != nil {
+		return nil, err
+	}
+	body["ignore_roles"] = roles
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.ContentLength = int64(len(data))
+	return c.next.Do(req)
+}
+
+// Neighbours returns the nodes connected to the given node by an edge that passes the
+// traversal filters, in the order the edges were returned.
+func (c *GraphClient) Neighbours(ctx context.Context, nodeUUID string, options ...TraversalOption) ([]Node, error) {
+	opts := applyTraversalOptions(options...)
+	edges, err := c.NodeEdges(ctx, nodeUUID)
+	if err != nil {
+		return nil, err
+	}
+	var neighbours []Node
+	seen := map[string]bool{}
+	for _, edge := range edges {
+		if !opts.follows(edge, nodeUUID) {
+			continue
+		}
+		other := edge.Other(nodeUUID)
+		if seen[other] {
+			continue
+		}
+		seen[other] = true
+		node, err := c.Node(ctx, other)
+		if err != nil {
+			return nil, err
+		}
+		neighbours = append(neighbours, *node)
+	}
+	return neighbours, nil
+}
+
+// EdgesBetween returns the edges connecting the two nodes, in either direction, that pass the
+// traversal filters.
+func (c *GraphClient) EdgesBetween(ctx context.Context, nodeUUID, otherUUID string, options ...TraversalOption) ([]Edge, error) {
+	opts := applyTraversalOptions(options...)
+	edges, err := c.NodeEdges(ctx, nodeUUID)
+	if err != nil {
+		return nil, err
+	}
+	var between []Edge
+	for _, edge := range edges {
+		if edge.Other(nodeUUID) == otherUUID && opts.follows(edge, nodeUUID) {
+			between = append(between, edge)
+		}
+	}
+	return between, nil
+}
+
+// Walk follows edges from the user's node for up to hops steps and returns every path
+// found along the way, shortest first. Paths never visit the same node twice.
+func (c *GraphClient) Walk(ctx context.Context, userID string, hops int, options ...TraversalOption) ([]Path, error) {
+	start, err := c.UserNode(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return c.WalkFrom(ctx, *start, hops, options...)
+}
+
+// WalkFrom is like Walk but starts from an arbitrary node.
+func (c *GraphClient) WalkFrom(ctx context.Context, start Node, hops int, options ...TraversalOption) ([]Path, error) {
+	opts := applyTraversalOptions(options...)
+	nodes := map[string]Node{start.UUID: start}
+	edgesOf := map[string][]Edge{}
+
+	var paths []Path
+	frontier := []Path{{Start: start}}
+	for depth := 0; depth < hops && len(frontier) > 0; depth++ {
+		var next []Path
+		for _, path := range frontier {
+			current := path.End().UUID
+			edges, ok := edgesOf[current]
+			if !ok {
+				var err error
+				if edges, err = c.NodeEdges(ctx, current); err != nil {
+					return nil, err
+				}
+				edgesOf[current] = edges
+			}
+			for _, edge := range edges {
+				if !opts.follows(edge, current) {
+					continue
+				}
+				otherUUID := edge.Other(current)
+				if path.visits(otherUUID) {
+					continue
+				}
+				other, ok := nodes[otherUUID]
+				if !ok {
+					node, err := c.Node(ctx, otherUUID)
+					if err != nil {
+						return nil, err
+					}
+					other = *node
+					nodes[otherUUID] = other
+				}
+				extended := Path{
+					Start: path.Start,
+					Hops:  append(append([]Hop(nil), path.Hops...), Hop{Edge: edge, Node: other}),
+				}
+				if opts.maxPaths > 0 && len(paths)+len(next) >= opts.maxPaths {
+					return append(paths, next...), nil
+				}
+				next = append(next, extended)
+			}
+		}
+		paths = append(paths, next...)
+		frontier = next
+	}
+	return paths, nil
+}
+
+func (p Path) visits(nodeUUID string) bool {
+	if p.Start.UUID == nodeUUID {
+		return true
+	}
+	for _, hop := range p.Hops {
+		if hop.Node.UUID == nodeUUID {
+			return true
+		}
+	}
+	return false
+}
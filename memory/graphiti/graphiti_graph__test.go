@@ -0,0 +1,175 @@
+package graphiti
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getzep/zep-go/option"
+)
+
+// newTestGraph serves a small user graph:
+// sarah -WORKS_AT-> techcorp -USES-> kubernetes, plus an invalidated sarah -WORKS_AT-> oldcorp.
+func newTestGraph(t *testing.T) *GraphClient {
+	t.Helper()
+
+	past := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	ended := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	nodes := map[string]Node{
+		"n-sarah":    {UUID: "n-sarah", Name: "Sarah"},
+		"n-techcorp": {UUID: "n-techcorp", Name: "TechCorp"},
+		"n-k8s":      {UUID: "n-k8s", Name: "Kubernetes"},
+		"n-oldcorp":  {UUID: "n-oldcorp", Name: "OldCorp"},
+	}
+	edges := []Edge{
+		{UUID: "e1", Name: "WORKS_AT", SourceNodeUUID: "n-sarah", TargetNodeUUID: "n-techcorp", ValidAt: &ended},
+		{UUID: "e2", Name: "USES", SourceNodeUUID: "n-techcorp", TargetNodeUUID: "n-k8s"},
+		{UUID: "e3", Name: "WORKS_AT", SourceNodeUUID: "n-sarah", TargetNodeUUID: "n-oldcorp", ValidAt: &past, InvalidAt: &ended},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{userID}/node", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("userID") != "sarah" {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"node": nodes["n-sarah"]})
+	})
+	mux.HandleFunc("GET /graph/node/{uuid}", func(w http.ResponseWriter, r *http.Request) {
+		node, ok := nodes[r.PathValue("uuid")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(node)
+	})
+	mux.HandleFunc("GET /graph/node/{uuid}/entity-edges", func(w http.ResponseWriter, r *http.Request) {
+		uuid := r.PathValue("uuid")
+		result := []Edge{}
+		for _, edge := range edges {
+			if edge.SourceNodeUUID == uuid || edge.TargetNodeUUID == uuid {
+				result = append(result, edge)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(result)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return NewGraphClient(option.WithBaseURL(server.URL), option.WithAPIKey("test"))
+}
+
+func TestGraphNeighbours(t *testing.T) {
+	t.Parallel()
+
+	graph := newTestGraph(t)
+	ctx := context.Background()
+
+	neighbours, err := graph.Neighbours(ctx, "n-techcorp")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(neighbours) != 2 {
+		t.Fatalf("Expected 2 neighbours, got %d", len(neighbours))
+	}
+	if neighbours[0].Name != "Sarah" || neighbours[1].Name != "Kubernetes" {
+		t.Errorf("Expected neighbours Sarah and Kubernetes, got %v", neighbours)
+	}
+
+	neighbours, err = graph.Neighbours(ctx, "n-techcorp", WithDirection(DirectionOutgoing))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(neighbours) != 1 || neighbours[0].Name != "Kubernetes" {
+		t.Errorf("Expected only outgoing neighbour Kubernetes, got %v", neighbours)
+	}
+}
+
+func TestGraphEdgesBetween(t *testing.T) {
+	t.Parallel()
+
+	graph := newTestGraph(t)
+
+	edges, err := graph.EdgesBetween(context.Background(), "n-techcorp", "n-sarah")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(edges) != 1 || edges[0].UUID != "e1" {
+		t.Errorf("Expected edge e1 between TechCorp and Sarah, got %v", edges)
+	}
+}
+
+func TestGraphWalk(t *testing.T) {
+	t.Parallel()
+
+	graph := newTestGraph(t)
+	ctx := context.Background()
+
+	paths, err := graph.Walk(ctx, "sarah", 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(paths) != 3 {
+		t.Fatalf("Expected 3 paths, got %d: %v", len(paths), paths)
+	}
+	expected := "Sarah -[WORKS_AT]-> TechCorp -[USES]-> Kubernetes"
+	if paths[2].String() != expected {
+		t.Errorf("Expected path %q, got %q", expected, paths[2].String())
+	}
+
+	paths, err = graph.Walk(ctx, "sarah", 2, WithValidAt(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, path := range paths {
+		if path.End().Name == "OldCorp" {
+			t.Errorf("Expected invalidated edge to be skipped, got %s", path)
+		}
+	}
+
+	paths, err = graph.Walk(ctx, "sarah", 2, WithEdgeTypes("WORKS_AT"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(paths) != 2 {
+		t.Errorf("Expected 2 WORKS_AT paths, got %d", len(paths))
+	}
+	for _, path := range paths {
+		if strings.Contains(path.String(), "USES") {
+			t.Errorf("Expected USES edges to be filtered out, got %s", path)
+		}
+	}
+}
+
+func TestGraphWalkUnknownUser(t *testing.T) {
+	t.Parallel()
+
+	graph := newTestGraph(t)
+
+	_, err := graph.Walk(context.Background(), "nobody", 1)
+	if err == nil {
+		t.Errorf("Expected error for unknown user")
+	}
+}
+
+func TestEdgeIsValidAt(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	edge := Edge{ValidAt: &start, InvalidAt: &end}
+
+	if edge.IsValidAt(start.Add(-time.Hour)) {
+		t.Errorf("Expected edge to be invalid before valid_at")
+	}
+	if !edge.IsValidAt(start.Add(time.Hour)) {
+		t.Errorf("Expected edge to be valid between valid_at and invalid_at")
+	}
+	if edge.IsValidAt(end) {
+		t.Errorf("Expected edge to be invalid at invalid_at")
+	}
+}
@@ -0,0 +1,326 @@
+package graphiti
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ExportFormat is a file format a Graph can be written as.
+type ExportFormat string
+
+const (
+	// ExportFormatDOT writes a Graphviz DOT digraph.
+	ExportFormatDOT ExportFormat = "dot"
+	// ExportFormatGraphML writes a GraphML document.
+	ExportFormatGraphML ExportFormat = "graphml"
+	// ExportFormatMermaid writes a Mermaid flowchart.
+	ExportFormatMermaid ExportFormat = "mermaid"
+)
+
+// Graph is a snapshot of all nodes and edges of a user or group graph.
+type Graph struct {
+	Nodes []Node
+	Edges []Edge
+}
+
+// UserGraph fetches every node and edge of the user's graph.
+func (c *GraphClient) UserGraph(ctx context.Context, userID string) (*Graph, error) {
+	nodes, err := c.UserNodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	edges, err := c.UserEdges(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &Graph{Nodes: nodes, Edges: edges}, nil
+}
+
+// GroupGraph fetches every node and edge of the group's graph.
+func (c *GraphClient) GroupGraph(ctx context.Context, groupID string) (*Graph, error) {
+	nodes, err := c.GroupNodes(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	edges, err := c.GroupEdges(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	return &Graph{Nodes: nodes, Edges: edges}, nil
+}
+
+// ExportUserGraph fetches the user's graph and writes it to w in the given format.
+func (c *GraphClient) ExportUserGraph(ctx context.Context, userID string, w io.Writer, format ExportFormat) error {
+	graph, err := c.UserGraph(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return graph.Export(w, format)
+}
+
+// ExportGroupGraph fetches the group's graph and writes it to w in the given format.
+func (c *GraphClient) ExportGroupGraph(ctx context.Context, groupID string, w io.Writer, format ExportFormat) error {
+	graph, err := c.GroupGraph(ctx, groupID)
+	if err != nil {
+		return err
+	}
+	return graph.Export(w, format)
+}
+
+// Export writes the graph to w in the given format.
+func (g *Graph) Export(w io.Writer, format ExportFormat) error {
+	switch format {
+	case ExportFormatDOT:
+		return g.WriteDOT(w)
+	case ExportFormatGraphML:
+		return g.WriteGraphML(w)
+	case ExportFormatMermaid:
+		return g.WriteMermaid(w)
+	default:
+		return fmt.Errorf("unknown export format: %s", format)
+	}
+}
+
+// property is a single exported key/value pair of a node or edge.
+type property struct {
+	key   string
+	value string
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatAttribute(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// attributeProperties returns the custom attributes sorted by key, prefixed with "attr." so
+// they can't collide with the built-in properties.
+func attributeProperties(attributes map[string]any) []property {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	properties := make([]property, 0, len(keys))
+	for _, key := range keys {
+		properties = append(properties, property{key: "attr." + key, value: formatAttribute(attributes[key])})
+	}
+	return properties
+}
+
+func nodeProperties(n Node) []property {
+	properties := []property{
+		{key: "uuid", value: n.UUID},
+		{key: "name", value: n.Name},
+		{key: "summary", value: n.Summary},
+		{key: "labels", value: strings.Join(n.Labels, ",")},
+		{key: "created_at", value: formatTime(n.CreatedAt)},
+	}
+	return append(nonEmpty(properties), attributeProperties(n.Attributes)...)
+}
+
+func edgeProperties(e Edge) []property {
+	properties := []property{
+		{key: "uuid", value: e.UUID},
+		{key: "name", value: e.Name},
+		{key: "fact", value: e.Fact},
+		{key: "episodes", value: strings.Join(e.Episodes, ",")},
+		{key: "created_at", value: formatTime(e.CreatedAt)},
+		{key: "valid_at", value: formatTime(e.ValidAt)},
+		{key: "invalid_at", value: formatTime(e.InvalidAt)},
+		{key: "expired_at", value: formatTime(e.ExpiredAt)},
+	}
+	return append(nonEmpty(properties), attributeProperties(e.Attributes)...)
+}
+
+func nonEmpty(properties []property) []property {
+	filtered := properties[:0]
+	for _, p := range properties {
+		if p.value != "" {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+func dotAttributes(label string, properties []property) string {
+	parts := []string{"label=" + dotQuote(label)}
+	for _, p := range properties {
+		parts = append(parts, dotQuote(p.key)+"="+dotQuote(p.value))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// WriteDOT writes the graph as a Graphviz digraph. Every node and edge property, including
+// custom attributes and validity timestamps, is kept as a DOT attribute.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph zep {")
+	for _, n := range g.Nodes {
+		fmt.Fprintf(bw, "  %s [%s];\n", dotQuote(n.UUID), dotAttributes(n.Name, nodeProperties(n)))
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(bw, "  %s -> %s [%s];\n",
+			dotQuote(e.SourceNodeUUID), dotQuote(e.TargetNodeUUID), dotAttributes(e.Name, edgeProperties(e)))
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+type graphMLKey struct {
+	XMLName  xml.Name `xml:"key"`
+	ID       string   `xml:"id,attr"`
+	For      string   `xml:"for,attr"`
+	AttrName string   `xml:"attr.name,attr"`
+	AttrType string   `xml:"attr.type,attr"`
+}
+
+type graphMLData struct {
+	XMLName xml.Name `xml:"data"`
+	Key     string   `xml:"key,attr"`
+	Value   string   `xml:",chardata"`
+}
+
+type graphMLNode struct {
+	XMLName xml.Name      `xml:"node"`
+	ID      string        `xml:"id,attr"`
+	Data    []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	XMLName xml.Name      `xml:"edge"`
+	ID      string        `xml:"id,attr"`
+	Source  string        `xml:"source,attr"`
+	Target  string        `xml:"target,attr"`
+	Data    []graphMLData `xml:"data"`
+}
+
+type graphMLGraph struct {
+	XMLName     xml.Name      `xml:"graph"`
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+// WriteGraphML writes the graph as a GraphML document. Every property is declared as a
+// string key so that custom attributes survive the round trip.
+func (g *Graph) WriteGraphML(w io.Writer) error {
+	doc := graphMLDocument{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Graph: graphMLGraph{ID: "zep", EdgeDefault: "directed"},
+	}
+	keys := map[string]bool{}
+	addData := func(domain string, properties []property) []graphMLData {
+		data := make([]graphMLData, 0, len(properties))
+		for _, p := range properties {
+			id := domain + "." + p.key
+			if !keys[id] {
+				keys[id] = true
+				doc.Keys = append(doc.Keys, graphMLKey{ID: id, For: domain, AttrName: p.key, AttrType: "string"})
+			}
+			data = append(data, graphMLData{Key: id, Value: p.value})
+		}
+		return data
+	}
+	for _, n := range g.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{ID: n.UUID, Data: addData("node", nodeProperties(n))})
+	}
+	for _, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			ID:     e.UUID,
+			Source: e.SourceNodeUUID,
+			Target: e.TargetNodeUUID,
+			Data:   addData("edge", edgeProperties(e)),
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func mermaidQuote(s string) string {
+	s = strings.ReplaceAll(s, `"`, "#quot;")
+	s = strings.ReplaceAll(s, "\n", "<br/>")
+	return `"` + s + `"`
+}
+
+func mermaidComment(properties []property) string {
+	values := make(map[string]string, len(properties))
+	for _, p := range properties {
+		values[p.key] = p.value
+	}
+	b, _ := json.Marshal(values)
+	return string(b)
+}
+
+// WriteMermaid writes the graph as a Mermaid flowchart. Mermaid has no notion of custom
+// attributes, so edge labels show the validity window and all properties are kept as
+// %% comments next to each node and edge.
+func (g *Graph) WriteMermaid(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "flowchart LR")
+	ids := map[string]string{}
+	idOf := func(uuid string) string {
+		id, ok := ids[uuid]
+		if !ok {
+			id = fmt.Sprintf("n%d", len(ids))
+			ids[uuid] = id
+		}
+		return id
+	}
+	for _, n := range g.Nodes {
+		fmt.Fprintf(bw, "  %%%% %s\n", mermaidComment(nodeProperties(n)))
+		fmt.Fprintf(bw, "  %s[%s]\n", idOf(n.UUID), mermaidQuote(n.Name))
+	}
+	for _, e := range g.Edges {
+		label := e.Name
+		if e.ValidAt != nil || e.InvalidAt != nil {
+			label += fmt.Sprintf("\n%s → %s", formatTime(e.ValidAt), formatTime(e.InvalidAt))
+		}
+		fmt.Fprintf(bw, "  %%%% %s\n", mermaidComment(edgeProperties(e)))
+		fmt.Fprintf(bw, "  %s -->|%s| %s\n", idOf(e.SourceNodeUUID), mermaidQuote(label), idOf(e.TargetNodeUUID))
+	}
+	return bw.Flush()
+}
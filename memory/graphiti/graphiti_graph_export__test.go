@@ -0,0 +1,157 @@
+package graphiti
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getzep/zep-go/option"
+)
+
+func testExportGraph() *Graph {
+	validAt := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &Graph{
+		Nodes: []Node{
+			{UUID: "n1", Name: "Sarah", Labels: []string{"Entity", "Person"}, Attributes: map[string]any{"role": "engineer"}},
+			{UUID: "n2", Name: `Tech "Corp"`},
+		},
+		Edges: []Edge{
+			{
+				UUID:           "e1",
+				Name:           "WORKS_AT",
+				Fact:           "Sarah works at TechCorp",
+				SourceNodeUUID: "n1",
+				TargetNodeUUID: "n2",
+				ValidAt:        &validAt,
+				Attributes:     map[string]any{"since": 2023},
+			},
+		},
+	}
+}
+
+func TestGraphWriteDOT(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := testExportGraph().Export(&buf, ExportFormatDOT); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	out := buf.String()
+
+	for _, expected := range []string{
+		`digraph zep {`,
+		`"n1" [label="Sarah", "uuid"="n1", "name"="Sarah", "labels"="Entity,Person", "attr.role"="engineer"];`,
+		`label="Tech \"Corp\""`,
+		`"n1" -> "n2" [label="WORKS_AT"`,
+		`"valid_at"="2023-01-01T00:00:00Z"`,
+		`"attr.since"="2023"`,
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected DOT output to contain %q, got:\n%s", expected, out)
+		}
+	}
+}
+
+func TestGraphWriteGraphML(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := testExportGraph().Export(&buf, ExportFormatGraphML); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var doc graphMLDocument
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Expected valid XML, got %v:\n%s", err, buf.String())
+	}
+	if len(doc.Graph.Nodes) != 2 || len(doc.Graph.Edges) != 1 {
+		t.Fatalf("Expected 2 nodes and 1 edge, got %d and %d", len(doc.Graph.Nodes), len(doc.Graph.Edges))
+	}
+	found := false
+	for _, data := range doc.Graph.Edges[0].Data {
+		if data.Key == "edge.valid_at" && data.Value == "2023-01-01T00:00:00Z" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected edge to keep its valid_at timestamp")
+	}
+	if !strings.Contains(buf.String(), `attr.name="attr.role"`) {
+		t.Errorf("Expected a key declaration for the custom node attribute")
+	}
+}
+
+func TestGraphWriteMermaid(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := testExportGraph().Export(&buf, ExportFormatMermaid); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	out := buf.String()
+
+	for _, expected := range []string{
+		"flowchart LR",
+		`n0["Sarah"]`,
+		`n1["Tech #quot;Corp#quot;"]`,
+		`n0 -->|"WORKS_AT<br/>2023-01-01T00:00:00Z → "| n1`,
+		`"attr.role":"engineer"`,
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected Mermaid output to contain %q, got:\n%s", expected, out)
+		}
+	}
+}
+
+func TestGraphExportUnknownFormat(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := testExportGraph().Export(&buf, "svg"); err == nil {
+		t.Errorf("Expected error for unknown format")
+	}
+}
+
+func TestUserGraphPaging(t *testing.T) {
+	t.Parallel()
+
+	var nodes []Node
+	for i := 0; i < graphPageSize+5; i++ {
+		nodes = append(nodes, Node{UUID: strings.Repeat("0", 4) + string(rune('a'+i%26)) + string(rune('a'+i/26))})
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /graph/node/user/{userID}", func(w http.ResponseWriter, r *http.Request) {
+		var request graphPageRequest
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		start := 0
+		if request.UUIDCursor != nil {
+			for i, node := range nodes {
+				if node.UUID == *request.UUIDCursor {
+					start = i + 1
+				}
+			}
+		}
+		end := min(start+*request.Limit, len(nodes))
+		_ = json.NewEncoder(w).Encode(nodes[start:end])
+	})
+	mux.HandleFunc("POST /graph/edge/user/{userID}", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("[]"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	graph, err := NewGraphClient(option.WithBaseURL(server.URL)).UserGraph(context.Background(), "sarah")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(graph.Nodes) != len(nodes) {
+		t.Errorf("Expected %d nodes across pages, got %d", len(nodes), len(graph.Nodes))
+	}
+}
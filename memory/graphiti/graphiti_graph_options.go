@@ -0,0 +1,88 @@
+package graphiti
+
+import "time"
+
+// Direction restricts which edges a traversal follows relative to the current node.
+type Direction int
+
+const (
+	// DirectionBoth follows edges regardless of their direction.
+	DirectionBoth Direction = iota
+	// DirectionOutgoing only follows edges whose source is the current node.
+	DirectionOutgoing
+	// DirectionIncoming only follows edges whose target is the current node.
+	DirectionIncoming
+)
+
+// TraversalOption is a function for configuring graph traversals
+// with other than the default values.
+type TraversalOption func(o *traversalOptions)
+
+type traversalOptions struct {
+	edgeTypes map[string]bool
+	validAt   *time.Time
+	direction Direction
+	maxPaths  int
+}
+
+// WithEdgeTypes is an option for only following edges with one of the given relation
+// types, e.g. "WORKS_AT".
+func WithEdgeTypes(edgeTypes ...string) TraversalOption {
+	return func(o *traversalOptions) {
+		o.edgeTypes = make(map[string]bool, len(edgeTypes))
+		for _, edgeType := range edgeTypes {
+			o.edgeTypes[edgeType] = true
+		}
+	}
+}
+
+// WithValidAt is an option for only following edges that were valid at the given time.
+// Use time.Now() to skip facts that have since been invalidated.
+func WithValidAt(t time.Time) TraversalOption {
+	return func(o *traversalOptions) {
+		o.validAt = &t
+	}
+}
+
+// WithDirection is an option for specifying which edge direction to follow.
+func WithDirection(direction Direction) TraversalOption {
+	return func(o *traversalOptions) {
+		o.direction = direction
+	}
+}
+
+// WithMaxPaths is an option for capping the number of paths a walk returns.
+func WithMaxPaths(maxPaths int) TraversalOption {
+	return func(o *traversalOptions) {
+		o.maxPaths = maxPaths
+	}
+}
+
+func (o *traversalOptions) follows(edge Edge, fromUUID string) bool {
+	if len(o.edgeTypes) > 0 && !o.edgeTypes[edge.Name] {
+		return false
+	}
+	if o.validAt != nil && !edge.IsValidAt(*o.validAt) {
+		return false
+	}
+	switch o.direction {
+	case DirectionOutgoing:
+		return edge.SourceNodeUUID == fromUUID
+	case DirectionIncoming:
+		return edge.TargetNodeUUID == fromUUID
+	default:
+		return true
+	}
+}
+
+func applyTraversalOptions(options ...TraversalOption) *traversalOptions {
+	o := &traversalOptions{
+		direction: DirectionBoth,
+	}
+
+	for _, option := range options {
+		option(o)
+	}
+
+	return o
+}
@@ -1,6 +1,15 @@
 package graphiti
 
-import "github.com/getzep/zep-go"
+import (
+	"log/slog"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/getzep/zep-go"
+	zepcore "github.com/getzep/zep-go/core"
+	"github.com/tmc/langchaingo/llms"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
 
 // MemoryOption ZepMemoryOption is a function for creating new buffer
 // with other than the default values.
@@ -9,42 +18,42 @@ type MemoryOption func(b *Memory)
 // WithReturnMessages is an option for specifying should it return messages.
 func WithReturnMessages(returnMessages bool) MemoryOption {
 	return func(b *Memory) {
-		b.ReturnMessages = returnMessages
+		core.WithReturnMessages(returnMessages)(&b.Memory)
 	}
 }
 
 // WithInputKey is an option for specifying the input key.
 func WithInputKey(inputKey string) MemoryOption {
 	return func(b *Memory) {
-		b.InputKey = inputKey
+		core.WithInputKey(inputKey)(&b.Memory)
 	}
 }
 
 // WithOutputKey is an option for specifying the output key.
 func WithOutputKey(outputKey string) MemoryOption {
 	return func(b *Memory) {
-		b.OutputKey = outputKey
+		core.WithOutputKey(outputKey)(&b.Memory)
 	}
 }
 
 // WithHumanPrefix is an option for specifying the human prefix. Will be passed as role for the message to zep.
 func WithHumanPrefix(humanPrefix string) MemoryOption {
 	return func(b *Memory) {
-		b.HumanPrefix = humanPrefix
+		core.WithHumanPrefix(humanPrefix)(&b.Memory)
 	}
 }
 
 // WithAIPrefix is an option for specifying the AI prefix. Will be passed as role for the message to zep.
 func WithAIPrefix(aiPrefix string) MemoryOption {
 	return func(b *Memory) {
-		b.AIPrefix = aiPrefix
+		core.WithAIPrefix(aiPrefix)(&b.Memory)
 	}
 }
 
 // WithMemoryKey is an option for specifying the memory key.
 func WithMemoryKey(memoryKey string) MemoryOption {
 	return func(b *Memory) {
-		b.MemoryKey = memoryKey
+		core.WithMemoryKey(memoryKey)(&b.Memory)
 	}
 }
 
@@ -55,15 +64,117 @@ func WithMemoryType(memoryType zep.MemoryType) MemoryOption {
 	}
 }
 
+// WithGraphEpisodes is an option for adding tool outputs and retrieved documents to the user's
+// graph as episodes. They are used for fact extraction but never replayed as chat turns.
+func WithGraphEpisodes(graph *GraphClient, userID string) MemoryOption {
+	return func(b *Memory) {
+		b.GraphClient = graph
+		b.UserID = userID
+	}
+}
+
+// WithEpisodeRoles is an option for specifying which message types are added as graph
+// episodes. Defaults to tool and function messages.
+func WithEpisodeRoles(roles ...llms.ChatMessageType) MemoryOption {
+	return func(b *Memory) {
+		b.EpisodeRoles = roles
+	}
+}
+
+// WithIgnoredRoles is an option for specifying message types that are kept in the session but
+// never ingested into the graph. If the zep client has an HTTP client of its own, pass it with
+// WithHTTPClient too.
+func WithIgnoredRoles(roles ...llms.ChatMessageType) MemoryOption {
+	return func(b *Memory) {
+		b.IgnoredRoles = roles
+	}
+}
+
+// WithHTTPClient is an option for specifying the HTTP client that the zep client was created
+// with, which the memory needs to send messages with ignored roles or encryption.
+func WithHTTPClient(httpClient zepcore.HTTPClient) MemoryOption {
+	return func(b *Memory) {
+		b.HTTPClient = httpClient
+	}
+}
+
+// WithMaxTokens is an option for limiting the loaded history to maxTokens tokens, as counted
+// by tokenizer. The oldest turns are dropped or truncated first; facts and summaries are kept.
+func WithMaxTokens(maxTokens int, tokenizer core.Tokenizer) MemoryOption {
+	return func(b *Memory) {
+		core.WithMaxTokens(maxTokens, tokenizer)(&b.Memory)
+	}
+}
+
+// WithRetryPolicy is an option for retrying failed requests to Zep with the given policy.
+// Messages are only re-sent when Zep did not process them. Errors are classified with
+// ClassifyError unless the policy has its own classifier.
+func WithRetryPolicy(policy core.RetryPolicy) MemoryOption {
+	return func(b *Memory) {
+		if policy.Classify == nil {
+			policy.Classify = ClassifyError
+		}
+		b.RetryPolicy = &policy
+	}
+}
+
+// WithTelemetry is an option for tracing and measuring the memory and its requests to Zep with
+// OpenTelemetry. Nil providers record nothing.
+func WithTelemetry(tracerProvider trace.TracerProvider, meterProvider metric.MeterProvider) MemoryOption {
+	return func(b *Memory) {
+		b.Telemetry = core.NewTelemetry(telemetryBackend, tracerProvider, meterProvider)
+	}
+}
+
+// WithLogger is an option for specifying the logger of the memory's events, such
+// as messages dropped because of their role. Defaults to slog.Default().
+func WithLogger(logger *slog.Logger) MemoryOption {
+	return func(b *Memory) {
+		b.Logger = logger
+	}
+}
+
+// WithRejectUnknownRoles is an option for failing loads and saves with messages of roles
+// Zep does not store, instead of dropping those messages.
+func WithRejectUnknownRoles() MemoryOption {
+	return func(b *Memory) {
+		b.RejectUnknownRoles = true
+	}
+}
+
+// WithResolver is an option for resolving the user and session of every load and save from
+// its context, such as core.ResolveFromContext, so that one memory serves every session of a
+// server. The user and session IDs of the memory are used when the resolver returns none.
+func WithResolver(resolver core.Resolver) MemoryOption {
+	return func(b *Memory) {
+		b.Resolver = resolver
+	}
+}
+
+// WithEncryption is an option for encrypting the content of messages with keys from provider
+// before they leave the process, for transcripts that Zep must store but not read. Zep is
+// told to keep them out of the graph, so the memory has no facts or summary. Messages that are
+// not encrypted are rejected on read unless core.WithAllowPlaintext is given.
+func WithEncryption(provider core.KeyProvider, options ...core.EncryptorOption) MemoryOption {
+	return func(b *Memory) {
+		b.Encryptor = core.NewEncryptor(provider, options...)
+	}
+}
+
+// WithMemoryOptions is an option for applying core.Memory options, such as options shared by
+// every backend that are not mirrored in this package.
+func WithMemoryOptions(options ...core.Option) MemoryOption {
+	return func(b *Memory) {
+		for _, option := range options {
+			option(&b.Memory)
+		}
+	}
+}
+
 func applyZepMemoryOptions(opts ...MemoryOption) *Memory {
 	m := &Memory{
-		ReturnMessages: true,
-		InputKey:       "",
-		OutputKey:      "",
-		HumanPrefix:    "Human",
-		AIPrefix:       "AI",
-		MemoryKey:      "history",
-		MemoryType:     zep.MemoryTypePerpetual,
+		Memory: *core.ApplyOptions(),
+		Config: defaultConfig(),
 	}
 
 	for _, opt := range opts {
@@ -0,0 +1,31 @@
+package graphiti
+
+import (
+	"context"
+	"errors"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	zepcore "github.com/getzep/zep-go/core"
+)
+
+// ClassifyError classifies the errors of the zep-go client and of GraphClient for a
+// core.RetryPolicy, by the status code of the failed response when there is one.
+func ClassifyError(err error) core.Retryability {
+	var apiErr *zepcore.APIError
+	if errors.As(err, &apiErr) {
+		return core.ClassifyStatus(apiErr.StatusCode)
+	}
+	return core.ClassifyNetworkError(err)
+}
+
+// translateError translates the errors of the zep-go client and of GraphClient into the
+// errors of core, by the status code of the failed response when there is one. See
+// core.NewBackendError.
+func translateError(ctx context.Context, err error) error {
+	statusCode := 0
+	var apiErr *zepcore.APIError
+	if errors.As(err, &apiErr) {
+		statusCode = apiErr.StatusCode
+	}
+	return core.NewBackendError(ctx, telemetryBackend, statusCode, err)
+}
@@ -0,0 +1,162 @@
+package graphiti
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/0xDezzy/langchaingo-memory/memory/graphiti/graphitest"
+	"github.com/0xDezzy/langchaingo-memory/memory/memorytest"
+	zepClient "github.com/getzep/zep-go/client"
+	zepcore "github.com/getzep/zep-go/core"
+	"github.com/getzep/zep-go/option"
+)
+
+const memoryPath = "/sessions/test-session/memory"
+
+func testRetryPolicy() core.RetryPolicy {
+	policy := core.DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	return policy
+}
+
+func TestRetryPolicy(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("RetriesReads", func(t *testing.T) {
+		t.Parallel()
+		server := graphitest.NewServer(graphitest.WithFault(memorytest.Fault{
+			Method: http.MethodGet, Path: memoryPath, StatusCode: http.StatusServiceUnavailable, Times: 2,
+		}))
+		t.Cleanup(server.Close)
+		h := NewZepChatMessageHistory(server.NewClient(), "test-session", WithChatHistoryRetryPolicy(testRetryPolicy()))
+
+		if _, err := h.Messages(ctx); err != nil {
+			t.Fatalf("Messages: %v", err)
+		}
+		if n := server.RequestCount(http.MethodGet, memoryPath); n != 3 {
+			t.Errorf("Expected 2 retries, got %d requests", n)
+		}
+	})
+
+	t.Run("DoesNotRetryWritesOn503", func(t *testing.T) {
+		t.Parallel()
+		server := graphitest.NewServer(graphitest.WithFault(memorytest.Fault{
+			Method: http.MethodPost, Path: memoryPath, StatusCode: http.StatusServiceUnavailable,
+		}))
+		t.Cleanup(server.Close)
+		h := NewZepChatMessageHistory(server.NewClient(), "test-session", WithChatHistoryRetryPolicy(testRetryPolicy()))
+
+		if err := h.AddUserMessage(ctx, "hello"); err == nil {
+			t.Fatalf("Expected AddUserMessage to fail")
+		}
+		if n := server.RequestCount(http.MethodPost, memoryPath); n != 1 {
+			t.Errorf("Expected a single request, without the retries of zep-go, got %d", n)
+		}
+	})
+
+	t.Run("RetriesWritesOn429", func(t *testing.T) {
+		t.Parallel()
+		server := graphitest.NewServer(graphitest.WithFault(memorytest.Fault{
+			Method: http.MethodPost, Path: memoryPath, StatusCode: http.StatusTooManyRequests, Times: 1,
+		}))
+		t.Cleanup(server.Close)
+		policy := testRetryPolicy()
+		policy.MaxRetryAfter = 0
+		var waits []time.Duration
+		policy.OnRetry = func(_ context.Context, _ int, _ error, wait time.Duration) {
+			waits = append(waits, wait)
+		}
+		h := NewZepChatMessageHistory(server.NewClient(), "test-session", WithChatHistoryRetryPolicy(policy))
+
+		if err := h.AddUserMessage(ctx, "hello"); err != nil {
+			t.Fatalf("AddUserMessage: %v", err)
+		}
+		if n := server.RequestCount(http.MethodPost, memoryPath); n != 2 {
+			t.Errorf("Expected 1 retry, got %d requests", n)
+		}
+		if len(waits) != 1 || waits[0] >= time.Second {
+			t.Errorf("Expected the backoff without a RetryAfterTransport, got %v", waits)
+		}
+	})
+
+	t.Run("HonoursRetryAfter", func(t *testing.T) {
+		t.Parallel()
+		server := graphitest.NewServer(graphitest.WithFault(memorytest.Fault{
+			Method: http.MethodPost, Path: memoryPath, StatusCode: http.StatusTooManyRequests,
+		}))
+		t.Cleanup(server.Close)
+		client := zepClient.NewClient(append(server.ClientOptions(),
+			option.WithHTTPClient(&http.Client{Transport: core.RetryAfterTransport(nil)}))...)
+		policy := testRetryPolicy()
+		policy.MaxRetryAfter = 100 * time.Millisecond
+		h := NewZepChatMessageHistory(client, "test-session", WithChatHistoryRetryPolicy(policy))
+
+		if err := h.AddUserMessage(ctx, "hello"); err == nil {
+			t.Fatalf("Expected AddUserMessage to fail")
+		}
+		if n := server.RequestCount(http.MethodPost, memoryPath); n != 1 {
+			t.Errorf("Expected a Retry-After over MaxRetryAfter not to be retried, got %d requests", n)
+		}
+	})
+
+	t.Run("MemoryOption", func(t *testing.T) {
+		t.Parallel()
+		m := NewMemory(createMockZepClient(t), "test-session", WithRetryPolicy(testRetryPolicy()))
+		h, ok := m.ChatHistory.(*ChatMessageHistory)
+		if !ok || h.RetryPolicy == nil || h.RetryPolicy.Classify == nil {
+			t.Errorf("Expected the retry policy to reach the chat history")
+		}
+	})
+}
+
+func TestTranslateError(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		t.Parallel()
+		server := graphitest.NewServer(graphitest.WithFault(memorytest.Fault{StatusCode: http.StatusUnauthorized}))
+		t.Cleanup(server.Close)
+		h := NewZepChatMessageHistory(server.NewClient(), "test-session")
+
+		_, err := h.Messages(ctx)
+		var apiErr *zepcore.APIError
+		if !errors.Is(err, core.ErrUnauthorized) || !errors.As(err, &apiErr) {
+			t.Errorf("Expected ErrUnauthorized wrapping the zep-go error, got %v", err)
+		}
+	})
+
+	t.Run("RateLimited", func(t *testing.T) {
+		t.Parallel()
+		server := graphitest.NewServer(graphitest.WithFault(memorytest.Fault{
+			Method: http.MethodPost, Path: memoryPath, StatusCode: http.StatusTooManyRequests,
+		}))
+		t.Cleanup(server.Close)
+		client := zepClient.NewClient(append(server.ClientOptions(),
+			option.WithMaxAttempts(1),
+			option.WithHTTPClient(&http.Client{Transport: core.RetryAfterTransport(nil)}))...)
+		h := NewZepChatMessageHistory(client, "test-session")
+
+		err := h.AddUserMessage(ctx, "hello")
+		var backendErr *core.BackendError
+		if !errors.Is(err, core.ErrRateLimited) || !errors.As(err, &backendErr) || backendErr.Wait != time.Second {
+			t.Errorf("Expected ErrRateLimited with the Retry-After, got %v", err)
+		}
+	})
+
+	t.Run("GraphClient", func(t *testing.T) {
+		t.Parallel()
+		server := graphitest.NewServer(graphitest.WithFault(memorytest.Fault{StatusCode: http.StatusBadRequest}))
+		t.Cleanup(server.Close)
+		graph := NewGraphClient(server.ClientOptions()...)
+
+		if _, err := graph.Node(ctx, "node"); !errors.Is(err, core.ErrInvalidInput) {
+			t.Errorf("Expected ErrInvalidInput, got %v", err)
+		}
+	})
+}
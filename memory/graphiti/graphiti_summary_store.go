@@ -0,0 +1,80 @@
+package graphiti
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/0xDezzy/langchaingo-memory/memory/summary"
+	"github.com/getzep/zep-go"
+	zepClient "github.com/getzep/zep-go/client"
+)
+
+// DefaultSummaryMetadataKey is the session metadata key SummaryStore keeps summaries under.
+const DefaultSummaryMetadataKey = "langchaingo_summary"
+
+// SummaryStore keeps running summaries in the metadata of Zep sessions, next to the messages
+// they condense. Use it with the summary package for memory types that Zep does not summarize
+// server-side.
+type SummaryStore struct {
+	ZepClient   *zepClient.Client
+	MetadataKey string
+}
+
+// Statically assert that SummaryStore implement the summary store interface.
+var _ summary.Store = &SummaryStore{}
+
+// NewSummaryStore returns a store that keeps summaries in the metadata of Zep sessions.
+func NewSummaryStore(client *zepClient.Client) *SummaryStore {
+	return &SummaryStore{ZepClient: client, MetadataKey: DefaultSummaryMetadataKey}
+}
+
+// Load reads the summary from the session metadata. Missing sessions have no summary.
+func (s *SummaryStore) Load(ctx context.Context, sessionID string) (summary.State, error) {
+	var state summary.State
+	session, err := s.ZepClient.Memory.GetSession(ctx, sessionID)
+	err = translateError(ctx, err)
+	if errors.Is(err, core.ErrNotFound) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	value, ok := session.Metadata[s.MetadataKey]
+	if !ok || value == nil {
+		return state, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return summary.State{}, err
+	}
+	return state, nil
+}
+
+// Save writes the summary to the session metadata, creating the session if needed.
+func (s *SummaryStore) Save(ctx context.Context, sessionID string, state summary.State) error {
+	metadata := map[string]any{s.MetadataKey: state}
+	_, err := s.ZepClient.Memory.UpdateSession(ctx, sessionID, &zep.UpdateSessionRequest{Metadata: metadata})
+	if err = translateError(ctx, err); errors.Is(err, core.ErrNotFound) {
+		_, err = s.ZepClient.Memory.AddSession(ctx, &zep.CreateSessionRequest{SessionID: sessionID, Metadata: metadata})
+		err = translateError(ctx, err)
+	}
+	return err
+}
+
+// Delete empties the summary in the session metadata. Zep merges metadata updates, so the key
+// is kept with an empty state.
+func (s *SummaryStore) Delete(ctx context.Context, sessionID string) error {
+	_, err := s.ZepClient.Memory.UpdateSession(ctx, sessionID, &zep.UpdateSessionRequest{
+		Metadata: map[string]any{s.MetadataKey: summary.State{}},
+	})
+	err = translateError(ctx, err)
+	if errors.Is(err, core.ErrNotFound) {
+		return nil
+	}
+	return err
+}
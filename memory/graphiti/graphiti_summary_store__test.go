@@ -0,0 +1,47 @@
+package graphiti
+
+import (
+	"context"
+	"testing"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/summary"
+)
+
+func TestSummaryStore(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	client := createMockZepClient(t)
+	store := NewSummaryStore(client)
+	if state, err := store.Load(ctx, "missing"); err != nil || state != (summary.State{}) {
+		t.Errorf("Expected no summary for a missing session, got %+v, %v", state, err)
+	}
+
+	m := NewMemory(client, "test-session")
+	if err := m.ChatHistory.AddUserMessage(ctx, "I live in Berlin"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	state := summary.State{Summary: "Sarah lives in Berlin", Summarized: 1, Last: "human: I live in Berlin"}
+	if err := store.Save(ctx, "test-session", state); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if got, err := store.Load(ctx, "test-session"); err != nil || got != state {
+		t.Errorf("Expected %+v, got %+v, %v", state, got, err)
+	}
+	if err := store.Delete(ctx, "test-session"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got, err := store.Load(ctx, "test-session"); err != nil || got != (summary.State{}) {
+		t.Errorf("Expected no summary after Delete, got %+v, %v", got, err)
+	}
+
+	if err := store.Save(ctx, "new-session", state); err != nil {
+		t.Fatalf("Expected Save to create a missing session, got %v", err)
+	}
+	if got, err := store.Load(ctx, "new-session"); err != nil || got != state {
+		t.Errorf("Expected %+v, got %+v, %v", state, got, err)
+	}
+	if err := store.Delete(ctx, "never-created"); err != nil {
+		t.Errorf("Expected Delete to ignore a missing session, got %v", err)
+	}
+}
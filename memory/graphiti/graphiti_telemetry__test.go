@@ -0,0 +1,55 @@
+package graphiti
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/0xDezzy/langchaingo-memory/memory/graphiti/graphitest"
+	"github.com/0xDezzy/langchaingo-memory/memory/memorytest"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTelemetry(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	server := graphitest.NewServer()
+	t.Cleanup(server.Close)
+	server.SetFacts("test-session", "Sarah lives in Berlin")
+	recorder := tracetest.NewSpanRecorder()
+	h := NewZepChatMessageHistory(server.NewClient(), "test-session",
+		WithChatHistoryTelemetry(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)), nil))
+
+	if err := h.AddUserMessage(ctx, "hello"); err != nil {
+		t.Fatalf("AddUserMessage: %v", err)
+	}
+	if _, err := h.Messages(ctx); err != nil {
+		t.Fatalf("Messages: %v", err)
+	}
+	server.InjectFault(memorytest.Fault{StatusCode: http.StatusBadRequest})
+	if err := h.Clear(ctx); err == nil {
+		t.Fatalf("Expected Clear to fail")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 3 || spans[0].Name() != "graphiti.AddMessages" || spans[1].Name() != "graphiti.Messages" {
+		t.Fatalf("Expected the spans of AddMessages and Messages, got %v", spans)
+	}
+	attributes := map[string]int64{}
+	for _, kv := range spans[1].Attributes() {
+		attributes[string(kv.Key)] = kv.Value.AsInt64()
+		if kv.Key == core.AttributeSessionHash && kv.Value.AsString() == "test-session" {
+			t.Errorf("Expected the session to be hashed")
+		}
+	}
+	if attributes[string(core.AttributeFactsCount)] != 1 || attributes[string(core.AttributeMessagesCount)] != 2 {
+		t.Errorf("Expected 1 fact and 2 messages with the system message, got %v", attributes)
+	}
+	if spans[1].Status().Code == codes.Error || spans[2].Status().Code != codes.Error {
+		t.Errorf("Expected only the span of the failed Clear to have the error status")
+	}
+}
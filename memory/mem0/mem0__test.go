@@ -1,9 +1,14 @@
 package mem0
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
 	"testing"
 
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
 	"github.com/bytectlgo/mem0-go/client"
 	"github.com/bytectlgo/mem0-go/types"
 	"github.com/tmc/langchaingo/llms"
@@ -210,7 +215,10 @@ func TestMessagesFromMem0Messages(t *testing.T) {
 		},
 	}
 
-	chatMessages := h.messagesFromMem0Messages(mem0Memories)
+	chatMessages, err := h.messagesFromMem0Messages(context.Background(), "test-user", mem0Memories)
+	if err != nil {
+		t.Fatalf("messagesFromMem0Messages: %v", err)
+	}
 
 	if len(chatMessages) != 3 {
 		t.Errorf("Expected 3 messages, got %d", len(chatMessages))
@@ -243,7 +251,10 @@ func TestMessagesToMem0Messages(t *testing.T) {
 		llms.FunctionChatMessage{Content: "Function result"},
 	}
 
-	mem0Messages := h.messagesToMem0Messages(chatMessages)
+	mem0Messages, err := h.messagesToMem0Messages(context.Background(), "test-user", chatMessages)
+	if err != nil {
+		t.Fatalf("messagesToMem0Messages: %v", err)
+	}
 
 	if len(mem0Messages) != 3 {
 		t.Errorf("Expected 3 messages, got %d", len(mem0Messages))
@@ -303,3 +314,31 @@ func TestChatMessageHistoryMethods(t *testing.T) {
 		}
 	})
 }
+
+func TestUnknownRoles(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	memories := []types.Memory{{Messages: []types.Message{{Role: "user", Content: "Hello"}, {Role: "narrator", Content: "Meanwhile"}}}}
+
+	var buf bytes.Buffer
+	h := NewMem0ChatMessageHistory(nil, "test-user", WithChatHistoryLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+	chatMessages, err := h.messagesFromMem0Messages(ctx, "test-user", memories)
+	if err != nil || len(chatMessages) != 1 {
+		t.Fatalf("Expected the unknown message to be dropped, got %d messages, %v", len(chatMessages), err)
+	}
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a JSON log record, got %q", buf.String())
+	}
+	if record["level"] != "WARN" || record["role"] != "narrator" || record["user_id"] != "test-user" || record["operation"] != "Messages" {
+		t.Errorf("Expected a warning with the role, user and operation, got %v", record)
+	}
+
+	h = NewMem0ChatMessageHistory(nil, "test-user", WithChatHistoryRejectUnknownRoles())
+	if _, err := h.messagesFromMem0Messages(ctx, "test-user", memories); !errors.Is(err, core.ErrUnknownRole) {
+		t.Errorf("Expected ErrUnknownRole, got %v", err)
+	}
+	if _, err := h.messagesToMem0Messages(ctx, "test-user", []llms.ChatMessage{llms.SystemChatMessage{Content: "Be brief"}}); !errors.Is(err, core.ErrUnknownRole) {
+		t.Errorf("Expected ErrUnknownRole, got %v", err)
+	}
+}
@@ -2,55 +2,192 @@ package mem0
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"slices"
+	"time"
 
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
 	"github.com/bytectlgo/mem0-go/client"
 	"github.com/bytectlgo/mem0-go/types"
 	"github.com/tmc/langchaingo/llms"
 	"github.com/tmc/langchaingo/schema"
 )
 
-// ChatMessageHistory is a struct that stores chat messages using Mem0.
+// ErrEncryptionNeedsClient is returned for writes with encryption that do not go through a
+// Client: the mem0 client cannot tell mem0 not to extract facts from the messages.
+var ErrEncryptionNeedsClient = errors.New("mem0: encryption needs a Client")
+
+// telemetryBackend names mem0 in the spans and metrics of the memories.
+const telemetryBackend = "mem0"
+
+// Timeouts are the default timeouts of the operations of a chat message history. They only
+// shorten the deadline of the context of a call, and include the retries of the operation.
+// Zero does not limit an operation.
+type Timeouts struct {
+	// Messages limits Messages and Facts.
+	Messages    time.Duration
+	AddMessages time.Duration
+	Clear       time.Duration
+}
+
+// DefaultTimeouts returns the default timeouts. Adding messages takes longer, as mem0 extracts
+// facts from them before responding.
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		Messages:    10 * time.Second,
+		AddMessages: 30 * time.Second,
+		Clear:       10 * time.Second,
+	}
+}
+
+// withTimeout returns ctx limited by timeout, if any.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Config is the configuration of a chat message history that a Memory passes on to its
+// history.
+type Config struct {
+	Client *Client
+	// Resolver, if set, resolves the user of every call from its context, so that one history
+	// serves every user. UserID is used when it resolves none.
+	Resolver    core.Resolver
+	RetryPolicy *core.RetryPolicy
+	Timeouts    Timeouts
+	// Encryptor encrypts the content of messages before they are sent to mem0, for transcript
+	// only storage: mem0 is told not to extract facts from them, so the history has none. It
+	// needs a Client; writes through the mem0 client fail with ErrEncryptionNeedsClient.
+	Encryptor *core.Encryptor
+	// Logger receives the events of the history, such as dropped messages. Nil logs to
+	// slog.Default().
+	Logger *slog.Logger
+	// RejectUnknownRoles fails reads and writes with messages of unknown roles, with an error
+	// wrapping core.ErrUnknownRole, instead of dropping and logging those messages.
+	RejectUnknownRoles bool
+}
+
+// ChatMessageHistory is a struct that stores chat messages using Mem0. Requests go through
+// Client when it is set, and are cancelled with the context of the call. Otherwise they go
+// through Mem0Client, whose requests cannot be cancelled and are abandoned when the context
+// is done.
 type ChatMessageHistory struct {
+	Config
+	// Mem0Client sends the requests of the history when it has no Client.
+	//
+	// Deprecated: the requests of the mem0-go client outlive their context for up to the 60s
+	// timeout of its HTTP client, and it lists the memories of every user of the project to
+	// read those of one. Use NewClientChatMessageHistory instead.
 	Mem0Client  *client.MemoryClient
 	UserID      string
 	HumanPrefix string
 	AIPrefix    string
+	Telemetry   *core.Telemetry
 }
 
 // Statically assert that Mem0ChatMessageHistory implement the chat message history interface.
 var _ schema.ChatMessageHistory = &ChatMessageHistory{}
 
+// Statically assert that ChatMessageHistory can be used as a core memory backend.
+var _ core.Backend = &ChatMessageHistory{}
+
+// Statically assert that ChatMessageHistory can return the facts extracted from added messages.
+var _ core.FactAdder = &ChatMessageHistory{}
+
+// Statically assert that ChatMessageHistory can return facts on their own.
+var _ core.FactSource = &ChatMessageHistory{}
+
+// Statically assert that ChatMessageHistory can tell wrappers whether it resolves identities.
+var _ core.IdentityResolver = &ChatMessageHistory{}
+
+// NewClientChatMessageHistory creates a new ChatMessageHistory of the user that sends its
+// requests through a Client of the mem0 API described by options.
+func NewClientChatMessageHistory(
+	options client.ClientOptions, userID string, opts ...ChatMessageHistoryOption,
+) *ChatMessageHistory {
+	opts = append([]ChatMessageHistoryOption{WithChatHistoryClient(NewClient(options))}, opts...)
+	return NewMem0ChatMessageHistory(nil, userID, opts...)
+}
+
 // NewMem0ChatMessageHistory creates a new Mem0ChatMessageHistory using chat message options.
+// The mem0 client may be nil when the history has a Client. Prefer
+// NewClientChatMessageHistory, whose requests end with the context of the call.
 func NewMem0ChatMessageHistory(mem0Client *client.MemoryClient, userID string, options ...ChatMessageHistoryOption) *ChatMessageHistory {
 	messageHistory := applyMem0ChatHistoryOptions(options...)
 	messageHistory.Mem0Client = mem0Client
 	messageHistory.UserID = userID
+	messageHistory.Telemetry = messageHistory.Telemetry.Identify(userID, "")
 	return messageHistory
 }
 
-func (h *ChatMessageHistory) messagesFromMem0Messages(mem0Messages []types.Memory) []llms.ChatMessage {
+// ResolvesIdentity reports whether the history has a Resolver.
+func (h *ChatMessageHistory) ResolvesIdentity() bool {
+	return h.Resolver != nil
+}
+
+// userID returns the user of a call, resolved from ctx if the history has a Resolver.
+func (h *ChatMessageHistory) userID(ctx context.Context, op *core.Operation) (string, error) {
+	if h.Resolver == nil {
+		return h.UserID, nil
+	}
+	userID, _, err := core.Resolve(ctx, h.Resolver, h.UserID, "")
+	if err != nil {
+		return "", fmt.Errorf("mem0: resolving user: %w", err)
+	}
+	if userID == "" {
+		return "", fmt.Errorf("mem0: %w", core.ErrNoIdentity)
+	}
+	op.Identify(userID, "")
+	return userID, nil
+}
+
+// unknownRole drops a message of an unknown role of the user, or rejects it if
+// RejectUnknownRoles is set.
+func (h *ChatMessageHistory) unknownRole(ctx context.Context, userID, operation, role string) error {
+	if h.RejectUnknownRoles {
+		return fmt.Errorf("mem0: %s: %w: %q", operation, core.ErrUnknownRole, role)
+	}
+	logger := h.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.LogAttrs(ctx, slog.LevelWarn, "mem0: dropped message with unknown role",
+		slog.String("operation", operation),
+		slog.String("user_id", userID),
+		slog.String("role", role))
+	return nil
+}
+
+func (h *ChatMessageHistory) messagesFromMem0Messages(ctx context.Context, userID string, mem0Messages []types.Memory) ([]llms.ChatMessage, error) {
 	var chatMessages []llms.ChatMessage
 	for _, mem0Memory := range mem0Messages {
 		for _, message := range mem0Memory.Messages {
+			content, err := h.Encryptor.Decrypt(ctx, message.Content, core.AssociatedData(userID, message.Role))
+			if err != nil {
+				return nil, fmt.Errorf("mem0: %w", err)
+			}
 			switch message.Role {
 			case "user":
-				chatMessages = append(chatMessages, llms.HumanChatMessage{Content: message.Content})
+				chatMessages = append(chatMessages, llms.HumanChatMessage{Content: content})
 			case "assistant":
-				chatMessages = append(chatMessages, llms.AIChatMessage{Content: message.Content})
+				chatMessages = append(chatMessages, llms.AIChatMessage{Content: content})
 			case "tool", "function":
-				chatMessages = append(chatMessages, llms.ToolChatMessage{Content: message.Content})
+				chatMessages = append(chatMessages, llms.ToolChatMessage{Content: content})
 			default:
-				log.Print(fmt.Errorf("unknown role: %s", message.Role))
-				continue
+				if err := h.unknownRole(ctx, userID, "Messages", message.Role); err != nil {
+					return nil, err
+				}
 			}
 		}
 	}
-	return chatMessages
+	return chatMessages, nil
 }
 
-func (h *ChatMessageHistory) messagesToMem0Messages(messages []llms.ChatMessage) []types.Message {
+func (h *ChatMessageHistory) messagesToMem0Messages(ctx context.Context, userID string, messages []llms.ChatMessage) ([]types.Message, error) {
 	var mem0Messages []types.Message
 	for _, m := range messages {
 		mem0Message := types.Message{
@@ -66,35 +203,41 @@ func (h *ChatMessageHistory) messagesToMem0Messages(messages []llms.ChatMessage)
 		case llms.ChatMessageTypeTool:
 			mem0Message.Role = "tool"
 		default:
-			log.Print(fmt.Errorf("unknown message type: %s", m.GetType()))
+			if err := h.unknownRole(ctx, userID, "AddMessages", string(m.GetType())); err != nil {
+				return nil, err
+			}
 			continue
 		}
 		mem0Messages = append(mem0Messages, mem0Message)
 	}
-	return mem0Messages
+	return mem0Messages, nil
 }
 
 // Messages returns all messages stored.
-func (h *ChatMessageHistory) Messages(ctx context.Context) ([]llms.ChatMessage, error) {
-	searchOptions := &types.SearchOptions{
-		MemoryOptions: types.MemoryOptions{
-			UserID: h.UserID,
-		},
-	}
-	mem0Memories, err := h.Mem0Client.GetAll(searchOptions)
+func (h *ChatMessageHistory) Messages(ctx context.Context) (_ []llms.ChatMessage, err error) {
+	ctx, op := h.Telemetry.Start(ctx, "Messages")
+	defer func() { op.End(ctx, err) }()
+
+	userID, mem0Memories, err := h.getAll(ctx, op)
 	if err != nil {
 		return nil, err
 	}
 
-	messages := h.messagesFromMem0Messages(mem0Memories)
+	messages, err := h.messagesFromMem0Messages(ctx, userID, mem0Memories)
+	if err != nil {
+		return nil, err
+	}
 
 	// Add system context if available
 	var systemPromptContent string
+	facts := 0
 	for _, memory := range mem0Memories {
-		if memory.Memory != "" {
+		if memory.Memory != "" && h.Encryptor == nil {
 			systemPromptContent += fmt.Sprintf("%s\n", memory.Memory)
+			facts++
 		}
 	}
+	op.RecordFacts(ctx, facts)
 
 	if systemPromptContent != "" {
 		// Add system prompt to the beginning of the messages.
@@ -108,71 +251,193 @@ func (h *ChatMessageHistory) Messages(ctx context.Context) ([]llms.ChatMessage,
 		)
 	}
 
+	op.RecordMessages(messages)
 	return messages, nil
 }
 
-// AddAIMessage adds an AIMessage to the chat message history.
-func (h *ChatMessageHistory) AddAIMessage(ctx context.Context, text string) error {
-	mem0Messages := h.messagesToMem0Messages(
-		[]llms.ChatMessage{
-			llms.AIChatMessage{Content: text},
-		},
-	)
+// Facts returns the memories mem0 extracted for the user. The mem0 API cannot list memories
+// without a search query other than in full, so Facts fetches every memory of the user, like
+// Messages does, and a tiered history on top of mem0 saves no remote reads.
+func (h *ChatMessageHistory) Facts(ctx context.Context) (_ []string, err error) {
+	ctx, op := h.Telemetry.Start(ctx, "Facts")
+	defer func() { op.End(ctx, err) }()
 
-	memoryOptions := types.MemoryOptions{
-		UserID: h.UserID,
+	_, mem0Memories, err := h.getAll(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+	var facts []string
+	for _, memory := range mem0Memories {
+		if memory.Memory != "" && h.Encryptor == nil {
+			facts = append(facts, memory.Memory)
+		}
 	}
+	op.RecordFacts(ctx, len(facts))
+	return facts, nil
+}
 
-	_, err := h.Mem0Client.Add(mem0Messages, memoryOptions)
+// getAll returns the user and their memories.
+func (h *ChatMessageHistory) getAll(ctx context.Context, op *core.Operation) (string, []types.Memory, error) {
+	userID, err := h.userID(ctx, op)
 	if err != nil {
-		return err
+		return "", nil, err
 	}
-	return nil
+	ctx, cancel := withTimeout(ctx, h.Timeouts.Messages)
+	defer cancel()
+
+	memoryOptions := types.MemoryOptions{
+		UserID: userID,
+	}
+	var mem0Memories []types.Memory
+	err = h.RetryPolicy.Do(ctx, true, func(ctx context.Context) error {
+		var err error
+		if h.Client != nil {
+			mem0Memories, err = h.Client.GetAll(ctx, memoryOptions)
+		} else {
+			mem0Memories, err = await(ctx, func() ([]types.Memory, error) {
+				return h.Mem0Client.GetAll(&types.SearchOptions{MemoryOptions: memoryOptions})
+			})
+			// The mem0 client drops the embedded memory options from the query, so it lists
+			// the memories of every user and those of other users are filtered out here.
+			// Client queries by user.
+			mem0Memories = slices.DeleteFunc(mem0Memories, func(m types.Memory) bool {
+				return m.UserID != userID
+			})
+		}
+		return translateError(ctx, err)
+	})
+	if errors.Is(err, core.ErrNotFound) {
+		// A user without memories is an empty history.
+		return userID, nil, nil
+	}
+	return userID, mem0Memories, err
+}
+
+// AddAIMessage adds an AIMessage to the chat message history.
+func (h *ChatMessageHistory) AddAIMessage(ctx context.Context, text string) error {
+	return h.AddMessages(ctx, []llms.ChatMessage{llms.AIChatMessage{Content: text}})
 }
 
 // AddUserMessage adds a user message to the chat message history.
 func (h *ChatMessageHistory) AddUserMessage(ctx context.Context, text string) error {
-	mem0Messages := h.messagesToMem0Messages(
-		[]llms.ChatMessage{
-			llms.HumanChatMessage{Content: text},
-		},
-	)
-
-	memoryOptions := types.MemoryOptions{
-		UserID: h.UserID,
-	}
+	return h.AddMessages(ctx, []llms.ChatMessage{llms.HumanChatMessage{Content: text}})
+}
 
-	_, err := h.Mem0Client.Add(mem0Messages, memoryOptions)
+func (h *ChatMessageHistory) Clear(ctx context.Context) (err error) {
+	ctx, op := h.Telemetry.Start(ctx, "Clear")
+	defer func() { op.End(ctx, err) }()
+	userID, err := h.userID(ctx, op)
 	if err != nil {
 		return err
 	}
-	return nil
-}
+	ctx, cancel := withTimeout(ctx, h.Timeouts.Clear)
+	defer cancel()
 
-func (h *ChatMessageHistory) Clear(ctx context.Context) error {
 	memoryOptions := types.MemoryOptions{
-		UserID: h.UserID,
+		UserID: userID,
 	}
 
-	err := h.Mem0Client.DeleteAll(memoryOptions)
-	if err != nil {
-		return err
+	err = h.RetryPolicy.Do(ctx, true, func(ctx context.Context) error {
+		if h.Client != nil {
+			return translateError(ctx, h.Client.DeleteAll(ctx, memoryOptions))
+		}
+		_, err := await(ctx, func() (struct{}, error) {
+			return struct{}{}, h.Mem0Client.DeleteAll(memoryOptions)
+		})
+		return translateError(ctx, err)
+	})
+	if errors.Is(err, core.ErrNotFound) {
+		return nil
 	}
-	return nil
+	return err
 }
 
 func (h *ChatMessageHistory) AddMessage(ctx context.Context, message llms.ChatMessage) error {
-	mem0Messages := h.messagesToMem0Messages([]llms.ChatMessage{message})
+	return h.AddMessages(ctx, []llms.ChatMessage{message})
+}
 
-	memoryOptions := types.MemoryOptions{
-		UserID: h.UserID,
+// AddMessages adds several messages to the chat message history in a single request, so that
+// mem0 extracts facts from the exchange as a whole.
+func (h *ChatMessageHistory) AddMessages(ctx context.Context, messages []llms.ChatMessage) (err error) {
+	ctx, op := h.Telemetry.Start(ctx, "AddMessages")
+	defer func() { op.End(ctx, err) }()
+	_, err = h.add(ctx, op, messages)
+	return err
+}
+
+// AddMessagesWithFacts is like AddMessages, and returns the memories that mem0 added or
+// updated from the messages. It returns none with encryption, as mem0 extracts nothing then.
+func (h *ChatMessageHistory) AddMessagesWithFacts(ctx context.Context, messages []llms.ChatMessage) (_ []string, err error) {
+	ctx, op := h.Telemetry.Start(ctx, "AddMessages")
+	defer func() { op.End(ctx, err) }()
+	added, err := h.add(ctx, op, messages)
+	if err != nil || h.Encryptor != nil {
+		return nil, err
+	}
+	var facts []string
+	for _, memory := range added {
+		if memory.Event == "DELETE" {
+			continue
+		}
+		fact := memory.Memory
+		if fact == "" && memory.Data != nil {
+			fact = memory.Data.Memory
+		}
+		if fact != "" {
+			facts = append(facts, fact)
+		}
 	}
+	op.RecordFacts(ctx, len(facts))
+	return facts, nil
+}
 
-	_, err := h.Mem0Client.Add(mem0Messages, memoryOptions)
+// add adds messages in a single request and returns the memories of the add response.
+func (h *ChatMessageHistory) add(ctx context.Context, op *core.Operation, messages []llms.ChatMessage) ([]types.Memory, error) {
+	op.RecordMessages(messages)
+	userID, err := h.userID(ctx, op)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	ctx, cancel := withTimeout(ctx, h.Timeouts.AddMessages)
+	defer cancel()
+
+	mem0Messages, err := h.messagesToMem0Messages(ctx, userID, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	memoryOptions := types.MemoryOptions{
+		UserID: userID,
+	}
+	if h.Encryptor != nil {
+		if h.Client == nil {
+			return nil, ErrEncryptionNeedsClient
+		}
+		var keyID string
+		for i, message := range mem0Messages {
+			associatedData := core.AssociatedData(userID, message.Role)
+			if mem0Messages[i].Content, keyID, err = h.Encryptor.Encrypt(ctx, message.Content, associatedData); err != nil {
+				return nil, fmt.Errorf("mem0: %w", err)
+			}
+		}
+		memoryOptions.Metadata = map[string]any{core.EncryptionKeyIDMetadataKey: keyID}
+	}
+
+	// Adding memories is not idempotent, so it is only retried when mem0 did not process it.
+	var added []types.Memory
+	err = h.RetryPolicy.Do(ctx, false, func(ctx context.Context) error {
+		var err error
+		if h.Client != nil {
+			// There is nothing to extract from encrypted messages, and mem0 must not try.
+			added, err = h.Client.add(ctx, mem0Messages, memoryOptions, h.Encryptor == nil)
+		} else {
+			added, err = await(ctx, func() ([]types.Memory, error) {
+				return h.Mem0Client.Add(mem0Messages, memoryOptions)
+			})
+		}
+		return translateError(ctx, err)
+	})
+	return added, err
 }
 
 func (*ChatMessageHistory) SetMessages(_ context.Context, _ []llms.ChatMessage) error {
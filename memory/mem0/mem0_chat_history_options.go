@@ -1,5 +1,13 @@
 package mem0
 
+import (
+	"log/slog"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
 // ChatMessageHistoryOption is a function for creating new chat message history
 // with other than the default values.
 type ChatMessageHistoryOption func(m *ChatMessageHistory)
@@ -18,8 +26,81 @@ func WithChatHistoryAIPrefix(aiPrefix string) ChatMessageHistoryOption {
 	}
 }
 
+// WithChatHistoryRetryPolicy is an option for retrying failed requests to mem0 with the given
+// policy. Messages are only re-sent when mem0 did not process them. Errors are classified with
+// ClassifyError unless the policy has its own classifier.
+func WithChatHistoryRetryPolicy(policy core.RetryPolicy) ChatMessageHistoryOption {
+	return func(b *ChatMessageHistory) {
+		if policy.Classify == nil {
+			policy.Classify = ClassifyError
+		}
+		b.RetryPolicy = &policy
+	}
+}
+
+// WithChatHistoryTelemetry is an option for tracing and measuring the requests to mem0 with
+// OpenTelemetry. Nil providers record nothing.
+func WithChatHistoryTelemetry(tracerProvider trace.TracerProvider, meterProvider metric.MeterProvider) ChatMessageHistoryOption {
+	return func(b *ChatMessageHistory) {
+		b.Telemetry = core.NewTelemetry(telemetryBackend, tracerProvider, meterProvider)
+	}
+}
+
+// WithChatHistoryLogger is an option for specifying the logger of the history's events, such
+// as messages dropped because of their role. Defaults to slog.Default().
+func WithChatHistoryLogger(logger *slog.Logger) ChatMessageHistoryOption {
+	return func(b *ChatMessageHistory) {
+		b.Logger = logger
+	}
+}
+
+// WithChatHistoryRejectUnknownRoles is an option for failing reads and writes with messages of
+// roles mem0 does not store, instead of dropping those messages.
+func WithChatHistoryRejectUnknownRoles() ChatMessageHistoryOption {
+	return func(b *ChatMessageHistory) {
+		b.RejectUnknownRoles = true
+	}
+}
+
+// WithChatHistoryClient is an option for sending the requests of the history through c, so
+// that they are cancelled with the context of the call instead of being abandoned.
+func WithChatHistoryClient(c *Client) ChatMessageHistoryOption {
+	return func(b *ChatMessageHistory) {
+		b.Client = c
+	}
+}
+
+// WithChatHistoryTimeouts is an option for specifying the default timeouts of the operations
+// of the history. Defaults to DefaultTimeouts().
+func WithChatHistoryTimeouts(timeouts Timeouts) ChatMessageHistoryOption {
+	return func(b *ChatMessageHistory) {
+		b.Timeouts = timeouts
+	}
+}
+
+// WithChatHistoryResolver is an option for resolving the user of every call from its
+// context, such as core.ResolveFromContext, so that one history serves every user of a
+// server. The user ID of the history is used when the resolver returns none.
+func WithChatHistoryResolver(resolver core.Resolver) ChatMessageHistoryOption {
+	return func(b *ChatMessageHistory) {
+		b.Resolver = resolver
+	}
+}
+
+// WithChatHistoryEncryption is an option for encrypting the content of messages with keys
+// from provider before they leave the process, for transcripts that mem0 must store but not
+// read. mem0 is told not to extract facts from them, which needs a Client, so the history has
+// none. Messages that are not encrypted are rejected on read unless core.WithAllowPlaintext is
+// given.
+func WithChatHistoryEncryption(provider core.KeyProvider, options ...core.EncryptorOption) ChatMessageHistoryOption {
+	return func(b *ChatMessageHistory) {
+		b.Encryptor = core.NewEncryptor(provider, options...)
+	}
+}
+
 func applyMem0ChatHistoryOptions(options ...ChatMessageHistoryOption) *ChatMessageHistory {
 	h := &ChatMessageHistory{
+		Config:      Config{Timeouts: DefaultTimeouts()},
 		HumanPrefix: "Human",
 		AIPrefix:    "AI",
 	}
@@ -0,0 +1,191 @@
+package mem0
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/bytectlgo/mem0-go/client"
+	"github.com/bytectlgo/mem0-go/types"
+)
+
+// defaultHost is the mem0 API used when the client options name no host.
+const defaultHost = "https://api.mem0.ai"
+
+// Client sends the requests of ChatMessageHistory to the mem0 API with the context of the
+// call, so that they end when the context is done. The methods of the mem0-go client take no
+// context, so their requests can only be abandoned.
+type Client struct {
+	host           string
+	apiKey         string
+	organizationID string
+	projectID      string
+	httpClient     *http.Client
+}
+
+// NewClient returns a client for the mem0 API described by options, as given to
+// client.NewMemoryClient. Unlike client.NewMemoryClient, it does not ping the API.
+func NewClient(options client.ClientOptions, opts ...ClientOption) *Client {
+	c := applyClientOptions(opts...)
+	c.host = options.Host
+	if c.host == "" {
+		c.host = defaultHost
+	}
+	c.apiKey = options.APIKey
+	if options.OrganizationID != "" && options.ProjectID != "" {
+		c.organizationID = options.OrganizationID
+		c.projectID = options.ProjectID
+	}
+	return c
+}
+
+// Add adds messages to the memories of the owner named in options.
+func (c *Client) Add(ctx context.Context, messages []types.Message, options types.MemoryOptions) ([]types.Memory, error) {
+	return c.add(ctx, messages, options, true)
+}
+
+// add adds messages, letting mem0 extract memories from them only if infer is set. The infer
+// option of the mem0-go client cannot turn extraction off, as false is omitted.
+func (c *Client) add(
+	ctx context.Context, messages []types.Message, options types.MemoryOptions, infer bool,
+) ([]types.Memory, error) {
+	options.OrgID, options.ProjectID = c.organizationID, c.projectID
+	request := struct {
+		types.MemoryOptions
+		Messages []types.Message `json:"messages"`
+		Infer    *bool           `json:"infer,omitempty"`
+	}{MemoryOptions: options, Messages: messages}
+	if !infer {
+		request.Infer = &infer
+	}
+	var memories []types.Memory
+	if err := c.do(ctx, http.MethodPost, "/v1/memories/", nil, request, &memories); err != nil {
+		return nil, err
+	}
+	return memories, nil
+}
+
+// GetAll returns the memories of the owner named in options.
+func (c *Client) GetAll(ctx context.Context, options types.MemoryOptions) ([]types.Memory, error) {
+	var memories []types.Memory
+	if err := c.do(ctx, http.MethodGet, "/v1/memories/", c.query(options), nil, &memories); err != nil {
+		return nil, err
+	}
+	return memories, nil
+}
+
+// DeleteAll deletes the memories of the owner named in options.
+func (c *Client) DeleteAll(ctx context.Context, options types.MemoryOptions) error {
+	return c.do(ctx, http.MethodDelete, "/v1/memories/", c.query(options), nil, nil)
+}
+
+// query returns the query naming the owner of the memories in options.
+func (c *Client) query(options types.MemoryOptions) url.Values {
+	query := url.Values{}
+	for key, value := range map[string]string{
+		"user_id":    options.UserID,
+		"agent_id":   options.AgentID,
+		"app_id":     options.AppID,
+		"run_id":     options.RunID,
+		"org_id":     c.organizationID,
+		"project_id": c.projectID,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	return query
+}
+
+// StatusError is a failed response of the mem0 API to a Client. It wraps the *client.APIError
+// the mem0-go client returns for the same response, whose status code is only in its message.
+type StatusError struct {
+	StatusCode int
+	Err        *client.APIError
+}
+
+func (e *StatusError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// do sends a request and decodes the response into response, unless it is nil. Failed
+// responses are returned as a *StatusError, and requests ended by ctx return ctx.Err().
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, request, response any) error {
+	var body io.Reader
+	if request != nil {
+		data, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.host+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+c.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return &StatusError{
+			StatusCode: resp.StatusCode,
+			Err:        &client.APIError{Message: fmt.Sprintf("API request failed with status %d: %s", resp.StatusCode, data)},
+		}
+	}
+	if response == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	return nil
+}
+
+// await calls fn, a request of the mem0-go client, and waits for it until ctx is done. The
+// request cannot be cancelled, so it is abandoned then and finishes in the background within
+// the timeout of the mem0-go client; an abandoned write may still be applied.
+func await[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := fn()
+		done <- result{value, err}
+	}()
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
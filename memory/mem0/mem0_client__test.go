@@ -0,0 +1,233 @@
+package mem0
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/0xDezzy/langchaingo-memory/memory/mem0/mem0test"
+	"github.com/0xDezzy/langchaingo-memory/memory/memorytest"
+	"github.com/bytectlgo/mem0-go/types"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// newClientHistory returns a history of the user with a Client of the server.
+func newClientHistory(server *mem0test.Server, userID string, options ...ChatMessageHistoryOption) *ChatMessageHistory {
+	return NewClientChatMessageHistory(server.ClientOptions(), userID, options...)
+}
+
+func TestClientConformance(t *testing.T) {
+	t.Parallel()
+
+	memorytest.RunChatMessageHistorySuite(t, func(t *testing.T) schema.ChatMessageHistory {
+		server := mem0test.NewServer()
+		t.Cleanup(server.Close)
+		return newClientHistory(server, "test-user")
+	}, memorytest.WithoutSetMessages())
+	memorytest.RunMemorySuite(t, func(t *testing.T) schema.Memory {
+		server := mem0test.NewServer()
+		t.Cleanup(server.Close)
+		return NewClientMemory(server.ClientOptions(), "test-user")
+	})
+}
+
+func TestClientQueriesByUser(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	server := mem0test.NewServer()
+	t.Cleanup(server.Close)
+	sarah, john := newClientHistory(server, "sarah"), newClientHistory(server, "john")
+	if err := sarah.AddUserMessage(ctx, "I live in Berlin"); err != nil {
+		t.Fatalf("AddUserMessage: %v", err)
+	}
+	if err := john.AddUserMessage(ctx, "I live in Paris"); err != nil {
+		t.Fatalf("AddUserMessage: %v", err)
+	}
+	// The server lists every memory when the query names no user.
+	messages, err := sarah.Messages(ctx)
+	if err != nil {
+		t.Fatalf("Messages: %v", err)
+	}
+	for _, message := range messages {
+		if strings.Contains(message.GetContent(), "Paris") {
+			t.Errorf("Expected only the messages of sarah, got %v", messages)
+		}
+	}
+}
+
+func TestCancellation(t *testing.T) {
+	t.Parallel()
+
+	// Abandoned requests of the mem0 client keep the server busy until it responds.
+	server := mem0test.NewServer(mem0test.WithLatency(500 * time.Millisecond))
+	t.Cleanup(server.Close)
+	mem0Client, err := server.NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	histories := map[string]*ChatMessageHistory{
+		"Client":       newClientHistory(server, "test-user"),
+		"MemoryClient": NewMem0ChatMessageHistory(mem0Client, "test-user"),
+	}
+	for name, h := range histories {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			if _, err := h.Messages(ctx); err != context.DeadlineExceeded || time.Since(start) > 250*time.Millisecond {
+				t.Errorf("Expected ctx.Err() once the deadline passed, got %v after %v", err, time.Since(start))
+			}
+			cancelled, cancel := context.WithCancel(context.Background())
+			cancel()
+			if err := h.AddUserMessage(cancelled, "hello"); err != context.Canceled {
+				t.Errorf("Expected ctx.Err() for a cancelled context, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	t.Parallel()
+
+	server := mem0test.NewServer(mem0test.WithFault(memorytest.Fault{
+		Method: http.MethodDelete, Path: addPath, Latency: 5 * time.Second,
+	}))
+	t.Cleanup(server.Close)
+	h := newClientHistory(server, "test-user", WithChatHistoryTimeouts(Timeouts{Clear: 20 * time.Millisecond}))
+
+	if err := h.AddUserMessage(context.Background(), "hello"); err != nil {
+		t.Fatalf("Expected writes not to be limited, got %v", err)
+	}
+	if err := h.Clear(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected Clear to time out, got %v", err)
+	}
+}
+
+func TestUserIsolation(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	server := mem0test.NewServer()
+	t.Cleanup(server.Close)
+	mem0Client, err := server.NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if err := newClientHistory(server, "other-user").AddUserMessage(ctx, "I live in Paris"); err != nil {
+		t.Fatalf("AddUserMessage: %v", err)
+	}
+
+	for name, h := range map[string]*ChatMessageHistory{
+		"Client":       newClientHistory(server, "test-user"),
+		"MemoryClient": NewMem0ChatMessageHistory(mem0Client, "test-user"),
+	} {
+		if messages, err := h.Messages(ctx); err != nil || len(messages) != 0 {
+			t.Errorf("%s: Expected no messages of other users, got %v, %v", name, messages, err)
+		}
+	}
+}
+
+func TestEncryption(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	keys := core.NewStaticKeyProvider("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+
+	server := mem0test.NewServer()
+	t.Cleanup(server.Close)
+	h := newClientHistory(server, "test-user", WithChatHistoryEncryption(keys))
+	if err := h.AddUserMessage(ctx, "I live in Berlin"); err != nil {
+		t.Fatalf("AddUserMessage: %v", err)
+	}
+	for _, memory := range server.Memories("test-user") {
+		if memory.Memory != "" {
+			t.Errorf("Expected mem0 not to extract facts, got %q", memory.Memory)
+		}
+		if memory.Metadata[core.EncryptionKeyIDMetadataKey] != "k1" {
+			t.Errorf("Expected the key ID in the metadata, got %v", memory.Metadata)
+		}
+		for _, message := range memory.Messages {
+			if !core.IsEncrypted(message.Content) {
+				t.Errorf("Expected mem0 to store ciphertext, got %q", message.Content)
+			}
+		}
+	}
+
+	messages, err := h.Messages(ctx)
+	if err != nil {
+		t.Fatalf("Messages: %v", err)
+	}
+	if len(messages) != 1 || messages[0].GetContent() != "I live in Berlin" {
+		t.Errorf("Expected the decrypted message, got %v", messages)
+	}
+
+	if err := newClientHistory(server, "test-user").AddUserMessage(ctx, "injected"); err != nil {
+		t.Fatalf("AddUserMessage: %v", err)
+	}
+	if _, err := h.Messages(ctx); !errors.Is(err, core.ErrNotEncrypted) {
+		t.Errorf("Expected a message that is not encrypted to be rejected, got %v", err)
+	}
+	migrating := newClientHistory(server, "test-user", WithChatHistoryEncryption(keys, core.WithAllowPlaintext()))
+	if messages, err := migrating.Messages(ctx); err != nil || len(messages) != 2 {
+		t.Errorf("Expected both messages while migrating, got %v, %v", messages, err)
+	}
+
+	var stored []types.Message
+	for _, memory := range server.Memories("test-user") {
+		stored = append(stored, memory.Messages...)
+	}
+	moved := newClientHistory(server, "other-user", WithChatHistoryEncryption(keys))
+	if _, err := moved.Client.add(ctx, stored[:1], types.MemoryOptions{UserID: "other-user"}, false); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if _, err := moved.Messages(ctx); err == nil {
+		t.Errorf("Expected a message moved to another user to fail")
+	}
+
+	client, err := server.NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	legacy := NewMem0ChatMessageHistory(client, "test-user", WithChatHistoryEncryption(keys))
+	if err := legacy.AddUserMessage(ctx, "I live in Berlin"); !errors.Is(err, ErrEncryptionNeedsClient) {
+		t.Errorf("Expected ErrEncryptionNeedsClient, got %v", err)
+	}
+}
+
+func TestResolver(t *testing.T) {
+	t.Parallel()
+
+	server := mem0test.NewServer()
+	t.Cleanup(server.Close)
+	m := NewMemory(nil, "", WithClient(NewClient(server.ClientOptions())), WithResolver(core.ResolveFromContext))
+
+	if _, err := m.LoadMemoryVariables(context.Background(), nil); !errors.Is(err, core.ErrNoIdentity) {
+		t.Errorf("Expected ErrNoIdentity without a user, got %v", err)
+	}
+	for _, userID := range []string{"sarah", "john"} {
+		ctx := core.ContextWithUserID(context.Background(), userID)
+		err := m.SaveContext(ctx, map[string]any{"input": "I am " + userID}, map[string]any{"output": "Hi " + userID})
+		if err != nil {
+			t.Fatalf("SaveContext: %v", err)
+		}
+	}
+	for _, userID := range []string{"sarah", "john"} {
+		if memories := server.Memories(userID); len(memories) != 1 {
+			t.Errorf("Expected one memory of %s, got %d", userID, len(memories))
+		}
+		ctx := core.ContextWithUserID(context.Background(), userID)
+		messages, err := m.ChatHistory.Messages(ctx)
+		if err != nil {
+			t.Fatalf("Messages: %v", err)
+		}
+		if len(messages) != 3 || messages[1].GetContent() != "I am "+userID {
+			t.Errorf("Expected the facts and turn of %s, got %v", userID, messages)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package mem0
+
+import (
+	"net/http"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+)
+
+// ClientOption is a function for creating a new client
+// with other than the default values.
+type ClientOption func(c *Client)
+
+// WithHTTPClient is an option for specifying the HTTP client of the requests. The default
+// client has no timeout of its own and passes the Retry-After of failed responses to a
+// core.RetryPolicy; wrap the transport of another client in core.RetryAfterTransport to keep
+// that.
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+func applyClientOptions(options ...ClientOption) *Client {
+	c := &Client{
+		httpClient: &http.Client{Transport: core.RetryAfterTransport(nil)},
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	return c
+}
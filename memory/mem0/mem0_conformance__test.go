@@ -0,0 +1,80 @@
+package mem0
+
+import (
+	"context"
+	"testing"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/mem0/mem0test"
+	"github.com/0xDezzy/langchaingo-memory/memory/memorytest"
+	"github.com/bytectlgo/mem0-go/client"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// newConformanceClient returns a mem0 client backed by a fresh fake server.
+func newConformanceClient(t *testing.T) *client.MemoryClient {
+	t.Helper()
+
+	server := mem0test.NewServer()
+	t.Cleanup(server.Close)
+	mem0Client, err := server.NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return mem0Client
+}
+
+func TestChatMessageHistoryConformance(t *testing.T) {
+	t.Parallel()
+
+	memorytest.RunChatMessageHistorySuite(t, func(t *testing.T) schema.ChatMessageHistory {
+		return NewMem0ChatMessageHistory(newConformanceClient(t), "test-user")
+	}, memorytest.WithoutSetMessages())
+}
+
+func TestMemoryConformance(t *testing.T) {
+	t.Parallel()
+
+	memorytest.RunMemorySuite(t, func(t *testing.T) schema.Memory {
+		return NewMemory(newConformanceClient(t), "test-user")
+	})
+}
+
+func TestFacts(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	h := NewMem0ChatMessageHistory(newConformanceClient(t), "test-user")
+	if err := h.AddMessages(ctx, []llms.ChatMessage{
+		llms.HumanChatMessage{Content: "I live in Berlin"},
+		llms.AIChatMessage{Content: "Noted."},
+	}); err != nil {
+		t.Fatalf("AddMessages: %v", err)
+	}
+	facts, err := h.Facts(ctx)
+	if err != nil {
+		t.Fatalf("Facts: %v", err)
+	}
+	if len(facts) != 1 || facts[0] != "I live in Berlin" {
+		t.Errorf("Expected the extracted memory, got %v", facts)
+	}
+}
+
+func TestSaveAndLoadContextFacts(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	server := mem0test.NewServer()
+	t.Cleanup(server.Close)
+	m := NewClientMemory(server.ClientOptions(), "test-user", WithReturnMessages(true))
+	variables, err := m.SaveAndLoadContext(ctx,
+		map[string]any{"input": "I live in Berlin"},
+		map[string]any{"output": "Noted."})
+	if err != nil {
+		t.Fatalf("SaveAndLoadContext: %v", err)
+	}
+	messages := variables["history"].([]llms.ChatMessage)
+	if len(messages) != 3 || messages[0].GetContent() != "I live in Berlin\n" {
+		t.Errorf("Expected the memory of the add response once and the turn, got %v", messages)
+	}
+}
@@ -1,5 +1,13 @@
 package mem0
 
+import (
+	"log/slog"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
 // MemoryOption Mem0MemoryOption is a function for creating new buffer
 // with other than the default values.
 type MemoryOption func(b *Memory)
@@ -7,53 +15,138 @@ type MemoryOption func(b *Memory)
 // WithReturnMessages is an option for specifying should it return messages.
 func WithReturnMessages(returnMessages bool) MemoryOption {
 	return func(b *Memory) {
-		b.ReturnMessages = returnMessages
+		core.WithReturnMessages(returnMessages)(&b.Memory)
 	}
 }
 
 // WithInputKey is an option for specifying the input key.
 func WithInputKey(inputKey string) MemoryOption {
 	return func(b *Memory) {
-		b.InputKey = inputKey
+		core.WithInputKey(inputKey)(&b.Memory)
 	}
 }
 
 // WithOutputKey is an option for specifying the output key.
 func WithOutputKey(outputKey string) MemoryOption {
 	return func(b *Memory) {
-		b.OutputKey = outputKey
+		core.WithOutputKey(outputKey)(&b.Memory)
 	}
 }
 
 // WithHumanPrefix is an option for specifying the human prefix. Will be passed as role for the message to mem0.
 func WithHumanPrefix(humanPrefix string) MemoryOption {
 	return func(b *Memory) {
-		b.HumanPrefix = humanPrefix
+		core.WithHumanPrefix(humanPrefix)(&b.Memory)
 	}
 }
 
 // WithAIPrefix is an option for specifying the AI prefix. Will be passed as role for the message to mem0.
 func WithAIPrefix(aiPrefix string) MemoryOption {
 	return func(b *Memory) {
-		b.AIPrefix = aiPrefix
+		core.WithAIPrefix(aiPrefix)(&b.Memory)
 	}
 }
 
 // WithMemoryKey is an option for specifying the memory key.
 func WithMemoryKey(memoryKey string) MemoryOption {
 	return func(b *Memory) {
-		b.MemoryKey = memoryKey
+		core.WithMemoryKey(memoryKey)(&b.Memory)
+	}
+}
+
+// WithMaxTokens is an option for limiting the loaded history to maxTokens tokens, as counted
+// by tokenizer. The oldest turns are dropped or truncated first; facts and summaries are kept.
+func WithMaxTokens(maxTokens int, tokenizer core.Tokenizer) MemoryOption {
+	return func(b *Memory) {
+		core.WithMaxTokens(maxTokens, tokenizer)(&b.Memory)
+	}
+}
+
+// WithRetryPolicy is an option for retrying failed requests to mem0 with the given policy.
+// Messages are only re-sent when mem0 did not process them. Errors are classified with
+// ClassifyError unless the policy has its own classifier.
+func WithRetryPolicy(policy core.RetryPolicy) MemoryOption {
+	return func(b *Memory) {
+		if policy.Classify == nil {
+			policy.Classify = ClassifyError
+		}
+		b.RetryPolicy = &policy
+	}
+}
+
+// WithTelemetry is an option for tracing and measuring the memory and its requests to mem0
+// with OpenTelemetry. Nil providers record nothing.
+func WithTelemetry(tracerProvider trace.TracerProvider, meterProvider metric.MeterProvider) MemoryOption {
+	return func(b *Memory) {
+		b.Telemetry = core.NewTelemetry(telemetryBackend, tracerProvider, meterProvider)
+	}
+}
+
+// WithLogger is an option for specifying the logger of the memory's events, such as messages
+// dropped because of their role. Defaults to slog.Default().
+func WithLogger(logger *slog.Logger) MemoryOption {
+	return func(b *Memory) {
+		b.Logger = logger
+	}
+}
+
+// WithRejectUnknownRoles is an option for failing loads and saves with messages of roles mem0
+// does not store, instead of dropping those messages.
+func WithRejectUnknownRoles() MemoryOption {
+	return func(b *Memory) {
+		b.RejectUnknownRoles = true
+	}
+}
+
+// WithClient is an option for sending the requests of the memory through c, so that they are
+// cancelled with the context of the call instead of being abandoned.
+func WithClient(c *Client) MemoryOption {
+	return func(b *Memory) {
+		b.Client = c
+	}
+}
+
+// WithTimeouts is an option for specifying the default timeouts of the requests of the
+// memory. Defaults to DefaultTimeouts().
+func WithTimeouts(timeouts Timeouts) MemoryOption {
+	return func(b *Memory) {
+		b.Timeouts = timeouts
+	}
+}
+
+// WithResolver is an option for resolving the user of every load and save from its context,
+// such as core.ResolveFromContext, so that one memory serves every user of a server. The
+// user ID of the memory is used when the resolver returns none.
+func WithResolver(resolver core.Resolver) MemoryOption {
+	return func(b *Memory) {
+		b.Resolver = resolver
+	}
+}
+
+// WithEncryption is an option for encrypting the content of messages with keys from provider
+// before they leave the process, for transcripts that mem0 must store but not read. mem0 is
+// told not to extract facts from them, which needs a Client, so the memory has none. Messages
+// that are not encrypted are rejected on read unless core.WithAllowPlaintext is given.
+func WithEncryption(provider core.KeyProvider, options ...core.EncryptorOption) MemoryOption {
+	return func(b *Memory) {
+		b.Encryptor = core.NewEncryptor(provider, options...)
+	}
+}
+
+// WithMemoryOptions is an option for applying core.Memory options, such as options shared by
+// every backend that are not mirrored in this package.
+func WithMemoryOptions(options ...core.Option) MemoryOption {
+	return func(b *Memory) {
+		for _, option := range options {
+			option(&b.Memory)
+		}
 	}
 }
 
 func applyMem0MemoryOptions(opts ...MemoryOption) *Memory {
 	m := &Memory{
-		ReturnMessages: true,
-		InputKey:       "",
-		OutputKey:      "",
-		HumanPrefix:    "Human",
-		AIPrefix:       "AI",
-		MemoryKey:      "history",
+		Memory: *core.ApplyOptions(),
+		Config: Config{Timeouts: DefaultTimeouts()},
 	}
 
 	for _, opt := range opts {
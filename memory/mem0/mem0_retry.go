@@ -0,0 +1,49 @@
+package mem0
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/bytectlgo/mem0-go/client"
+)
+
+// ClassifyError classifies the errors of the mem0 client for a core.RetryPolicy, by the status
+// code of the failed response when there is one.
+func ClassifyError(err error) core.Retryability {
+	if statusCode, ok := apiErrorStatusCode(err); ok {
+		return core.ClassifyStatus(statusCode)
+	}
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) {
+		return core.NotRetryable
+	}
+	return core.ClassifyNetworkError(err)
+}
+
+// translateError translates the errors of the mem0 client into the errors of core, by the
+// status code of the failed response when there is one. See core.NewBackendError.
+func translateError(ctx context.Context, err error) error {
+	statusCode, _ := apiErrorStatusCode(err)
+	return core.NewBackendError(ctx, telemetryBackend, statusCode, err)
+}
+
+// apiErrorStatusCode returns the status code of a failed response of the mem0 API. The errors
+// of the mem0-go client have no field for it, so it is read from the message they all start
+// with.
+func apiErrorStatusCode(err error) (int, bool) {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode, true
+	}
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) {
+		return 0, false
+	}
+	var statusCode int
+	if _, err := fmt.Sscanf(apiErr.Message, "API request failed with status %d", &statusCode); err != nil {
+		return 0, false
+	}
+	return statusCode, true
+}
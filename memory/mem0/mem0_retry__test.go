@@ -0,0 +1,117 @@
+package mem0
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/0xDezzy/langchaingo-memory/memory/mem0/mem0test"
+	"github.com/0xDezzy/langchaingo-memory/memory/memorytest"
+	"github.com/bytectlgo/mem0-go/client"
+)
+
+const addPath = "/v1/memories/"
+
+func newRetryHistory(t *testing.T, fault memorytest.Fault) (*mem0test.Server, *ChatMessageHistory) {
+	t.Helper()
+
+	server := mem0test.NewServer(mem0test.WithFault(fault))
+	t.Cleanup(server.Close)
+	mem0Client, err := server.NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	policy := core.DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	return server, NewMem0ChatMessageHistory(mem0Client, "test-user", WithChatHistoryRetryPolicy(policy))
+}
+
+func TestRetryPolicy(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("RetriesReads", func(t *testing.T) {
+		t.Parallel()
+		server, h := newRetryHistory(t, memorytest.Fault{
+			Method: http.MethodGet, Path: addPath, StatusCode: http.StatusServiceUnavailable, Times: 2,
+		})
+		if _, err := h.Messages(ctx); err != nil {
+			t.Fatalf("Messages: %v", err)
+		}
+		if n := server.RequestCount(http.MethodGet, addPath); n != 3 {
+			t.Errorf("Expected 2 retries, got %d requests", n)
+		}
+	})
+
+	t.Run("DoesNotRetryWritesOn503", func(t *testing.T) {
+		t.Parallel()
+		server, h := newRetryHistory(t, memorytest.Fault{
+			Method: http.MethodPost, Path: addPath, StatusCode: http.StatusServiceUnavailable,
+		})
+		if err := h.AddUserMessage(ctx, "hello"); err == nil {
+			t.Fatalf("Expected AddUserMessage to fail")
+		}
+		if n := server.RequestCount(http.MethodPost, addPath); n != 1 {
+			t.Errorf("Expected a single request, got %d", n)
+		}
+	})
+
+	t.Run("RetriesWritesOn429", func(t *testing.T) {
+		t.Parallel()
+		server, h := newRetryHistory(t, memorytest.Fault{
+			Method: http.MethodPost, Path: addPath, StatusCode: http.StatusTooManyRequests, Times: 1,
+		})
+		if err := h.AddUserMessage(ctx, "hello"); err != nil {
+			t.Fatalf("AddUserMessage: %v", err)
+		}
+		if n := server.RequestCount(http.MethodPost, addPath); n != 2 {
+			t.Errorf("Expected 1 retry, got %d requests", n)
+		}
+	})
+}
+
+func TestClassifyError(t *testing.T) {
+	t.Parallel()
+
+	errs := map[error]core.Retryability{
+		&client.APIError{Message: "API request failed with status 429: slow down"}: core.Retryable,
+		&client.APIError{Message: "API request failed with status 503"}:            core.RetryableIfIdempotent,
+		&client.APIError{Message: "API request failed with status 400: bad"}:       core.NotRetryable,
+		&client.APIError{Message: "API key is invalid"}:                            core.NotRetryable,
+		&client.APIError{Message: "memory status 503 is unknown"}:                  core.NotRetryable,
+		&StatusError{StatusCode: 429, Err: &client.APIError{Message: "slow down"}}: core.Retryable,
+		&StatusError{StatusCode: 502, Err: &client.APIError{}}:                     core.RetryableIfIdempotent,
+		context.Canceled:   core.NotRetryable,
+		errors.New("boom"): core.NotRetryable,
+	}
+	for err, expected := range errs {
+		if got := ClassifyError(err); got != expected {
+			t.Errorf("Expected %v to be classified %d, got %d", err, expected, got)
+		}
+	}
+}
+
+func TestTranslateError(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	_, h := newRetryHistory(t, memorytest.Fault{Method: http.MethodGet, Path: addPath, StatusCode: http.StatusUnauthorized})
+	_, err := h.Messages(ctx)
+	var apiErr *client.APIError
+	if !errors.Is(err, core.ErrUnauthorized) || !errors.As(err, &apiErr) {
+		t.Errorf("Expected ErrUnauthorized wrapping the mem0 error, got %v", err)
+	}
+
+	_, h = newRetryHistory(t, memorytest.Fault{Method: http.MethodGet, Path: addPath, StatusCode: http.StatusNotFound})
+	if messages, err := h.Messages(ctx); err != nil || len(messages) != 0 {
+		t.Errorf("Expected a missing user to be an empty history, got %v, %v", messages, err)
+	}
+
+	_, h = newRetryHistory(t, memorytest.Fault{Method: http.MethodPost, Path: addPath, StatusCode: http.StatusBadRequest})
+	if err := h.AddUserMessage(ctx, "hello"); !errors.Is(err, core.ErrInvalidInput) {
+		t.Errorf("Expected ErrInvalidInput, got %v", err)
+	}
+}
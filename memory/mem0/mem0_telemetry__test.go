@@ -0,0 +1,65 @@
+package mem0
+
+import (
+	"context"
+	"testing"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTelemetry(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	recorder := tracetest.NewSpanRecorder()
+	reader := sdkmetric.NewManualReader()
+	m := NewMemory(newConformanceClient(t), "test-user", WithTelemetry(
+		sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)),
+		sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+
+	if err := m.SaveContext(ctx, map[string]any{"input": "I live in Berlin"}, map[string]any{"output": "Noted."}); err != nil {
+		t.Fatalf("SaveContext: %v", err)
+	}
+	if _, err := m.LoadMemoryVariables(ctx, nil); err != nil {
+		t.Fatalf("LoadMemoryVariables: %v", err)
+	}
+
+	spans := recorder.Ended()
+	names := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range spans {
+		names[span.Name()] = span
+	}
+	for child, parent := range map[string]string{"mem0.AddMessages": "mem0.SaveContext", "mem0.Messages": "mem0.LoadMemoryVariables"} {
+		if names[child] == nil || names[parent] == nil ||
+			names[child].Parent().SpanID() != names[parent].SpanContext().SpanID() {
+			t.Errorf("Expected a %s span within a %s span", child, parent)
+		}
+	}
+	for _, kv := range names["mem0.Messages"].Attributes() {
+		if kv.Key == core.AttributeUserHash && kv.Value.AsString() == "test-user" {
+			t.Errorf("Expected the user to be hashed")
+		}
+	}
+
+	var metrics metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &metrics); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	var facts int64
+	for _, scope := range metrics.ScopeMetrics {
+		for _, metric := range scope.Metrics {
+			if metric.Name == "memory.facts.returned" {
+				for _, point := range metric.Data.(metricdata.Sum[int64]).DataPoints {
+					facts += point.Value
+				}
+			}
+		}
+	}
+	if facts != 1 {
+		t.Errorf("Expected 1 fact returned, got %d", facts)
+	}
+}
@@ -0,0 +1,160 @@
+package mem0test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/memorytest"
+	"github.com/bytectlgo/mem0-go/client"
+	"github.com/bytectlgo/mem0-go/types"
+)
+
+func newTestClient(t *testing.T, options ...ServerOption) (*Server, *client.MemoryClient) {
+	t.Helper()
+
+	s := NewServer(options...)
+	t.Cleanup(s.Close)
+	c, err := s.NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return s, c
+}
+
+func TestServerMemoryLifecycle(t *testing.T) {
+	t.Parallel()
+
+	s, c := newTestClient(t)
+	added, err := c.Add([]types.Message{
+		{Role: "user", Content: "I live in Berlin"},
+		{Role: "assistant", Content: "Noted!"},
+	}, types.MemoryOptions{UserID: "sarah"})
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if len(added) != 1 || added[0].ID != "mem-0001" || added[0].Memory != "I live in Berlin" {
+		t.Fatalf("Expected mem-0001 with the user message as memory, got %+v", added)
+	}
+	if _, err := c.Add("I like tea", types.MemoryOptions{UserID: "tom"}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	memory, err := c.Get("mem-0001")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(memory.Messages) != 2 || memory.UserID != "sarah" {
+		t.Errorf("Expected both messages for sarah, got %+v", memory)
+	}
+
+	if _, err := c.Update("mem-0001", "Lives in Munich"); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	history, err := c.History("mem-0001")
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(history) != 2 || history[1].Event != "UPDATE" || history[1].OldMemory != "I live in Berlin" {
+		t.Errorf("Expected ADD and UPDATE history, got %+v", history)
+	}
+
+	users, err := c.Users()
+	if err != nil {
+		t.Fatalf("Users: %v", err)
+	}
+	if users.Count != 2 || users.Results[0].ID != "sarah" {
+		t.Errorf("Expected users sarah and tom, got %+v", users)
+	}
+
+	if err := c.Delete("mem-0001"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := c.Get("mem-0001"); err == nil {
+		t.Errorf("Expected error getting a deleted memory")
+	}
+	if err := c.DeleteAll(types.MemoryOptions{UserID: "tom"}); err != nil {
+		t.Fatalf("DeleteAll: %v", err)
+	}
+	if memories := s.Memories(""); len(memories) != 0 {
+		t.Errorf("Expected no memories left, got %+v", memories)
+	}
+}
+
+func TestServerSearch(t *testing.T) {
+	t.Parallel()
+
+	_, c := newTestClient(t)
+	for _, fact := range []string{"Sarah works at TechCorp", "Sarah likes tea", "TechCorp is in Berlin"} {
+		if _, err := c.Add(fact, types.MemoryOptions{UserID: "sarah"}); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	if _, err := c.Add("Tom works at TechCorp", types.MemoryOptions{UserID: "tom"}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	results, err := c.Search("where does sarah work at techcorp", &types.SearchOptions{
+		MemoryOptions: types.MemoryOptions{UserID: "sarah"},
+		Limit:         2,
+	})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 2 || results[0].Memory != "Sarah works at TechCorp" {
+		t.Fatalf("Expected the best match first and 2 results, got %+v", results)
+	}
+	if results[0].Score <= results[1].Score {
+		t.Errorf("Expected results ordered by score, got %v and %v", results[0].Score, results[1].Score)
+	}
+}
+
+func TestServerFaults(t *testing.T) {
+	t.Parallel()
+
+	s, c := newTestClient(t, WithFault(memorytest.Fault{
+		Method:     http.MethodPost,
+		Path:       "/v1/memories/",
+		StatusCode: http.StatusTooManyRequests,
+		Times:      1,
+	}))
+
+	if _, err := c.Add("first", types.MemoryOptions{UserID: "sarah"}); err == nil {
+		t.Errorf("Expected the injected 429 to fail the first add")
+	}
+	if _, err := c.Add("second", types.MemoryOptions{UserID: "sarah"}); err != nil {
+		t.Errorf("Expected the fault to be used up, got %v", err)
+	}
+	if count := s.RequestCount(http.MethodPost, "/v1/memories/"); count != 2 {
+		t.Errorf("Expected 2 add requests, got %d", count)
+	}
+
+	s.InjectFault(memorytest.Fault{StatusCode: http.StatusInternalServerError})
+	if _, err := c.GetAll(nil); err == nil {
+		t.Errorf("Expected the injected 500 to fail GetAll")
+	}
+	s.ClearFaults()
+
+	s.InjectFault(memorytest.Fault{Latency: 50 * time.Millisecond})
+	start := time.Now()
+	if _, err := c.GetAll(nil); err != nil {
+		t.Fatalf("GetAll: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected the response to be delayed, took %v", elapsed)
+	}
+}
+
+func TestServerAPIKey(t *testing.T) {
+	t.Parallel()
+
+	s := NewServer(WithAPIKey("secret"))
+	defer s.Close()
+
+	if _, err := client.NewMemoryClient(client.ClientOptions{APIKey: "wrong", Host: s.URL}); err == nil {
+		t.Errorf("Expected an invalid API key to be rejected")
+	}
+	if _, err := s.NewClient(); err != nil {
+		t.Errorf("Expected the configured API key to be accepted, got %v", err)
+	}
+}
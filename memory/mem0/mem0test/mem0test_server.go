@@ -0,0 +1,401 @@
+// Package mem0test provides an in-memory fake of the mem0 REST API for tests. It serves the
+// endpoints used by this module from an httptest.Server, so a real client.MemoryClient can be
+// exercised end-to-end without network access.
+package mem0test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/memorytest"
+	"github.com/bytectlgo/mem0-go/client"
+	"github.com/bytectlgo/mem0-go/types"
+)
+
+// Server is a fake mem0 API. Memories get deterministic IDs ("mem-0001", "mem-0002", ...) and
+// timestamps, and facts are extracted naively: the memory text of an added batch is the
+// content of its user messages. Faults never apply to the ping endpoint, so clients can
+// always be created.
+type Server struct {
+	*httptest.Server
+	memorytest.FaultInjector
+
+	apiKey string
+	clock  func() time.Time
+
+	mu       sync.Mutex
+	memories []types.Memory
+	history  map[string][]types.MemoryHistory
+	ids      int
+	requests map[string]int
+}
+
+// NewServer starts a fake mem0 API. The caller must call Close when done.
+func NewServer(options ...ServerOption) *Server {
+	s := applyServerOptions(options...)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/memories/{$}", s.handleAdd)
+	mux.HandleFunc("GET /v1/memories/{$}", s.handleGetAll)
+	mux.HandleFunc("DELETE /v1/memories/{$}", s.handleDeleteAll)
+	mux.HandleFunc("POST /v1/memories/search/{$}", s.handleSearch)
+	mux.HandleFunc("GET /v1/memories/{id}/{$}", s.handleGet)
+	mux.HandleFunc("PUT /v1/memories/{id}/{$}", s.handleUpdate)
+	mux.HandleFunc("DELETE /v1/memories/{id}/{$}", s.handleDelete)
+	mux.HandleFunc("GET /v1/memories/{id}/history/{$}", s.handleHistory)
+	mux.HandleFunc("GET /v1/users/{$}", s.handleUsers)
+	faulty := s.Handler(mux)
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.apiKey != "" && r.Header.Get("Authorization") != "Token "+s.apiKey {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"detail": "Invalid API key"})
+			return
+		}
+		if r.Method == http.MethodGet && r.URL.Path == "/v1/ping/" {
+			writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "org_id": "org-test", "project_id": "project-test"})
+			return
+		}
+		s.mu.Lock()
+		s.requests[r.Method+" "+r.URL.Path]++
+		s.mu.Unlock()
+		faulty.ServeHTTP(w, r)
+	}))
+	return s
+}
+
+// NewClient returns a mem0 client for the server.
+func (s *Server) NewClient() (*client.MemoryClient, error) {
+	return client.NewMemoryClient(s.ClientOptions())
+}
+
+// ClientOptions returns the options of clients for the server.
+func (s *Server) ClientOptions() client.ClientOptions {
+	apiKey := s.apiKey
+	if apiKey == "" {
+		apiKey = "test"
+	}
+	return client.ClientOptions{APIKey: apiKey, Host: s.URL}
+}
+
+// Memories returns the stored memories of the user in the order they were added. An empty
+// user ID returns every memory.
+func (s *Server) Memories(userID string) []types.Memory {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.filter(filter{userID: userID})
+}
+
+// RequestCount returns how many requests with the given method and path the server has
+// received, excluding pings. Requests failed by an injected fault are counted.
+func (s *Server) RequestCount(method, path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[method+" "+path]
+}
+
+// filter selects memories by owner. Empty fields match every memory.
+type filter struct {
+	userID  string
+	agentID string
+	appID   string
+	runID   string
+}
+
+func queryFilter(query url.Values) filter {
+	return filter{
+		userID:  query.Get("user_id"),
+		agentID: query.Get("agent_id"),
+		appID:   query.Get("app_id"),
+		runID:   query.Get("run_id"),
+	}
+}
+
+func optionsFilter(options types.MemoryOptions) filter {
+	return filter{userID: options.UserID, agentID: options.AgentID, appID: options.AppID, runID: options.RunID}
+}
+
+func (f filter) matches(m types.Memory) bool {
+	return (f.userID == "" || f.userID == m.UserID) &&
+		(f.agentID == "" || f.agentID == m.AgentID) &&
+		(f.appID == "" || f.appID == m.AppID) &&
+		(f.runID == "" || f.runID == m.RunID)
+}
+
+// filter returns copies of the matching memories. The caller must hold s.mu.
+func (s *Server) filter(f filter) []types.Memory {
+	memories := []types.Memory{}
+	for _, m := range s.memories {
+		if f.matches(m) {
+			memories = append(memories, m)
+		}
+	}
+	return memories
+}
+
+// index returns the position of the memory with the given ID, or -1. The caller must hold s.mu.
+func (s *Server) index(id string) int {
+	for i, m := range s.memories {
+		if m.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// recordHistory appends a history entry for a memory. The caller must hold s.mu.
+func (s *Server) recordHistory(m types.Memory, event, oldMemory string, input []types.Message, now time.Time) {
+	entries := s.history[m.ID]
+	s.history[m.ID] = append(entries, types.MemoryHistory{
+		ID:        fmt.Sprintf("%s-history-%d", m.ID, len(entries)+1),
+		MemoryID:  m.ID,
+		Input:     input,
+		OldMemory: oldMemory,
+		NewMemory: m.Memory,
+		UserID:    m.UserID,
+		Event:     event,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+}
+
+// extract returns the naive memory text of a batch of messages.
+func extract(messages []types.Message) string {
+	var facts []string
+	for _, message := range messages {
+		if message.Role == "user" && message.Content != "" {
+			facts = append(facts, message.Content)
+		}
+	}
+	return strings.Join(facts, "\n")
+}
+
+func (s *Server) handleAdd(w http.ResponseWriter, r *http.Request) {
+	// Infer defaults to true, which the bool of MemoryOptions cannot tell from an explicit false.
+	var request struct {
+		types.MemoryOptions
+		Infer *bool `json:"infer"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if request.UserID == "" && request.AgentID == "" && request.AppID == "" && request.RunID == "" {
+		writeError(w, http.StatusBadRequest, "one of user_id, agent_id, app_id or run_id is required")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock()
+	s.ids++
+	var text string
+	if request.Infer == nil || *request.Infer {
+		text = extract(request.Messages)
+	}
+	m := types.Memory{
+		ID:         fmt.Sprintf("mem-%04d", s.ids),
+		Messages:   request.Messages,
+		Event:      "ADD",
+		Data:       &types.MemoryData{Memory: text},
+		Memory:     text,
+		UserID:     request.UserID,
+		AgentID:    request.AgentID,
+		AppID:      request.AppID,
+		RunID:      request.RunID,
+		Metadata:   request.Metadata,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		MemoryType: "add",
+	}
+	s.memories = append(s.memories, m)
+	s.recordHistory(m, "ADD", "", request.Messages, now)
+	writeJSON(w, http.StatusOK, []types.Memory{m})
+}
+
+func (s *Server) handleGetAll(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, s.filter(queryFilter(r.URL.Query())))
+}
+
+func (s *Server) handleDeleteAll(w http.ResponseWriter, r *http.Request) {
+	f := queryFilter(r.URL.Query())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock()
+	kept := s.memories[:0]
+	for _, m := range s.memories {
+		if f.matches(m) {
+			s.recordHistory(types.Memory{ID: m.ID, UserID: m.UserID}, "DELETE", m.Memory, nil, now)
+			continue
+		}
+		kept = append(kept, m)
+	}
+	s.memories = kept
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Memories deleted successfully!"})
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		types.SearchOptions
+		Query string `json:"query"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	memories := s.filter(optionsFilter(request.MemoryOptions))
+	s.mu.Unlock()
+
+	results := []types.Memory{}
+	for _, m := range memories {
+		m.Score = score(request.Query, m.Memory)
+		if m.Score > 0 && m.Score >= request.Threshold {
+			results = append(results, m)
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	limit := request.Limit
+	if request.TopK > 0 {
+		limit = request.TopK
+	}
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// score returns the fraction of the query words that appear in text.
+func score(query, text string) float64 {
+	queryWords := words(query)
+	if len(queryWords) == 0 {
+		return 0
+	}
+	textWords := map[string]bool{}
+	for _, word := range words(text) {
+		textWords[word] = true
+	}
+	matched := 0
+	for _, word := range queryWords {
+		if textWords[word] {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(queryWords))
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.index(r.PathValue("id"))
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "Memory not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.memories[i])
+}
+
+func (s *Server) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.index(r.PathValue("id"))
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "Memory not found")
+		return
+	}
+	now := s.clock()
+	m := &s.memories[i]
+	oldMemory := m.Memory
+	m.Memory = request.Text
+	m.Data = &types.MemoryData{Memory: request.Text}
+	m.Event = "UPDATE"
+	m.UpdatedAt = now
+	s.recordHistory(*m, "UPDATE", oldMemory, nil, now)
+	writeJSON(w, http.StatusOK, []types.Memory{*m})
+}
+
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.index(r.PathValue("id"))
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "Memory not found")
+		return
+	}
+	m := s.memories[i]
+	s.memories = append(s.memories[:i], s.memories[i+1:]...)
+	s.recordHistory(types.Memory{ID: m.ID, UserID: m.UserID}, "DELETE", m.Memory, nil, s.clock())
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Memory deleted successfully!"})
+}
+
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	history, ok := s.history[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Memory not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, history)
+}
+
+func (s *Server) handleUsers(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	users := types.AllUsers{Results: []types.User{}}
+	index := map[string]int{}
+	for _, m := range s.memories {
+		if m.UserID == "" {
+			continue
+		}
+		i, ok := index[m.UserID]
+		if !ok {
+			i = len(users.Results)
+			index[m.UserID] = i
+			users.Results = append(users.Results, types.User{
+				ID:        m.UserID,
+				Name:      m.UserID,
+				CreatedAt: m.CreatedAt,
+				Type:      "user",
+			})
+		}
+		users.Results[i].TotalMemories++
+		users.Results[i].UpdatedAt = m.UpdatedAt
+	}
+	users.Count = len(users.Results)
+	writeJSON(w, http.StatusOK, users)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,57 @@
+package mem0test
+
+import (
+	"time"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/memorytest"
+	"github.com/bytectlgo/mem0-go/types"
+)
+
+// ServerOption is a function for creating a new fake server
+// with other than the default values.
+type ServerOption func(s *Server)
+
+// WithAPIKey is an option for requiring an API key. By default any key is accepted.
+func WithAPIKey(apiKey string) ServerOption {
+	return func(s *Server) {
+		s.apiKey = apiKey
+	}
+}
+
+// WithClock is an option for specifying the clock used for memory timestamps. The default
+// clock starts at 2024-01-01T00:00:00Z and advances one second per call.
+func WithClock(clock func() time.Time) ServerOption {
+	return func(s *Server) {
+		s.clock = clock
+	}
+}
+
+// WithFault is an option for injecting a fault from the start.
+func WithFault(fault memorytest.Fault) ServerOption {
+	return func(s *Server) {
+		s.InjectFault(fault)
+	}
+}
+
+// WithLatency is an option for delaying every response by the given duration.
+func WithLatency(latency time.Duration) ServerOption {
+	return WithFault(memorytest.Fault{Latency: latency})
+}
+
+func applyServerOptions(options ...ServerOption) *Server {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := &Server{
+		clock: func() time.Time {
+			now = now.Add(time.Second)
+			return now
+		},
+		history:  map[string][]types.MemoryHistory{},
+		requests: map[string]int{},
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
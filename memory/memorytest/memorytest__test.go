@@ -0,0 +1,87 @@
+package memorytest
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// localHistory is an in-process chat message history used to check the suites themselves.
+type localHistory struct {
+	mu       sync.Mutex
+	messages []llms.ChatMessage
+}
+
+var _ core.Backend = &localHistory{}
+
+func (h *localHistory) Messages(ctx context.Context) ([]llms.ChatMessage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]llms.ChatMessage(nil), h.messages...), nil
+}
+
+func (h *localHistory) AddMessages(ctx context.Context, messages []llms.ChatMessage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.messages = append(h.messages, messages...)
+	return nil
+}
+
+func (h *localHistory) AddMessage(ctx context.Context, message llms.ChatMessage) error {
+	return h.AddMessages(ctx, []llms.ChatMessage{message})
+}
+
+func (h *localHistory) AddUserMessage(ctx context.Context, text string) error {
+	return h.AddMessage(ctx, llms.HumanChatMessage{Content: text})
+}
+
+func (h *localHistory) AddAIMessage(ctx context.Context, text string) error {
+	return h.AddMessage(ctx, llms.AIChatMessage{Content: text})
+}
+
+func (h *localHistory) SetMessages(ctx context.Context, messages []llms.ChatMessage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.messages = append([]llms.ChatMessage(nil), messages...)
+	return nil
+}
+
+func (h *localHistory) Clear(ctx context.Context) error {
+	return h.SetMessages(ctx, nil)
+}
+
+func TestChatMessageHistorySuite(t *testing.T) {
+	t.Parallel()
+
+	RunChatMessageHistorySuite(t, func(*testing.T) schema.ChatMessageHistory {
+		return &localHistory{}
+	})
+}
+
+func TestMemorySuite(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Messages", func(t *testing.T) {
+		RunMemorySuite(t, func(*testing.T) schema.Memory {
+			return core.NewMemory(&localHistory{})
+		})
+	})
+	t.Run("BufferString", func(t *testing.T) {
+		RunMemorySuite(t, func(*testing.T) schema.Memory {
+			return core.NewMemory(&localHistory{}, core.WithReturnMessages(false))
+		})
+	})
+}
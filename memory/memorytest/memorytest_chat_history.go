@@ -0,0 +1,242 @@
+// Package memorytest provides conformance test suites for schema.ChatMessageHistory and
+// schema.Memory implementations, so that every backend is held to the same behaviour.
+package memorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// ChatMessageHistoryFactory returns a new, empty chat message history. It is called once
+// per sub-test, and histories returned by separate calls must not share messages.
+type ChatMessageHistoryFactory func(t *testing.T) schema.ChatMessageHistory
+
+// concurrentWrites is the number of messages the concurrency tests write in parallel.
+const concurrentWrites = 8
+
+// SuiteOption configures a conformance suite.
+type SuiteOption func(*suite)
+
+type suite struct {
+	setMessages bool
+}
+
+// WithoutSetMessages skips the SetMessages checks, for backends whose SetMessages is a no-op.
+func WithoutSetMessages() SuiteOption {
+	return func(s *suite) {
+		s.setMessages = false
+	}
+}
+
+func applySuiteOptions(options ...SuiteOption) *suite {
+	s := &suite{setMessages: true}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// RunChatMessageHistorySuite runs the conformance suite against the chat message histories
+// returned by factory.
+//
+// System messages returned by Messages are ignored when comparing conversations, since
+// backends use them to carry facts and summaries rather than stored turns. Function messages
+// may come back as tool messages, as backends commonly store both under one role.
+func RunChatMessageHistorySuite(t *testing.T, factory ChatMessageHistoryFactory, options ...SuiteOption) {
+	t.Helper()
+	s := applySuiteOptions(options...)
+
+	t.Run("EmptySession", func(t *testing.T) {
+		h := factory(t)
+		messages := conversation(t, h)
+		if len(messages) != 0 {
+			t.Errorf("Expected no messages in a new session, got %v", messages)
+		}
+	})
+
+	t.Run("Ordering", func(t *testing.T) {
+		h := factory(t)
+		ctx := context.Background()
+		var expected []llms.ChatMessage
+		for i := 0; i < 3; i++ {
+			user := fmt.Sprintf("question %d", i)
+			ai := fmt.Sprintf("answer %d", i)
+			if err := h.AddUserMessage(ctx, user); err != nil {
+				t.Fatalf("AddUserMessage: %v", err)
+			}
+			if err := h.AddAIMessage(ctx, ai); err != nil {
+				t.Fatalf("AddAIMessage: %v", err)
+			}
+			expected = append(expected, llms.HumanChatMessage{Content: user}, llms.AIChatMessage{Content: ai})
+		}
+		assertConversation(t, expected, conversation(t, h))
+	})
+
+	t.Run("RoleRoundTrip", func(t *testing.T) {
+		h := factory(t)
+		ctx := context.Background()
+		expected := []llms.ChatMessage{
+			llms.HumanChatMessage{Content: "human"},
+			llms.AIChatMessage{Content: "ai"},
+			llms.ToolChatMessage{ID: "call-1", Content: "tool"},
+			llms.FunctionChatMessage{Name: "lookup", Content: "function"},
+		}
+		for _, message := range expected {
+			if err := h.AddMessage(ctx, message); err != nil {
+				t.Fatalf("AddMessage(%s): %v", message.GetType(), err)
+			}
+		}
+		assertConversation(t, expected, conversation(t, h))
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		h := factory(t)
+		ctx := context.Background()
+		if err := h.AddUserMessage(ctx, "forget me"); err != nil {
+			t.Fatalf("AddUserMessage: %v", err)
+		}
+		if err := h.Clear(ctx); err != nil {
+			t.Fatalf("Clear: %v", err)
+		}
+		if messages := conversation(t, h); len(messages) != 0 {
+			t.Errorf("Expected no messages after Clear, got %v", messages)
+		}
+		if err := h.AddUserMessage(ctx, "after clear"); err != nil {
+			t.Fatalf("AddUserMessage after Clear: %v", err)
+		}
+		assertConversation(t, []llms.ChatMessage{llms.HumanChatMessage{Content: "after clear"}}, conversation(t, h))
+	})
+
+	t.Run("SetMessages", func(t *testing.T) {
+		if !s.setMessages {
+			t.Skip("SetMessages is not supported")
+		}
+		h := factory(t)
+		ctx := context.Background()
+		if err := h.AddUserMessage(ctx, "replaced"); err != nil {
+			t.Fatalf("AddUserMessage: %v", err)
+		}
+		expected := []llms.ChatMessage{
+			llms.HumanChatMessage{Content: "new question"},
+			llms.AIChatMessage{Content: "new answer"},
+		}
+		if err := h.SetMessages(ctx, expected); err != nil {
+			t.Fatalf("SetMessages: %v", err)
+		}
+		assertConversation(t, expected, conversation(t, h))
+
+		if err := h.SetMessages(ctx, nil); err != nil {
+			t.Fatalf("SetMessages(nil): %v", err)
+		}
+		if messages := conversation(t, h); len(messages) != 0 {
+			t.Errorf("Expected no messages after SetMessages(nil), got %v", messages)
+		}
+	})
+
+	t.Run("Concurrency", func(t *testing.T) {
+		h := factory(t)
+		ctx := context.Background()
+		var wg sync.WaitGroup
+		// Every goroutine may fail both of its calls.
+		errs := make(chan error, 2*concurrentWrites)
+		for i := 0; i < concurrentWrites; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if err := h.AddUserMessage(ctx, fmt.Sprintf("message %d", i)); err != nil {
+					errs <- err
+				}
+				if _, err := h.Messages(ctx); err != nil {
+					errs <- err
+				}
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Errorf("Concurrent call failed: %v", err)
+		}
+
+		seen := map[string]bool{}
+		for _, message := range conversation(t, h) {
+			seen[message.GetContent()] = true
+		}
+		for i := 0; i < concurrentWrites; i++ {
+			if content := fmt.Sprintf("message %d", i); !seen[content] {
+				t.Errorf("Expected %q to be stored", content)
+			}
+		}
+	})
+
+	t.Run("ContextCancellation", func(t *testing.T) {
+		h := factory(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if _, err := h.Messages(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected Messages to return context.Canceled, got %v", err)
+		}
+		if err := h.AddUserMessage(ctx, "too late"); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected AddUserMessage to return context.Canceled, got %v", err)
+		}
+		if err := h.AddMessage(ctx, llms.AIChatMessage{Content: "too late"}); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected AddMessage to return context.Canceled, got %v", err)
+		}
+		if err := h.Clear(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected Clear to return context.Canceled, got %v", err)
+		}
+		if messages := conversation(t, h); len(messages) != 0 {
+			t.Errorf("Expected cancelled writes not to be stored, got %v", messages)
+		}
+	})
+}
+
+// conversation returns the messages of h without system messages.
+func conversation(t *testing.T, h schema.ChatMessageHistory) []llms.ChatMessage {
+	t.Helper()
+	return conversationOf(t, context.Background(), h)
+}
+
+// conversationOf returns the messages of h read with ctx, without system messages.
+func conversationOf(t *testing.T, ctx context.Context, h schema.ChatMessageHistory) []llms.ChatMessage {
+	t.Helper()
+
+	messages, err := h.Messages(ctx)
+	if err != nil {
+		t.Fatalf("Messages: %v", err)
+	}
+	var filtered []llms.ChatMessage
+	for _, message := range messages {
+		if message.GetType() != llms.ChatMessageTypeSystem {
+			filtered = append(filtered, message)
+		}
+	}
+	return filtered
+}
+
+func sameType(expected, actual llms.ChatMessageType) bool {
+	if expected == llms.ChatMessageTypeFunction {
+		return actual == llms.ChatMessageTypeFunction || actual == llms.ChatMessageTypeTool
+	}
+	return expected == actual
+}
+
+func assertConversation(t *testing.T, expected, actual []llms.ChatMessage) {
+	t.Helper()
+
+	if len(actual) != len(expected) {
+		t.Fatalf("Expected %d messages, got %d: %v", len(expected), len(actual), actual)
+	}
+	for i := range expected {
+		if !sameType(expected[i].GetType(), actual[i].GetType()) || expected[i].GetContent() != actual[i].GetContent() {
+			t.Errorf("Expected message %d to be %s %q, got %s %q", i,
+				expected[i].GetType(), expected[i].GetContent(), actual[i].GetType(), actual[i].GetContent())
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package memorytest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fault describes a failure a fake server injects into matching requests.
+type Fault struct {
+	// Method restricts the fault to one HTTP method. Empty matches every method.
+	Method string
+	// Path restricts the fault to request paths with this prefix. Empty matches every path.
+	Path string
+	// Latency delays the response. The delay ends early if the request is cancelled.
+	Latency time.Duration
+	// StatusCode fails the request with this status. Zero serves the request normally after
+	// the latency. A 429 response carries a Retry-After header of one second.
+	StatusCode int
+	// Times limits the fault to this many matching requests. Zero injects it until cleared.
+	Times int
+}
+
+func (f *Fault) matches(r *http.Request) bool {
+	return (f.Method == "" || f.Method == r.Method) && strings.HasPrefix(r.URL.Path, f.Path)
+}
+
+// FaultInjector injects faults into the requests served by a fake server. It is safe for
+// concurrent use.
+type FaultInjector struct {
+	mu     sync.Mutex
+	faults []*Fault
+}
+
+// InjectFault adds a fault. When several faults match a request, the first one added wins.
+func (i *FaultInjector) InjectFault(fault Fault) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.faults = append(i.faults, &fault)
+}
+
+// ClearFaults removes every fault.
+func (i *FaultInjector) ClearFaults() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.faults = nil
+}
+
+// next returns a copy of the first fault matching r, and uses up one of its times.
+func (i *FaultInjector) next(r *http.Request) (Fault, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for index, fault := range i.faults {
+		if !fault.matches(r) {
+			continue
+		}
+		matched := *fault
+		if fault.Times > 0 {
+			fault.Times--
+			if fault.Times == 0 {
+				i.faults = append(i.faults[:index], i.faults[index+1:]...)
+			}
+		}
+		return matched, true
+	}
+	return Fault{}, false
+}
+
+// Handler wraps next so that matching requests are delayed or failed.
+func (i *FaultInjector) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fault, ok := i.next(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if fault.Latency > 0 {
+			// The server only notices cancelled requests once their body is read.
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			timer := time.NewTimer(fault.Latency)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if fault.StatusCode == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if fault.StatusCode == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "1")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(fault.StatusCode)
+		_, _ = fmt.Fprintf(w, `{"message":%q}`, http.StatusText(fault.StatusCode))
+	})
+}
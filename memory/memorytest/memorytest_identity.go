@@ -0,0 +1,37 @@
+package memorytest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// RunUserIsolationSuite checks that the chat message histories returned by factory keep apart
+// the users they serve. Every history must resolve the user of every call from its context
+// with core.ResolveFromContext, as a wrapper given the resolver of the history it wraps does.
+func RunUserIsolationSuite(t *testing.T, factory ChatMessageHistoryFactory) {
+	t.Helper()
+
+	t.Run("UserIsolation", func(t *testing.T) {
+		h := factory(t)
+		sarah := core.ContextWithUserID(context.Background(), "sarah")
+		john := core.ContextWithUserID(context.Background(), "john")
+		// Reading first lets wrappers cache or buffer the empty history of each user.
+		for _, ctx := range []context.Context{sarah, john} {
+			if messages := conversationOf(t, ctx, h); len(messages) != 0 {
+				t.Fatalf("Expected no messages for a new user, got %v", messages)
+			}
+		}
+
+		if err := h.AddUserMessage(sarah, "I am sarah"); err != nil {
+			t.Fatalf("AddUserMessage: %v", err)
+		}
+		if err := h.AddUserMessage(john, "I am john"); err != nil {
+			t.Fatalf("AddUserMessage: %v", err)
+		}
+		assertConversation(t, []llms.ChatMessage{llms.HumanChatMessage{Content: "I am sarah"}}, conversationOf(t, sarah, h))
+		assertConversation(t, []llms.ChatMessage{llms.HumanChatMessage{Content: "I am john"}}, conversationOf(t, john, h))
+	})
+}
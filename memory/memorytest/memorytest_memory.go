@@ -0,0 +1,174 @@
+package memorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// MemoryFactory returns a new, empty memory. It is called once per sub-test, and memories
+// returned by separate calls must not share messages.
+type MemoryFactory func(t *testing.T) schema.Memory
+
+// RunMemorySuite runs the conformance suite against the memories returned by factory.
+//
+// Turns are saved with single-key input and output maps, so the memories must either leave
+// their input and output keys unset or accept any key. Loaded history may be returned either
+// as messages or as a buffer string, under the first memory variable; further variables, such
+// as flags set by wrappers, are not checked.
+func RunMemorySuite(t *testing.T, factory MemoryFactory) {
+	t.Helper()
+
+	t.Run("EmptyMemory", func(t *testing.T) {
+		m := factory(t)
+		if turns := loadTurns(t, m); len(turns) != 0 {
+			t.Errorf("Expected no history in a new memory, got %v", turns)
+		}
+	})
+
+	t.Run("SaveAndLoad", func(t *testing.T) {
+		m := factory(t)
+		var expected []string
+		for i := 0; i < 3; i++ {
+			input, output := fmt.Sprintf("question %d", i), fmt.Sprintf("answer %d", i)
+			saveTurn(t, m, input, output)
+			expected = append(expected, input, output)
+		}
+		assertTurns(t, expected, loadTurns(t, m))
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		m := factory(t)
+		ctx := context.Background()
+		saveTurn(t, m, "forget me", "forgotten")
+		if err := m.Clear(ctx); err != nil {
+			t.Fatalf("Clear: %v", err)
+		}
+		if turns := loadTurns(t, m); len(turns) != 0 {
+			t.Errorf("Expected no history after Clear, got %v", turns)
+		}
+		saveTurn(t, m, "after clear", "remembered")
+		assertTurns(t, []string{"after clear", "remembered"}, loadTurns(t, m))
+	})
+
+	t.Run("Concurrency", func(t *testing.T) {
+		m := factory(t)
+		ctx := context.Background()
+		var wg sync.WaitGroup
+		errs := make(chan error, concurrentWrites)
+		for i := 0; i < concurrentWrites; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				err := m.SaveContext(ctx,
+					map[string]any{"input": fmt.Sprintf("question %d", i)},
+					map[string]any{"output": fmt.Sprintf("answer %d", i)})
+				if err != nil {
+					errs <- err
+				}
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Errorf("Concurrent SaveContext failed: %v", err)
+		}
+
+		history := strings.Join(loadTurns(t, m), "\n")
+		for i := 0; i < concurrentWrites; i++ {
+			for _, content := range []string{fmt.Sprintf("question %d", i), fmt.Sprintf("answer %d", i)} {
+				if !strings.Contains(history, content) {
+					t.Errorf("Expected %q to be stored", content)
+				}
+			}
+		}
+	})
+
+	t.Run("ContextCancellation", func(t *testing.T) {
+		m := factory(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := m.SaveContext(ctx, map[string]any{"input": "too late"}, map[string]any{"output": "too late"})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected SaveContext to return context.Canceled, got %v", err)
+		}
+		if _, err := m.LoadMemoryVariables(ctx, map[string]any{}); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected LoadMemoryVariables to return context.Canceled, got %v", err)
+		}
+		if err := m.Clear(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected Clear to return context.Canceled, got %v", err)
+		}
+		if turns := loadTurns(t, m); len(turns) != 0 {
+			t.Errorf("Expected cancelled saves not to be stored, got %v", turns)
+		}
+	})
+}
+
+func saveTurn(t *testing.T, m schema.Memory, input, output string) {
+	t.Helper()
+
+	err := m.SaveContext(context.Background(), map[string]any{"input": input}, map[string]any{"output": output})
+	if err != nil {
+		t.Fatalf("SaveContext: %v", err)
+	}
+}
+
+// loadTurns loads the memory variables of m and returns the content of each stored turn of the
+// first variable, without system messages. Buffer strings are split into lines.
+func loadTurns(t *testing.T, m schema.Memory) []string {
+	t.Helper()
+
+	keys := m.MemoryVariables(context.Background())
+	if len(keys) == 0 {
+		t.Fatalf("Expected a memory variable, got none")
+	}
+	variables, err := m.LoadMemoryVariables(context.Background(), map[string]any{})
+	if err != nil {
+		t.Fatalf("LoadMemoryVariables: %v", err)
+	}
+	value, ok := variables[keys[0]]
+	if !ok {
+		t.Fatalf("Expected memory variable %q, got %v", keys[0], variables)
+	}
+
+	var turns []string
+	switch value := value.(type) {
+	case []llms.ChatMessage:
+		for _, message := range value {
+			if message.GetType() != llms.ChatMessageTypeSystem {
+				turns = append(turns, message.GetContent())
+			}
+		}
+	case string:
+		for _, line := range strings.Split(value, "\n") {
+			if line != "" {
+				turns = append(turns, line)
+			}
+		}
+	default:
+		t.Fatalf("Expected messages or a string for %q, got %T", keys[0], value)
+	}
+	return turns
+}
+
+// assertTurns checks that actual holds the expected contents in order. Buffer strings carry
+// role prefixes, so each turn only has to contain the expected content.
+func assertTurns(t *testing.T, expected, actual []string) {
+	t.Helper()
+
+	if len(actual) != len(expected) {
+		t.Fatalf("Expected %d turns, got %d: %v", len(expected), len(actual), actual)
+	}
+	for i := range expected {
+		if !strings.Contains(actual[i], expected[i]) {
+			t.Errorf("Expected turn %d to contain %q, got %q", i, expected[i], actual[i])
+		}
+	}
+}
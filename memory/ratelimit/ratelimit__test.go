@@ -0,0 +1,264 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/0xDezzy/langchaingo-memory/memory/mem0"
+	"github.com/0xDezzy/langchaingo-memory/memory/mem0/mem0test"
+	"github.com/0xDezzy/langchaingo-memory/memory/memorytest"
+	"github.com/tmc/langchaingo/schema"
+)
+
+func acquire(t *testing.T, l *Limiter, userID, sessionID string) error {
+	t.Helper()
+
+	release, err := l.Acquire(context.Background(), userID, sessionID)
+	if err == nil {
+		release()
+	}
+	return err
+}
+
+func TestLimiter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Scopes", func(t *testing.T) {
+		t.Parallel()
+		now := time.Unix(0, 0)
+		l := NewLimiter(WithFailFast(), WithClock(func() time.Time { return now }),
+			WithGlobalLimit(Limit{Rate: 10, Burst: 5}),
+			WithUserLimit(Limit{Rate: 1, Burst: 3}),
+			WithSessionLimit(Limit{Rate: 1, Burst: 2}))
+
+		for i := 0; i < 2; i++ {
+			if err := acquire(t, l, "sarah", "a"); err != nil {
+				t.Fatalf("Expected the burst of the session to be allowed, got %v", err)
+			}
+		}
+		var limited *RateLimitError
+		err := acquire(t, l, "sarah", "a")
+		if !errors.As(err, &limited) || !errors.Is(err, ErrRateLimited) || !errors.Is(err, core.ErrRateLimited) ||
+			limited.Scope != ScopeSession || limited.Key != "a" || limited.Wait != time.Second {
+			t.Fatalf("Expected the session limit, got %v", err)
+		}
+		if err := acquire(t, l, "sarah", "b"); err != nil {
+			t.Fatalf("Expected another session of the user to be allowed, got %v", err)
+		}
+		if err := acquire(t, l, "sarah", "c"); !errors.As(err, &limited) || limited.Scope != ScopeUser {
+			t.Fatalf("Expected the user limit, got %v", err)
+		}
+		if err := acquire(t, l, "bob", ""); err != nil {
+			t.Fatalf("Expected another user to be allowed, got %v", err)
+		}
+		if err := acquire(t, l, "alice", ""); err != nil {
+			t.Fatalf("Expected another user to be allowed, got %v", err)
+		}
+		if err := acquire(t, l, "carol", ""); !errors.As(err, &limited) || limited.Scope != ScopeGlobal {
+			t.Fatalf("Expected the global limit, got %v", err)
+		}
+
+		now = now.Add(time.Second)
+		if err := acquire(t, l, "sarah", "a"); err != nil {
+			t.Errorf("Expected the buckets to refill, got %v", err)
+		}
+	})
+
+	t.Run("Waits", func(t *testing.T) {
+		t.Parallel()
+		l := NewLimiter(WithSessionLimit(Limit{Rate: 20, Burst: 1}))
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			if err := acquire(t, l, "", "a"); err != nil {
+				t.Fatalf("Acquire: %v", err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+			t.Errorf("Expected to wait for the tokens, took %v", elapsed)
+		}
+	})
+
+	t.Run("Deadline", func(t *testing.T) {
+		t.Parallel()
+		l := NewLimiter(WithGlobalLimit(Limit{Rate: 1, Burst: 1}))
+		if err := acquire(t, l, "", ""); err != nil {
+			t.Fatalf("Acquire: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		if _, err := l.Acquire(ctx, "", ""); !errors.Is(err, ErrRateLimited) || time.Since(start) > 50*time.Millisecond {
+			t.Errorf("Expected to fail at once when the wait outlasts the deadline, got %v", err)
+		}
+	})
+
+	t.Run("MaxInFlight", func(t *testing.T) {
+		t.Parallel()
+		l := NewLimiter(WithMaxInFlight(1))
+		release, err := l.Acquire(context.Background(), "", "")
+		if err != nil {
+			t.Fatalf("Acquire: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if _, err := l.Acquire(ctx, "", ""); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected to wait for the request in flight, got %v", err)
+		}
+
+		failFast := NewLimiter(WithMaxInFlight(1), WithFailFast())
+		releaseFailFast, _ := failFast.Acquire(context.Background(), "", "")
+		var limited *RateLimitError
+		if err := acquire(t, failFast, "", ""); !errors.As(err, &limited) || limited.Scope != ScopeInFlight {
+			t.Errorf("Expected the in-flight cap, got %v", err)
+		}
+		releaseFailFast()
+		release()
+		release()
+		if err := acquire(t, l, "", ""); err != nil {
+			t.Errorf("Expected the request to be allowed once released, got %v", err)
+		}
+	})
+
+	t.Run("RefundsRejectedInFlight", func(t *testing.T) {
+		t.Parallel()
+		now := time.Unix(0, 0)
+		l := NewLimiter(WithFailFast(), WithMaxInFlight(1), WithClock(func() time.Time { return now }),
+			WithUserLimit(Limit{Rate: 0.001, Burst: 2}))
+		release, err := l.Acquire(context.Background(), "sarah", "")
+		if err != nil {
+			t.Fatalf("Acquire: %v", err)
+		}
+		var limited *RateLimitError
+		if err := acquire(t, l, "sarah", ""); !errors.As(err, &limited) || limited.Scope != ScopeInFlight {
+			t.Fatalf("Expected the in-flight cap, got %v", err)
+		}
+		release()
+		if err := acquire(t, l, "sarah", ""); err != nil {
+			t.Errorf("Expected the token of the rejected request to be given back, got %v", err)
+		}
+	})
+
+	t.Run("BoundedBuckets", func(t *testing.T) {
+		t.Parallel()
+		now := time.Unix(0, 0)
+		l := NewLimiter(WithClock(func() time.Time { return now }), WithSessionLimit(Limit{Rate: 0.001, Burst: 2}))
+		for i := 0; i < 2*maxBuckets; i++ {
+			now = now.Add(time.Millisecond)
+			if err := acquire(t, l, "", fmt.Sprint(i)); err != nil {
+				t.Fatalf("Acquire: %v", err)
+			}
+		}
+		if n := len(l.buckets[2].buckets); n > maxBuckets {
+			t.Errorf("Expected at most %d session buckets, got %d", maxBuckets, n)
+		}
+	})
+
+	t.Run("RetryPolicy", func(t *testing.T) {
+		t.Parallel()
+		l := NewLimiter(WithFailFast(), WithSessionLimit(Limit{Rate: 20, Burst: 1}))
+		policy := core.DefaultRetryPolicy()
+		policy.InitialBackoff = time.Millisecond
+		policy.Classify = func(err error) core.Retryability {
+			if errors.Is(err, ErrRateLimited) {
+				return core.Retryable
+			}
+			return core.NotRetryable
+		}
+		var waits []time.Duration
+		policy.OnRetry = func(_ context.Context, _ int, _ error, wait time.Duration) {
+			waits = append(waits, wait)
+		}
+		for i := 0; i < 2; i++ {
+			err := policy.Do(context.Background(), false, func(ctx context.Context) error {
+				return l.Do(ctx, "", "a", func(context.Context) error { return nil })
+			})
+			if err != nil {
+				t.Fatalf("Do: %v", err)
+			}
+		}
+		if len(waits) != 1 || waits[0] < 40*time.Millisecond {
+			t.Errorf("Expected a retry after the wait of the limit, got %v", waits)
+		}
+	})
+}
+
+func TestResolver(t *testing.T) {
+	t.Parallel()
+
+	newRemote := func(t *testing.T) schema.ChatMessageHistory {
+		server := mem0test.NewServer()
+		t.Cleanup(server.Close)
+		return mem0.NewClientChatMessageHistory(server.ClientOptions(), "", mem0.WithChatHistoryResolver(core.ResolveFromContext))
+	}
+	sarah := core.ContextWithUserID(context.Background(), "sarah")
+	john := core.ContextWithUserID(context.Background(), "john")
+
+	limiter := NewLimiter(WithFailFast(), WithUserLimit(Limit{Rate: 0.001, Burst: 1}))
+	if _, err := NewChatMessageHistory(newRemote(t), limiter, "", "").Messages(sarah); !errors.Is(err, core.ErrResolverRequired) {
+		t.Errorf("Expected ErrResolverRequired without a resolver, got %v", err)
+	}
+	h := NewChatMessageHistory(newRemote(t), limiter, "", "", WithChatHistoryResolver(core.ResolveFromContext))
+	if _, err := h.Messages(sarah); err != nil {
+		t.Fatalf("Messages: %v", err)
+	}
+	var limited *RateLimitError
+	if _, err := h.Messages(sarah); !errors.As(err, &limited) || limited.Key != "sarah" {
+		t.Errorf("Expected the limit of sarah, got %v", err)
+	}
+	if _, err := h.Messages(john); err != nil {
+		t.Errorf("Expected john to be limited apart from sarah, got %v", err)
+	}
+
+	unlimited := NewLimiter()
+	memorytest.RunUserIsolationSuite(t, func(t *testing.T) schema.ChatMessageHistory {
+		return NewChatMessageHistory(newRemote(t), unlimited, "", "", WithChatHistoryResolver(core.ResolveFromContext))
+	})
+}
+
+func TestMemory(t *testing.T) {
+	t.Parallel()
+	server := mem0test.NewServer()
+	t.Cleanup(server.Close)
+	remote := mem0.NewClientChatMessageHistory(server.ClientOptions(), "", mem0.WithChatHistoryResolver(core.ResolveFromContext))
+	sarah := core.ContextWithUserID(context.Background(), "sarah")
+
+	limiter := NewLimiter(WithFailFast(), WithUserLimit(Limit{Rate: 0.001, Burst: 1}))
+	m := NewMemory(remote, limiter, "", "", WithResolver(core.ResolveFromContext),
+		WithMemoryOptions(core.WithMemoryKey("chat")))
+	variables, err := m.LoadMemoryVariables(sarah, nil)
+	if err != nil {
+		t.Fatalf("LoadMemoryVariables: %v", err)
+	}
+	if _, ok := variables["chat"]; !ok {
+		t.Errorf("Expected the memory key of the options, got %v", variables)
+	}
+	var limited *RateLimitError
+	if _, err := m.LoadMemoryVariables(sarah, nil); !errors.As(err, &limited) || limited.Key != "sarah" {
+		t.Errorf("Expected the limit of the user resolved from the context, got %v", err)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	t.Parallel()
+
+	newRemote := func(t *testing.T) schema.ChatMessageHistory {
+		server := mem0test.NewServer()
+		t.Cleanup(server.Close)
+		client, err := server.NewClient()
+		if err != nil {
+			t.Fatalf("NewClient: %v", err)
+		}
+		return mem0.NewMem0ChatMessageHistory(client, "sarah")
+	}
+	limiter := NewLimiter(WithGlobalLimit(Limit{Rate: 1000, Burst: 100}), WithMaxInFlight(4))
+	memorytest.RunChatMessageHistorySuite(t, func(t *testing.T) schema.ChatMessageHistory {
+		return NewChatMessageHistory(newRemote(t), limiter, "sarah", "a")
+	}, memorytest.WithoutSetMessages())
+	memorytest.RunMemorySuite(t, func(t *testing.T) schema.Memory {
+		return NewMemory(newRemote(t), limiter, "sarah", "a")
+	})
+}
@@ -0,0 +1,147 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// ChatMessageHistory sends the requests of History through Limiter, as requests of UserID and
+// SessionID.
+type ChatMessageHistory struct {
+	History   schema.ChatMessageHistory
+	Limiter   *Limiter
+	UserID    string
+	SessionID string
+	// Resolver, if set, resolves the user and session of every request from its context, for
+	// a History that serves every user with a resolver of its own. UserID and SessionID are
+	// used when it resolves none.
+	Resolver core.Resolver
+}
+
+// Statically assert that ChatMessageHistory implement the chat message history interface.
+var _ schema.ChatMessageHistory = &ChatMessageHistory{}
+
+// Statically assert that ChatMessageHistory can be used as a core memory backend.
+var _ core.Backend = &ChatMessageHistory{}
+
+// Statically assert that ChatMessageHistory can provide facts.
+var _ core.FactSource = &ChatMessageHistory{}
+
+// Statically assert that ChatMessageHistory can tell wrappers whether it resolves identities.
+var _ core.IdentityResolver = &ChatMessageHistory{}
+
+// NewChatMessageHistory creates a history whose requests are limited by limiter, which is
+// shared by the histories of every tenant of the backend.
+func NewChatMessageHistory(
+	history schema.ChatMessageHistory, limiter *Limiter, userID, sessionID string, options ...ChatMessageHistoryOption,
+) *ChatMessageHistory {
+	h := applyChatHistoryOptions(options...)
+	h.History = history
+	h.Limiter = limiter
+	h.UserID = userID
+	h.SessionID = sessionID
+	return h
+}
+
+// ResolvesIdentity reports whether the history has a Resolver.
+func (h *ChatMessageHistory) ResolvesIdentity() bool {
+	return h.Resolver != nil
+}
+
+func (h *ChatMessageHistory) do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if h.Resolver == nil && core.ResolvesIdentity(h.History) {
+		return fmt.Errorf("ratelimit: %w", core.ErrResolverRequired)
+	}
+	userID, sessionID, err := core.Resolve(ctx, h.Resolver, h.UserID, h.SessionID)
+	if err != nil {
+		return fmt.Errorf("ratelimit: resolving user: %w", err)
+	}
+	return h.Limiter.Do(ctx, userID, sessionID, fn)
+}
+
+// Messages returns the messages of History.
+func (h *ChatMessageHistory) Messages(ctx context.Context) ([]llms.ChatMessage, error) {
+	var messages []llms.ChatMessage
+	err := h.do(ctx, func(ctx context.Context) error {
+		var err error
+		messages, err = h.History.Messages(ctx)
+		return err
+	})
+	return messages, err
+}
+
+// Facts returns the facts of History if it is a core.FactSource, and otherwise the lines of
+// the system messages of its messages.
+func (h *ChatMessageHistory) Facts(ctx context.Context) ([]string, error) {
+	source, ok := h.History.(core.FactSource)
+	if !ok {
+		messages, err := h.Messages(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var facts []string
+		for _, message := range messages {
+			if message.GetType() == llms.ChatMessageTypeSystem {
+				facts = append(facts, message.GetContent())
+			}
+		}
+		return facts, nil
+	}
+	var facts []string
+	err := h.do(ctx, func(ctx context.Context) error {
+		var err error
+		facts, err = source.Facts(ctx)
+		return err
+	})
+	return facts, err
+}
+
+// AddMessage adds a message to History.
+func (h *ChatMessageHistory) AddMessage(ctx context.Context, message llms.ChatMessage) error {
+	return h.AddMessages(ctx, []llms.ChatMessage{message})
+}
+
+// AddUserMessage adds a user message to History.
+func (h *ChatMessageHistory) AddUserMessage(ctx context.Context, text string) error {
+	return h.AddMessage(ctx, llms.HumanChatMessage{Content: text})
+}
+
+// AddAIMessage adds an AI message to History.
+func (h *ChatMessageHistory) AddAIMessage(ctx context.Context, text string) error {
+	return h.AddMessage(ctx, llms.AIChatMessage{Content: text})
+}
+
+// AddMessages adds messages to History, in a single request if it is a core.Backend and
+// otherwise in one limited request per message.
+func (h *ChatMessageHistory) AddMessages(ctx context.Context, messages []llms.ChatMessage) error {
+	if backend, ok := h.History.(core.Backend); ok {
+		return h.do(ctx, func(ctx context.Context) error {
+			return backend.AddMessages(ctx, messages)
+		})
+	}
+	for _, message := range messages {
+		err := h.do(ctx, func(ctx context.Context) error {
+			return h.History.AddMessage(ctx, message)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Clear clears History.
+func (h *ChatMessageHistory) Clear(ctx context.Context) error {
+	return h.do(ctx, h.History.Clear)
+}
+
+// SetMessages replaces the messages of History.
+func (h *ChatMessageHistory) SetMessages(ctx context.Context, messages []llms.ChatMessage) error {
+	return h.do(ctx, func(ctx context.Context) error {
+		return h.History.SetMessages(ctx, messages)
+	})
+}
@@ -0,0 +1,26 @@
+package ratelimit
+
+import "github.com/0xDezzy/langchaingo-memory/memory/core"
+
+// ChatMessageHistoryOption is a function for creating a new rate limited chat message history
+// with other than the default values.
+type ChatMessageHistoryOption func(h *ChatMessageHistory)
+
+// WithChatHistoryResolver is an option for resolving the user and session of every request
+// from its context, such as with core.ResolveFromContext, for a history that serves every
+// user with a resolver of its own.
+func WithChatHistoryResolver(resolver core.Resolver) ChatMessageHistoryOption {
+	return func(h *ChatMessageHistory) {
+		h.Resolver = resolver
+	}
+}
+
+func applyChatHistoryOptions(options ...ChatMessageHistoryOption) *ChatMessageHistory {
+	h := &ChatMessageHistory{}
+
+	for _, option := range options {
+		option(h)
+	}
+
+	return h
+}
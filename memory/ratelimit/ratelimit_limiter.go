@@ -0,0 +1,275 @@
+// Package ratelimit keeps the tenants sharing a mem0 API key or a Zep project from exhausting
+// its rate limits for each other. A Limiter applies token-bucket limits globally, per user and
+// per session, and caps the requests in flight; requests over the limits wait for their turn
+// or fail fast with a *RateLimitError.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+)
+
+// ErrRateLimited is matched by every *RateLimitError.
+var ErrRateLimited = errors.New("ratelimit: rate limited")
+
+// Scope names the limit that rejected a request.
+type Scope string
+
+const (
+	ScopeGlobal   Scope = "global"
+	ScopeUser     Scope = "user"
+	ScopeSession  Scope = "session"
+	ScopeInFlight Scope = "in-flight"
+)
+
+// RateLimitError is returned for requests over a limit, when the limiter fails fast or the
+// wait would outlast the deadline of the request.
+type RateLimitError struct {
+	Scope Scope
+	// Key is the user or session of the limit, empty for the global limits.
+	Key string
+	// Wait is how long the request would have had to wait, if known.
+	Wait time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("ratelimit: %s limit exceeded", e.Scope)
+	}
+	return fmt.Sprintf("ratelimit: %s limit exceeded for %s", e.Scope, e.Key)
+}
+
+// Is makes errors.Is(err, ErrRateLimited) and errors.Is(err, core.ErrRateLimited) match, so
+// that callers handle local and backend rate limits alike.
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited || target == core.ErrRateLimited
+}
+
+// RetryAfter returns Wait, so that a core.RetryPolicy waits that long before retrying.
+func (e *RateLimitError) RetryAfter() time.Duration {
+	return e.Wait
+}
+
+// Limit is a token-bucket limit: Rate requests per second on average, in bursts of up to Burst
+// requests. A zero Rate does not limit.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// bucket is the token bucket of a limit for one key.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// buckets are the token buckets of a limit by key.
+type buckets struct {
+	scope   Scope
+	limit   Limit
+	buckets map[string]*bucket
+}
+
+// maxBuckets is the number of buckets a limit keeps. Once it is reached, the buckets that are
+// full again, which are the same as new ones, are dropped, and then the buckets idle the
+// longest until a quarter of the buckets is free.
+const maxBuckets = 4096
+
+// take refills the bucket of key, takes a token and returns how long the caller must wait for
+// it. The caller must hold the lock of the limiter.
+func (b *buckets) take(key string, now time.Time) time.Duration {
+	if b.limit.Rate <= 0 {
+		return 0
+	}
+	bk := b.refill(key, now)
+	bk.tokens--
+	if bk.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(-bk.tokens / b.limit.Rate * float64(time.Second)))
+}
+
+// wait returns how long a token of key takes without taking it. The caller must hold the lock
+// of the limiter.
+func (b *buckets) wait(key string, now time.Time) time.Duration {
+	if b.limit.Rate <= 0 {
+		return 0
+	}
+	bk := b.refill(key, now)
+	if bk.tokens >= 1 {
+		return 0
+	}
+	return time.Duration(math.Ceil((1 - bk.tokens) / b.limit.Rate * float64(time.Second)))
+}
+
+// give returns a token taken by a request that was abandoned. The caller must hold the lock of
+// the limiter.
+func (b *buckets) give(key string) {
+	if bk, ok := b.buckets[key]; ok {
+		bk.tokens = min(bk.tokens+1, b.burst())
+	}
+}
+
+func (b *buckets) refill(key string, now time.Time) *bucket {
+	bk, ok := b.buckets[key]
+	if !ok {
+		if len(b.buckets) >= maxBuckets {
+			b.prune(now)
+		}
+		bk = &bucket{tokens: b.burst(), last: now}
+		b.buckets[key] = bk
+	}
+	bk.tokens = min(bk.tokens+now.Sub(bk.last).Seconds()*b.limit.Rate, b.burst())
+	bk.last = now
+	return bk
+}
+
+// prune drops the buckets that are full again, and then the buckets idle the longest if
+// fewer than a quarter of maxBuckets were full. A dropped bucket that was not full lets its key
+// burst again, which is the price of bounding the memory of the limiter.
+func (b *buckets) prune(now time.Time) {
+	for key, bk := range b.buckets {
+		if bk.tokens+now.Sub(bk.last).Seconds()*b.limit.Rate >= b.burst() {
+			delete(b.buckets, key)
+		}
+	}
+	if len(b.buckets) < maxBuckets*3/4 {
+		return
+	}
+	keys := slices.SortedFunc(maps.Keys(b.buckets), func(a, c string) int {
+		return b.buckets[a].last.Compare(b.buckets[c].last)
+	})
+	for _, key := range keys[:len(keys)-maxBuckets*3/4] {
+		delete(b.buckets, key)
+	}
+}
+
+func (b *buckets) burst() float64 {
+	return float64(max(b.limit.Burst, 1))
+}
+
+// Limiter limits the requests of every tenant sharing a backend. It is safe for concurrent
+// use.
+type Limiter struct {
+	global   Limit
+	user     Limit
+	session  Limit
+	failFast bool
+	now      func() time.Time
+	// inFlight holds a token for every request in flight, if the requests in flight are capped.
+	inFlight chan struct{}
+
+	mu      sync.Mutex
+	buckets []*buckets
+}
+
+// NewLimiter creates a limiter. Without options it does not limit.
+func NewLimiter(options ...LimiterOption) *Limiter {
+	l := applyLimiterOptions(options...)
+	for _, scoped := range []struct {
+		scope Scope
+		limit Limit
+	}{{ScopeGlobal, l.global}, {ScopeUser, l.user}, {ScopeSession, l.session}} {
+		l.buckets = append(l.buckets, &buckets{scope: scoped.scope, limit: scoped.limit, buckets: make(map[string]*bucket)})
+	}
+	return l
+}
+
+// Acquire waits until a request of the user and session is within the limits, and returns a
+// function to call when the request is done. An empty user or session is only subject to the
+// global limits. Acquire fails with a *RateLimitError instead of waiting if the limiter fails
+// fast or the wait would outlast the deadline of ctx, and with the error of ctx if ctx is done
+// while waiting.
+func (l *Limiter) Acquire(ctx context.Context, userID, sessionID string) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	keys := []string{"", userID, sessionID}
+
+	l.mu.Lock()
+	now := l.now()
+	var rejected *RateLimitError
+	for i, b := range l.buckets {
+		if keys[i] == "" && b.scope != ScopeGlobal {
+			continue
+		}
+		if wait := b.wait(keys[i], now); wait > 0 && (rejected == nil || wait > rejected.Wait) {
+			rejected = &RateLimitError{Scope: b.scope, Key: keys[i], Wait: wait}
+		}
+	}
+	if rejected != nil && (l.failFast || exceedsDeadline(ctx, rejected.Wait)) {
+		l.mu.Unlock()
+		return nil, rejected
+	}
+	var wait time.Duration
+	for i, b := range l.buckets {
+		if keys[i] == "" && b.scope != ScopeGlobal {
+			continue
+		}
+		wait = max(wait, b.take(keys[i], now))
+	}
+	l.mu.Unlock()
+
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			l.giveBack(keys)
+			return nil, ctx.Err()
+		}
+	}
+
+	if l.inFlight == nil {
+		return func() {}, nil
+	}
+	// A request that is rejected for the cap does not use up the tokens it took.
+	select {
+	case l.inFlight <- struct{}{}:
+	default:
+		if l.failFast {
+			l.giveBack(keys)
+			return nil, &RateLimitError{Scope: ScopeInFlight}
+		}
+		select {
+		case l.inFlight <- struct{}{}:
+		case <-ctx.Done():
+			l.giveBack(keys)
+			return nil, ctx.Err()
+		}
+	}
+	var once sync.Once
+	return func() { once.Do(func() { <-l.inFlight }) }, nil
+}
+
+func (l *Limiter) giveBack(keys []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, b := range l.buckets {
+		b.give(keys[i])
+	}
+}
+
+func exceedsDeadline(ctx context.Context, wait time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	return ok && time.Until(deadline) < wait
+}
+
+// Do calls fn once a request of the user and session is within the limits.
+func (l *Limiter) Do(ctx context.Context, userID, sessionID string, fn func(ctx context.Context) error) error {
+	release, err := l.Acquire(ctx, userID, sessionID)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return fn(ctx)
+}
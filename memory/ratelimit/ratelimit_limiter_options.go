@@ -0,0 +1,67 @@
+package ratelimit
+
+import "time"
+
+// LimiterOption is a function for creating a new limiter
+// with other than the default values.
+type LimiterOption func(l *Limiter)
+
+// WithGlobalLimit is an option for limiting the requests of every tenant together, e.g. below
+// the rate limit of the API key.
+func WithGlobalLimit(limit Limit) LimiterOption {
+	return func(l *Limiter) {
+		l.global = limit
+	}
+}
+
+// WithUserLimit is an option for limiting the requests of each user.
+func WithUserLimit(limit Limit) LimiterOption {
+	return func(l *Limiter) {
+		l.user = limit
+	}
+}
+
+// WithSessionLimit is an option for limiting the requests of each session.
+func WithSessionLimit(limit Limit) LimiterOption {
+	return func(l *Limiter) {
+		l.session = limit
+	}
+}
+
+// WithMaxInFlight is an option for capping the requests in flight across every tenant. Zero
+// or less does not cap them.
+func WithMaxInFlight(maxInFlight int) LimiterOption {
+	return func(l *Limiter) {
+		l.inFlight = nil
+		if maxInFlight > 0 {
+			l.inFlight = make(chan struct{}, maxInFlight)
+		}
+	}
+}
+
+// WithFailFast is an option for failing requests over the limits with a *RateLimitError
+// instead of waiting.
+func WithFailFast() LimiterOption {
+	return func(l *Limiter) {
+		l.failFast = true
+	}
+}
+
+// WithClock is an option for specifying the clock of the token buckets, for tests.
+func WithClock(now func() time.Time) LimiterOption {
+	return func(l *Limiter) {
+		l.now = now
+	}
+}
+
+func applyLimiterOptions(options ...LimiterOption) *Limiter {
+	l := &Limiter{
+		now: time.Now,
+	}
+
+	for _, option := range options {
+		option(l)
+	}
+
+	return l
+}
@@ -0,0 +1,30 @@
+package ratelimit
+
+import (
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// Memory is a core.Memory whose requests are rate limited. The schema.Memory implementation
+// comes from the embedded core.Memory.
+type Memory struct {
+	core.Memory
+	History *ChatMessageHistory
+}
+
+// Statically assert that Memory implement the memory interface.
+var _ schema.Memory = &Memory{}
+
+// NewMemory creates a memory whose requests are limited by limiter, as requests of userID and
+// sessionID.
+func NewMemory(
+	history schema.ChatMessageHistory, limiter *Limiter, userID, sessionID string, options ...MemoryOption,
+) *Memory {
+	m := applyRateLimitMemoryOptions(options...)
+	m.History.History = history
+	m.History.Limiter = limiter
+	m.History.UserID = userID
+	m.History.SessionID = sessionID
+	m.ChatHistory = m.History
+	return m
+}
@@ -0,0 +1,38 @@
+package ratelimit
+
+import "github.com/0xDezzy/langchaingo-memory/memory/core"
+
+// MemoryOption is a function for creating a new rate limited memory
+// with other than the default values.
+type MemoryOption func(m *Memory)
+
+// WithResolver is an option for resolving the user and session of every request from its
+// context, for a history that serves every user with a resolver of its own.
+func WithResolver(resolver core.Resolver) MemoryOption {
+	return func(m *Memory) {
+		WithChatHistoryResolver(resolver)(m.History)
+	}
+}
+
+// WithMemoryOptions is an option for applying core.Memory options, such as the memory key or
+// a token budget.
+func WithMemoryOptions(options ...core.Option) MemoryOption {
+	return func(m *Memory) {
+		for _, option := range options {
+			option(&m.Memory)
+		}
+	}
+}
+
+func applyRateLimitMemoryOptions(options ...MemoryOption) *Memory {
+	m := &Memory{
+		Memory:  *core.ApplyOptions(),
+		History: applyChatHistoryOptions(),
+	}
+
+	for _, option := range options {
+		option(m)
+	}
+
+	return m
+}
@@ -0,0 +1,180 @@
+package redact
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+type restoreKey struct{}
+
+// AuthorizeRestore returns a context whose reads restore the personal data of redacted
+// histories, for readers authorized to see it. Without it, reads return the placeholders.
+func AuthorizeRestore(ctx context.Context) context.Context {
+	return context.WithValue(ctx, restoreKey{}, true)
+}
+
+// restoreAuthorized reports whether ctx is authorized to restore personal data.
+func restoreAuthorized(ctx context.Context) bool {
+	authorized, _ := ctx.Value(restoreKey{}).(bool)
+	return authorized
+}
+
+// ChatMessageHistory redacts the messages written to History with Redactor, so that personal
+// data never reaches the backend. Reads with a context from AuthorizeRestore restore the data
+// from the vault of the redactor.
+type ChatMessageHistory struct {
+	History  schema.ChatMessageHistory
+	Redactor *Redactor
+}
+
+// Statically assert that ChatMessageHistory implement the chat message history interface.
+var _ schema.ChatMessageHistory = &ChatMessageHistory{}
+
+// Statically assert that ChatMessageHistory can be used as a core memory backend.
+var _ core.Backend = &ChatMessageHistory{}
+
+// Statically assert that ChatMessageHistory can provide facts.
+var _ core.FactSource = &ChatMessageHistory{}
+
+// NewChatMessageHistory creates a history redacting the messages written to history.
+func NewChatMessageHistory(history schema.ChatMessageHistory, redactor *Redactor) *ChatMessageHistory {
+	return &ChatMessageHistory{
+		History:  history,
+		Redactor: redactor,
+	}
+}
+
+// NewMemory returns a memory redacting the messages it saves to history.
+func NewMemory(history schema.ChatMessageHistory, redactor *Redactor, options ...core.Option) *core.Memory {
+	m := core.ApplyOptions(options...)
+	m.ChatHistory = NewChatMessageHistory(history, redactor)
+	return m
+}
+
+// Messages returns the messages of History, restored if ctx is authorized.
+func (h *ChatMessageHistory) Messages(ctx context.Context) ([]llms.ChatMessage, error) {
+	messages, err := h.History.Messages(ctx)
+	if err != nil || !restoreAuthorized(ctx) {
+		return messages, err
+	}
+	return h.transform(ctx, messages, h.Redactor.Restore)
+}
+
+// Facts returns the facts of History if it is a core.FactSource, and otherwise the lines of
+// the system messages of its messages, restored if ctx is authorized. Backends extract facts
+// from redacted messages, so the facts hold the same placeholders.
+func (h *ChatMessageHistory) Facts(ctx context.Context) ([]string, error) {
+	source, ok := h.History.(core.FactSource)
+	if !ok {
+		messages, err := h.Messages(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var facts []string
+		for _, message := range messages {
+			if message.GetType() == llms.ChatMessageTypeSystem {
+				facts = append(facts, message.GetContent())
+			}
+		}
+		return facts, nil
+	}
+	facts, err := source.Facts(ctx)
+	if err != nil || !restoreAuthorized(ctx) {
+		return facts, err
+	}
+	for i, fact := range facts {
+		if facts[i], err = h.Redactor.Restore(ctx, fact); err != nil {
+			return nil, err
+		}
+	}
+	return facts, nil
+}
+
+// AddMessage redacts a message and adds it to History.
+func (h *ChatMessageHistory) AddMessage(ctx context.Context, message llms.ChatMessage) error {
+	return h.AddMessages(ctx, []llms.ChatMessage{message})
+}
+
+// AddUserMessage redacts a user message and adds it to History.
+func (h *ChatMessageHistory) AddUserMessage(ctx context.Context, text string) error {
+	return h.AddMessage(ctx, llms.HumanChatMessage{Content: text})
+}
+
+// AddAIMessage redacts an AI message and adds it to History.
+func (h *ChatMessageHistory) AddAIMessage(ctx context.Context, text string) error {
+	return h.AddMessage(ctx, llms.AIChatMessage{Content: text})
+}
+
+// AddMessages redacts messages and adds them to History, in a single request if it is a
+// core.Backend. Nothing is added if any message cannot be redacted.
+func (h *ChatMessageHistory) AddMessages(ctx context.Context, messages []llms.ChatMessage) error {
+	redacted, err := h.transform(ctx, messages, h.Redactor.Redact)
+	if err != nil {
+		return err
+	}
+	if backend, ok := h.History.(core.Backend); ok {
+		return backend.AddMessages(ctx, redacted)
+	}
+	for _, message := range redacted {
+		if err := h.History.AddMessage(ctx, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Clear clears History.
+func (h *ChatMessageHistory) Clear(ctx context.Context) error {
+	return h.History.Clear(ctx)
+}
+
+// SetMessages redacts messages and replaces the messages of History with them.
+func (h *ChatMessageHistory) SetMessages(ctx context.Context, messages []llms.ChatMessage) error {
+	redacted, err := h.transform(ctx, messages, h.Redactor.Redact)
+	if err != nil {
+		return err
+	}
+	return h.History.SetMessages(ctx, redacted)
+}
+
+// transform returns copies of messages with their content transformed by fn. Messages of
+// unknown types fail, rather than reaching the backend unredacted.
+func (h *ChatMessageHistory) transform(
+	ctx context.Context, messages []llms.ChatMessage, fn func(ctx context.Context, text string) (string, error),
+) ([]llms.ChatMessage, error) {
+	transformed := make([]llms.ChatMessage, 0, len(messages))
+	for _, message := range messages {
+		content, err := fn(ctx, message.GetContent())
+		if err != nil {
+			return nil, err
+		}
+		switch m := message.(type) {
+		case llms.HumanChatMessage:
+			m.Content = content
+			message = m
+		case llms.AIChatMessage:
+			m.Content = content
+			message = m
+		case llms.SystemChatMessage:
+			m.Content = content
+			message = m
+		case llms.GenericChatMessage:
+			m.Content = content
+			message = m
+		case llms.FunctionChatMessage:
+			m.Content = content
+			message = m
+		case llms.ToolChatMessage:
+			m.Content = content
+			message = m
+		default:
+			return nil, fmt.Errorf("redact: cannot redact messages of type %T", message)
+		}
+		transformed = append(transformed, message)
+	}
+	return transformed, nil
+}
@@ -0,0 +1,175 @@
+// Package redact replaces personal data in chat messages with typed placeholders before they
+// are sent to mem0 or Zep. Detectors find the data, from regular expressions or an LLM, and a
+// Redactor replaces it with placeholders such as [EMAIL]. With a Vault, placeholders become
+// tokens such as [EMAIL_3f9a1c2e5b7d4e60] that authorized readers can restore.
+package redact
+
+import (
+	"context"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The types of the built-in detectors.
+const (
+	TypeEmail      = "EMAIL"
+	TypePhone      = "PHONE"
+	TypeCreditCard = "CREDIT_CARD"
+	TypeIBAN       = "IBAN"
+	TypeAPIKey     = "API_KEY"
+	TypeJWT        = "JWT"
+)
+
+// Match is personal data found in a text, from byte offset Start to End.
+type Match struct {
+	Type  string
+	Start int
+	End   int
+}
+
+// Detector finds personal data in texts.
+type Detector interface {
+	Detect(ctx context.Context, text string) ([]Match, error)
+}
+
+// RegexpDetector finds the matches of Regexp, of type Type, that pass Validate.
+type RegexpDetector struct {
+	Type   string
+	Regexp *regexp.Regexp
+	// Validate rejects false positives, such as card numbers failing the Luhn check. Nil
+	// accepts every match.
+	Validate func(match string) bool
+}
+
+// Statically assert that RegexpDetector implement the detector interface.
+var _ Detector = &RegexpDetector{}
+
+// NewRegexpDetector returns a detector of the matches of expr, of type typ, such as a custom
+// detector of customer numbers.
+func NewRegexpDetector(typ string, expr *regexp.Regexp) *RegexpDetector {
+	return &RegexpDetector{Type: typ, Regexp: expr}
+}
+
+// Detect returns the valid matches of the regular expression in text.
+func (d *RegexpDetector) Detect(_ context.Context, text string) ([]Match, error) {
+	var matches []Match
+	for _, loc := range d.Regexp.FindAllStringIndex(text, -1) {
+		if d.Validate != nil && !d.Validate(text[loc[0]:loc[1]]) {
+			continue
+		}
+		matches = append(matches, Match{Type: d.Type, Start: loc[0], End: loc[1]})
+	}
+	return matches, nil
+}
+
+var (
+	emailRegexp = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+	phoneRegexp = regexp.MustCompile(`(?:` +
+		`\+\d{1,3}(?:[ .-]?\(\d{1,4}\))?(?:[ .-]?\d{2,4}){2,5}` + // international: +49 30 1234567
+		`|\(\d{2,4}\)[ .-]?\d{3,4}[ .-]?\d{3,4}` + // area code in parentheses: (555) 123-4567
+		`|\b0[1-9]\d{0,3}[ /.-][1-9]\d{2,7}(?:[ .-]\d{3,4})?` + // trunk prefix: 030 1234567
+		`|\b\d{3}[.-]\d{3}[.-]\d{4}` + // separated groups: 555-123-4567
+		`)\b`)
+	creditCardRegexp = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+	ibanRegexp       = regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]){11,30}\b`)
+	apiKeyRegexp     = regexp.MustCompile(`\b(?:` +
+		`(?:sk|pk|rk)-[A-Za-z0-9_-]{16,}` + // OpenAI, Anthropic, Stripe
+		`|(?:sk|pk|rk)_(?:live|test)_[A-Za-z0-9]{16,}` + // Stripe
+		`|AKIA[0-9A-Z]{16}` + // AWS access key IDs
+		`|gh[pousr]_[A-Za-z0-9]{36,}` + // GitHub
+		`|xox[abprs]-[A-Za-z0-9-]{10,}` + // Slack
+		`|AIza[0-9A-Za-z_-]{35}` + // Google
+		`)`)
+	jwtRegexp = regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`)
+)
+
+// Email returns a detector of email addresses.
+func Email() *RegexpDetector {
+	return &RegexpDetector{Type: TypeEmail, Regexp: emailRegexp}
+}
+
+// Phone returns a detector of phone numbers of 7 to 15 digits, in national or international
+// format. Numbers need the structure of a phone number, such as a country code, a trunk prefix
+// or a parenthesized area code, so that IDs, timestamps and dates are left alone.
+func Phone() *RegexpDetector {
+	return &RegexpDetector{Type: TypePhone, Regexp: phoneRegexp, Validate: func(match string) bool {
+		n := len(digits(match))
+		return n >= 7 && n <= 15
+	}}
+}
+
+// CreditCard returns a detector of card numbers of 13 to 19 digits passing the Luhn check.
+func CreditCard() *RegexpDetector {
+	return &RegexpDetector{Type: TypeCreditCard, Regexp: creditCardRegexp, Validate: func(match string) bool {
+		number := digits(match)
+		return len(number) >= 13 && len(number) <= 19 && luhn(number)
+	}}
+}
+
+// IBAN returns a detector of IBANs passing the ISO 7064 mod 97 check, with or without spaces.
+func IBAN() *RegexpDetector {
+	return &RegexpDetector{Type: TypeIBAN, Regexp: ibanRegexp, Validate: validIBAN}
+}
+
+// APIKey returns a detector of API keys with well-known prefixes, such as those of OpenAI,
+// Anthropic, Stripe, AWS, GitHub, Slack and Google.
+func APIKey() *RegexpDetector {
+	return &RegexpDetector{Type: TypeAPIKey, Regexp: apiKeyRegexp}
+}
+
+// JWT returns a detector of JSON Web Tokens.
+func JWT() *RegexpDetector {
+	return &RegexpDetector{Type: TypeJWT, Regexp: jwtRegexp}
+}
+
+// DefaultDetectors returns the built-in detectors.
+func DefaultDetectors() []Detector {
+	return []Detector{Email(), Phone(), CreditCard(), IBAN(), APIKey(), JWT()}
+}
+
+// digits returns the digits of s.
+func digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// luhn reports whether number, a string of digits, passes the Luhn check.
+func luhn(number string) bool {
+	sum := 0
+	for i := 0; i < len(number); i++ {
+		d := int(number[len(number)-1-i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// validIBAN reports whether iban passes the ISO 7064 mod 97 check: moved to the end of the
+// number, the country code and check digits must leave a remainder of 1.
+func validIBAN(iban string) bool {
+	iban = strings.ReplaceAll(iban, " ", "")
+	var numeric strings.Builder
+	for _, r := range iban[4:] + iban[:4] {
+		switch {
+		case r >= '0' && r <= '9':
+			numeric.WriteRune(r)
+		case r >= 'A' && r <= 'Z':
+			numeric.WriteString(strconv.Itoa(int(r-'A') + 10))
+		default:
+			return false
+		}
+	}
+	n, ok := new(big.Int).SetString(numeric.String(), 10)
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
@@ -0,0 +1,72 @@
+package redact
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// DefaultModelPrompt asks the model to list the personal data in a text as JSON. {text} is
+// replaced before the prompt is sent.
+const DefaultModelPrompt = `List every piece of personal data in the text below, such as names of people, postal addresses, dates of birth, national ID numbers and account numbers. Answer with a JSON array of objects with a "type" field, an upper case name of the kind of data such as PERSON or ADDRESS, and a "text" field, the data exactly as it appears in the text. Answer with [] if there is none.
+
+Text:
+{text}
+
+JSON:`
+
+// ModelDetector finds personal data that regular expressions cannot, such as names and
+// addresses, by asking Model. Every occurrence of the data listed by the model is matched.
+type ModelDetector struct {
+	Model  llms.Model
+	Prompt string
+}
+
+// Statically assert that ModelDetector implement the detector interface.
+var _ Detector = &ModelDetector{}
+
+// NewModelDetector returns a detector asking model with DefaultModelPrompt.
+func NewModelDetector(model llms.Model) *ModelDetector {
+	return &ModelDetector{Model: model, Prompt: DefaultModelPrompt}
+}
+
+// Detect asks the model for the personal data in text.
+func (d *ModelDetector) Detect(ctx context.Context, text string) ([]Match, error) {
+	prompt := strings.ReplaceAll(d.Prompt, "{text}", text)
+	answer, err := llms.GenerateFromSinglePrompt(ctx, d.Model, prompt)
+	if err != nil {
+		return nil, fmt.Errorf("redact: detecting personal data: %w", err)
+	}
+	// Models like to wrap JSON in code fences or prose, so only the array is parsed.
+	start, end := strings.Index(answer, "["), strings.LastIndex(answer, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("redact: detecting personal data: no JSON array in %q", answer)
+	}
+	var found []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal([]byte(answer[start:end+1]), &found); err != nil {
+		return nil, fmt.Errorf("redact: detecting personal data: %w", err)
+	}
+
+	var matches []Match
+	for _, f := range found {
+		if f.Text == "" {
+			continue
+		}
+		for offset := 0; ; {
+			i := strings.Index(text[offset:], f.Text)
+			if i < 0 {
+				break
+			}
+			start := offset + i
+			offset = start + len(f.Text)
+			matches = append(matches, Match{Type: f.Type, Start: start, End: offset})
+		}
+	}
+	return matches, nil
+}
@@ -0,0 +1,120 @@
+package redact
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// tokenRegexp matches the placeholders of a Redactor with a vault.
+var tokenRegexp = regexp.MustCompile(`\[([A-Z0-9_]+_[0-9a-f]{16})\]`)
+
+// Redactor replaces the personal data found by Detectors with placeholders of its type, such
+// as [EMAIL]. With a Vault, the placeholders hold tokens, such as [EMAIL_3f9a1c2e5b7d4e60],
+// that Restore replaces with the original data.
+type Redactor struct {
+	Detectors []Detector
+	Vault     Vault
+}
+
+// NewRedactor returns a redactor with the built-in detectors and no vault.
+func NewRedactor(options ...RedactorOption) *Redactor {
+	return applyRedactorOptions(options...)
+}
+
+// Redact returns text with its personal data replaced. When matches overlap, the one starting
+// first is kept, and the longest of those starting together.
+func (r *Redactor) Redact(ctx context.Context, text string) (string, error) {
+	var matches []Match
+	for _, detector := range r.Detectors {
+		found, err := detector.Detect(ctx, text)
+		if err != nil {
+			return "", err
+		}
+		matches = append(matches, found...)
+	}
+	if len(matches) == 0 {
+		return text, nil
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Start != matches[j].Start {
+			return matches[i].Start < matches[j].Start
+		}
+		return matches[i].End > matches[j].End
+	})
+
+	var b strings.Builder
+	end := 0
+	for _, m := range matches {
+		if m.Start < end || m.End <= m.Start || m.End > len(text) {
+			continue
+		}
+		placeholder, err := r.placeholder(ctx, placeholderType(m.Type), text[m.Start:m.End])
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(text[end:m.Start])
+		b.WriteString(placeholder)
+		end = m.End
+	}
+	b.WriteString(text[end:])
+	return b.String(), nil
+}
+
+// placeholder returns the placeholder of value, of type typ.
+func (r *Redactor) placeholder(ctx context.Context, typ, value string) (string, error) {
+	if r.Vault == nil {
+		return "[" + typ + "]", nil
+	}
+	token, err := r.Vault.Tokenize(ctx, typ, value)
+	if err != nil {
+		return "", err
+	}
+	return "[" + token + "]", nil
+}
+
+// placeholderType returns typ in upper case, with the characters that placeholders cannot hold
+// replaced by underscores.
+func placeholderType(typ string) string {
+	typ = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		}
+		return '_'
+	}, typ)
+	if typ == "" {
+		return "PII"
+	}
+	return typ
+}
+
+// Restore returns text with the tokens of the vault replaced by the original data. Tokens the
+// vault does not know, and placeholders without tokens, are kept.
+func (r *Redactor) Restore(ctx context.Context, text string) (string, error) {
+	if r.Vault == nil {
+		return text, nil
+	}
+	var err error
+	restored := tokenRegexp.ReplaceAllStringFunc(text, func(placeholder string) string {
+		if err != nil {
+			return placeholder
+		}
+		value, ok, detokenizeErr := r.Vault.Detokenize(ctx, placeholder[1:len(placeholder)-1])
+		if detokenizeErr != nil {
+			err = detokenizeErr
+			return placeholder
+		}
+		if !ok {
+			return placeholder
+		}
+		return value
+	})
+	if err != nil {
+		return "", err
+	}
+	return restored, nil
+}
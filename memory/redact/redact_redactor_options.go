@@ -0,0 +1,33 @@
+package redact
+
+// RedactorOption is a function for creating a new redactor
+// with other than the default values.
+type RedactorOption func(r *Redactor)
+
+// WithDetectors is an option for specifying the detectors of personal data, replacing the
+// built-in ones. Add DefaultDetectors() to keep them.
+func WithDetectors(detectors ...Detector) RedactorOption {
+	return func(r *Redactor) {
+		r.Detectors = detectors
+	}
+}
+
+// WithVault is an option for replacing personal data with tokens kept in vault, which
+// authorized readers can restore.
+func WithVault(vault Vault) RedactorOption {
+	return func(r *Redactor) {
+		r.Vault = vault
+	}
+}
+
+func applyRedactorOptions(options ...RedactorOption) *Redactor {
+	r := &Redactor{
+		Detectors: DefaultDetectors(),
+	}
+
+	for _, option := range options {
+		option(r)
+	}
+
+	return r
+}
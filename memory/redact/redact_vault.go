@@ -0,0 +1,183 @@
+package redact
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// ErrInvalidKey is returned for vault keys that are not 32 bytes long.
+var ErrInvalidKey = errors.New("redact: vault keys must be 32 bytes long")
+
+// Vault keeps the personal data replaced by tokens, so that authorized readers can restore it.
+type Vault interface {
+	// Tokenize stores value, personal data of type typ, and returns its token. The same value
+	// gets the same token, so that the backend sees repeated data as the same entity.
+	Tokenize(ctx context.Context, typ, value string) (string, error)
+	// Detokenize returns the value of token, false if the vault does not know the token.
+	Detokenize(ctx context.Context, token string) (string, bool, error)
+}
+
+// FileVault is a Vault in a local file encrypted with AES-256-GCM. Tokens are derived from the
+// values with HMAC-SHA256, so they reveal nothing without the key. Every new value is appended
+// to the file as a line of its own. It is safe for concurrent use, but not for use by several
+// processes.
+type FileVault struct {
+	path     string
+	aead     cipher.AEAD
+	tokenKey []byte
+
+	mu     sync.Mutex
+	values map[string]string
+}
+
+// Statically assert that FileVault implement the vault interface.
+var _ Vault = &FileVault{}
+
+// vaultEntry is a line of a FileVault, before encryption.
+type vaultEntry struct {
+	Token string `json:"token"`
+	Value string `json:"value"`
+}
+
+// NewFileVault opens the vault at path with key, which must be 32 bytes long, or creates it on
+// the first Tokenize when the file does not exist.
+func NewFileVault(path string, key []byte) (*FileVault, error) {
+	if len(key) != 32 {
+		return nil, ErrInvalidKey
+	}
+	block, err := aes.NewCipher(deriveKey(key, "encryption"))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	v := &FileVault{path: path, aead: aead, tokenKey: deriveKey(key, "tokens"), values: map[string]string{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return v, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	lines := bytes.Split(data, []byte("\n"))
+	for _, line := range lines[:len(lines)-1] {
+		entry, err := v.open(line)
+		if err != nil {
+			return nil, fmt.Errorf("redact: opening vault %s: %w", path, err)
+		}
+		v.values[entry.Token] = entry.Value
+	}
+	// A crash while appending leaves a last line without its newline. Its value was never
+	// tokenized, so it is cut off before the next append.
+	if torn := lines[len(lines)-1]; len(torn) > 0 {
+		if err := os.Truncate(path, int64(len(data)-len(torn))); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// open decrypts a line of the vault.
+func (v *FileVault) open(line []byte) (vaultEntry, error) {
+	var entry vaultEntry
+	data, err := base64.StdEncoding.DecodeString(string(line))
+	if err != nil {
+		return entry, err
+	}
+	nonceSize := v.aead.NonceSize()
+	if len(data) < nonceSize {
+		return entry, errors.New("truncated entry")
+	}
+	plaintext, err := v.aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return entry, err
+	}
+	err = json.Unmarshal(plaintext, &entry)
+	return entry, err
+}
+
+// deriveKey derives the key of one use from the key of the vault, so that the same key is
+// never used for both encryption and tokens.
+func deriveKey(key []byte, label string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(label))
+	return mac.Sum(nil)
+}
+
+// Tokenize returns the token of value, such as EMAIL_3f9a1c2e5b7d4e60, writing the vault when
+// the value is new.
+func (v *FileVault) Tokenize(_ context.Context, typ, value string) (string, error) {
+	mac := hmac.New(sha256.New, v.tokenKey)
+	mac.Write([]byte(typ + "\x00" + value))
+	token := typ + "_" + hex.EncodeToString(mac.Sum(nil)[:8])
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if _, ok := v.values[token]; ok {
+		return token, nil
+	}
+	if err := v.append(vaultEntry{Token: token, Value: value}); err != nil {
+		return "", err
+	}
+	v.values[token] = value
+	return token, nil
+}
+
+// Detokenize returns the value of token.
+func (v *FileVault) Detokenize(_ context.Context, token string) (string, bool, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	value, ok := v.values[token]
+	return value, ok, nil
+}
+
+// append encrypts entry into a new line at the end of the vault. The caller must hold v.mu.
+func (v *FileVault) append(entry vaultEntry) error {
+	plaintext, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, v.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	line := base64.StdEncoding.AppendEncode(nil, v.aead.Seal(nonce, nonce, plaintext, nil))
+
+	f, err := os.OpenFile(v.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		// Cut off a partly written line, so that the next one starts on a line of its own.
+		_ = f.Truncate(info.Size())
+		f.Close()
+		return err
+	}
+	// The token is handed out once append returns, so the line must survive a crash.
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
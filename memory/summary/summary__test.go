@@ -0,0 +1,350 @@
+package summary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/0xDezzy/langchaingo-memory/memory/mem0"
+	"github.com/0xDezzy/langchaingo-memory/memory/mem0/mem0test"
+	"github.com/0xDezzy/langchaingo-memory/memory/memorytest"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// fakeModel records the prompts it is sent and answers with a numbered summary.
+type fakeModel struct {
+	mu      sync.Mutex
+	prompts []string
+	err     error
+}
+
+var _ llms.Model = &fakeModel{}
+
+func (m *fakeModel) GenerateContent(
+	_ context.Context, messages []llms.MessageContent, _ ...llms.CallOption,
+) (*llms.ContentResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	m.prompts = append(m.prompts, fmt.Sprint(messages[0].Parts[0]))
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{
+		{Content: fmt.Sprintf(" summary %d\n", len(m.prompts))},
+	}}, nil
+}
+
+func (m *fakeModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+func (m *fakeModel) Prompts() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.prompts...)
+}
+
+func newMem0History(t *testing.T) *mem0.ChatMessageHistory {
+	t.Helper()
+
+	server := mem0test.NewServer()
+	t.Cleanup(server.Close)
+	client, err := server.NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return mem0.NewMem0ChatMessageHistory(client, "sarah")
+}
+
+func addTurns(t *testing.T, history schema.ChatMessageHistory, from, to int) {
+	t.Helper()
+
+	for i := from; i <= to; i++ {
+		if err := history.AddUserMessage(context.Background(), fmt.Sprintf("question %d", i)); err != nil {
+			t.Fatalf("AddUserMessage: %v", err)
+		}
+		if err := history.AddAIMessage(context.Background(), fmt.Sprintf("answer %d", i)); err != nil {
+			t.Fatalf("AddAIMessage: %v", err)
+		}
+	}
+}
+
+func contents(messages []llms.ChatMessage) []string {
+	var c []string
+	for _, message := range messages {
+		c = append(c, string(message.GetType())+": "+strings.TrimSpace(message.GetContent()))
+	}
+	return c
+}
+
+func TestChatMessageHistory(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("BelowThreshold", func(t *testing.T) {
+		t.Parallel()
+		model := &fakeModel{}
+		history := NewChatMessageHistory(newMem0History(t), model, "sarah", WithMaxMessages(4))
+		addTurns(t, history, 1, 2)
+
+		messages, err := history.Messages(ctx)
+		if err != nil {
+			t.Fatalf("Messages: %v", err)
+		}
+		if len(messages) != 5 || len(model.Prompts()) != 0 {
+			t.Errorf("Expected the history unchanged without calling the model, got %v", contents(messages))
+		}
+	})
+
+	t.Run("Incremental", func(t *testing.T) {
+		t.Parallel()
+		model := &fakeModel{}
+		history := NewChatMessageHistory(newMem0History(t), model, "sarah",
+			WithMaxMessages(4), WithRecentMessages(2))
+		addTurns(t, history, 1, 3)
+
+		messages, err := history.Messages(ctx)
+		if err != nil {
+			t.Fatalf("Messages: %v", err)
+		}
+		expected := "system: summary 1|human: question 3|ai: answer 3"
+		if got := strings.Join(contents(messages[1:]), "|"); got != expected {
+			t.Errorf("Expected %q, got %q", expected, got)
+		}
+		if messages[0].GetType() != llms.ChatMessageTypeSystem || !strings.Contains(messages[0].GetContent(), "question 1") {
+			t.Errorf("Expected the mem0 memories to be kept first, got %v", contents(messages))
+		}
+		prompts := model.Prompts()
+		if len(prompts) != 1 || !strings.Contains(prompts[0], "Human: question 1\nAI: answer 1\nHuman: question 2\nAI: answer 2") ||
+			strings.Contains(prompts[0], "question 3") {
+			t.Fatalf("Expected the two older turns to be summarized, got %q", prompts)
+		}
+
+		if _, err := history.Messages(ctx); err != nil || len(model.Prompts()) != 1 {
+			t.Errorf("Expected the stored summary to be reused, got %d prompts, %v", len(model.Prompts()), err)
+		}
+
+		addTurns(t, history, 4, 5)
+		messages, err = history.Messages(ctx)
+		if err != nil {
+			t.Fatalf("Messages: %v", err)
+		}
+		expected = "system: summary 2|human: question 5|ai: answer 5"
+		if got := strings.Join(contents(messages[1:]), "|"); got != expected {
+			t.Errorf("Expected %q, got %q", expected, got)
+		}
+		prompts = model.Prompts()
+		if len(prompts) != 2 || !strings.Contains(prompts[1], "Current summary:\nsummary 1") ||
+			strings.Contains(prompts[1], "question 2") || !strings.Contains(prompts[1], "Human: question 3") ||
+			!strings.Contains(prompts[1], "AI: answer 4") {
+			t.Errorf("Expected only the new lines to be folded into the summary, got %q", prompts)
+		}
+	})
+
+	t.Run("ModelError", func(t *testing.T) {
+		t.Parallel()
+		model := &fakeModel{err: fmt.Errorf("overloaded")}
+		history := NewChatMessageHistory(newMem0History(t), model, "sarah", WithMaxMessages(2), WithRecentMessages(0))
+		addTurns(t, history, 1, 2)
+
+		if _, err := history.Messages(ctx); err == nil || !strings.Contains(err.Error(), "overloaded") {
+			t.Errorf("Expected the model error, got %v", err)
+		}
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		t.Parallel()
+		store := NewMemoryStore()
+		history := NewChatMessageHistory(newMem0History(t), &fakeModel{}, "sarah",
+			WithStore(store), WithMaxMessages(2), WithRecentMessages(0))
+		addTurns(t, history, 1, 2)
+		if _, err := history.Messages(ctx); err != nil {
+			t.Fatalf("Messages: %v", err)
+		}
+		if state, _ := store.Load(ctx, "sarah"); state.Summary == "" {
+			t.Fatalf("Expected a stored summary")
+		}
+
+		if err := history.Clear(ctx); err != nil {
+			t.Fatalf("Clear: %v", err)
+		}
+		if state, _ := store.Load(ctx, "sarah"); state != (State{}) {
+			t.Errorf("Expected Clear to delete the summary, got %+v", state)
+		}
+	})
+}
+
+func TestResolver(t *testing.T) {
+	t.Parallel()
+
+	newRemote := func(t *testing.T) schema.ChatMessageHistory {
+		server := mem0test.NewServer()
+		t.Cleanup(server.Close)
+		return mem0.NewClientChatMessageHistory(server.ClientOptions(), "", mem0.WithChatHistoryResolver(core.ResolveFromContext))
+	}
+	sarah := core.ContextWithUserID(context.Background(), "sarah")
+	john := core.ContextWithUserID(context.Background(), "john")
+
+	if _, err := NewChatMessageHistory(newRemote(t), &fakeModel{}, "").Messages(sarah); !errors.Is(err, core.ErrResolverRequired) {
+		t.Errorf("Expected ErrResolverRequired without a resolver, got %v", err)
+	}
+
+	store := NewMemoryStore()
+	h := NewChatMessageHistory(newRemote(t), &fakeModel{}, "",
+		WithResolver(core.ResolveFromContext), WithStore(store), WithMaxMessages(1), WithRecentMessages(0))
+	for _, ctx := range []context.Context{sarah, john} {
+		if err := h.AddUserMessage(ctx, "question"); err != nil {
+			t.Fatalf("AddUserMessage: %v", err)
+		}
+		if err := h.AddAIMessage(ctx, "answer"); err != nil {
+			t.Fatalf("AddAIMessage: %v", err)
+		}
+		if _, err := h.Messages(ctx); err != nil {
+			t.Fatalf("Messages: %v", err)
+		}
+	}
+	for _, user := range []string{"sarah", "john"} {
+		if state, _ := store.Load(context.Background(), user); state.Summarized != 2 {
+			t.Errorf("Expected a summary of the turn of %s, got %+v", user, state)
+		}
+	}
+
+	memorytest.RunUserIsolationSuite(t, func(t *testing.T) schema.ChatMessageHistory {
+		return NewChatMessageHistory(newRemote(t), &fakeModel{}, "", WithResolver(core.ResolveFromContext))
+	})
+}
+
+// blockingModel blocks every summary until release is closed.
+type blockingModel struct {
+	fakeModel
+	started chan struct{}
+	release chan struct{}
+}
+
+func (m *blockingModel) GenerateContent(
+	ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption,
+) (*llms.ContentResponse, error) {
+	m.started <- struct{}{}
+	<-m.release
+	return m.fakeModel.GenerateContent(ctx, messages, options...)
+}
+
+func TestSessionsSummarizeConcurrently(t *testing.T) {
+	t.Parallel()
+
+	server := mem0test.NewServer()
+	t.Cleanup(server.Close)
+	remote := mem0.NewClientChatMessageHistory(server.ClientOptions(), "", mem0.WithChatHistoryResolver(core.ResolveFromContext))
+	model := &blockingModel{started: make(chan struct{}, 1), release: make(chan struct{})}
+	h := NewChatMessageHistory(remote, model, "",
+		WithResolver(core.ResolveFromContext), WithMaxMessages(1), WithRecentMessages(0))
+	sarah := core.ContextWithUserID(context.Background(), "sarah")
+	john := core.ContextWithUserID(context.Background(), "john")
+	if err := remote.AddUserMessage(sarah, "question 1"); err != nil {
+		t.Fatalf("AddUserMessage: %v", err)
+	}
+	if err := remote.AddAIMessage(sarah, "answer 1"); err != nil {
+		t.Fatalf("AddAIMessage: %v", err)
+	}
+
+	summarized := make(chan error, 1)
+	go func() {
+		_, err := h.Messages(sarah)
+		summarized <- err
+	}()
+	<-model.started
+	loaded := make(chan error, 1)
+	go func() {
+		_, err := h.Messages(john)
+		loaded <- err
+	}()
+	select {
+	case err := <-loaded:
+		if err != nil {
+			t.Errorf("Messages: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expected john to load while sarah is summarized")
+	}
+	close(model.release)
+	if err := <-summarized; err != nil {
+		t.Errorf("Messages: %v", err)
+	}
+}
+
+func TestResync(t *testing.T) {
+	t.Parallel()
+
+	conversation := []llms.ChatMessage{
+		llms.HumanChatMessage{Content: "a"},
+		llms.AIChatMessage{Content: "b"},
+		llms.HumanChatMessage{Content: "c"},
+	}
+	state := State{Summary: "s", Summarized: 3, Last: "ai: b"}
+	if got := resync(state, conversation); got.Summarized != 2 {
+		t.Errorf("Expected the summary to be found at the last summarized message, got %+v", got)
+	}
+	if got := resync(state, conversation[2:]); got != (State{}) {
+		t.Errorf("Expected a window past the summary to be considered new without the summary, got %+v", got)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	state := State{Summary: "Sarah lives in Berlin", Summarized: 4, Last: "ai: ok"}
+	if err := NewFileStore(dir).Save(ctx, "user/1", state); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	store := NewFileStore(dir)
+	if got, err := store.Load(ctx, "user/1"); err != nil || got != state {
+		t.Errorf("Expected %+v, got %+v, %v", state, got, err)
+	}
+	if err := store.Delete(ctx, "user/1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got, err := store.Load(ctx, "user/1"); err != nil || got != (State{}) {
+		t.Errorf("Expected no summary after Delete, got %+v, %v", got, err)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	t.Parallel()
+
+	backend := mem0.NewMemory(nil, "sarah", mem0.WithMemoryKey("chat"), mem0.WithReturnMessages(false))
+	backend.ChatHistory = newMem0History(t)
+	m := NewMemory(&backend.Memory, &fakeModel{}, "sarah", WithMaxMessages(2), WithRecentMessages(2))
+	addTurns(t, m.ChatHistory, 1, 2)
+
+	result, err := m.LoadMemoryVariables(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("LoadMemoryVariables: %v", err)
+	}
+	buffer, ok := result["chat"].(string)
+	if !ok || !strings.Contains(buffer, "summary 1") || strings.Contains(buffer, "Human: question 1") ||
+		!strings.Contains(buffer, "Human: question 2") {
+		t.Errorf("Expected the summary and the recent turn, got %v", result)
+	}
+	if _, ok := backend.ChatHistory.(*mem0.ChatMessageHistory); !ok {
+		t.Errorf("Expected the wrapped memory to be left unchanged")
+	}
+}
+
+func TestConformance(t *testing.T) {
+	t.Parallel()
+
+	memorytest.RunChatMessageHistorySuite(t, func(t *testing.T) schema.ChatMessageHistory {
+		return NewChatMessageHistory(newMem0History(t), &fakeModel{}, "sarah")
+	}, memorytest.WithoutSetMessages())
+	memorytest.RunMemorySuite(t, func(t *testing.T) schema.Memory {
+		return NewMemory(core.NewMemory(newMem0History(t)), &fakeModel{}, "sarah")
+	})
+}
@@ -0,0 +1,271 @@
+// Package summary condenses the older turns of a chat message history into a running summary
+// written by an LLM. It works on top of any backend, including mem0, which has no summaries,
+// and graphiti sessions whose memory type Zep does not summarize server-side.
+package summary
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// DefaultPrompt asks the model to extend the running summary with new lines of the
+// conversation. {summary} and {new_lines} are replaced before the prompt is sent.
+const DefaultPrompt = `Progressively summarize the lines of conversation provided, adding onto the previous summary and returning a new summary. Keep every fact about the user.
+
+Current summary:
+{summary}
+
+New lines of conversation:
+{new_lines}
+
+New summary:`
+
+// ChatMessageHistory wraps a chat message history and replaces its oldest conversation
+// messages with a system message holding their summary once there are more than
+// MaxMessages of them. The summary is updated incrementally: only the messages that left the
+// recent window since the last update are sent to the model, together with the summary.
+type ChatMessageHistory struct {
+	History        schema.ChatMessageHistory
+	Model          llms.Model
+	SessionID      string
+	Store          Store
+	MaxMessages    int
+	RecentMessages int
+	Prompt         string
+	HumanPrefix    string
+	AIPrefix       string
+	// Resolver, if set, resolves the session whose summary is used from the context of every
+	// call, for a History that serves every user with a resolver of its own. The resolved
+	// session is used, or the resolved user if it resolves no session, and SessionID if it
+	// resolves neither.
+	Resolver core.Resolver
+
+	// mu guards locks, which serialize the summary updates of each session so that
+	// concurrent loads do not summarize twice, without holding up the other sessions.
+	mu    sync.Mutex
+	locks map[string]*sessionLock
+}
+
+// sessionLock is the lock of a session, deleted once no call holds or waits for it.
+type sessionLock struct {
+	sync.Mutex
+	refs int
+}
+
+// Statically assert that ChatMessageHistory implement the chat message history interface.
+var _ schema.ChatMessageHistory = &ChatMessageHistory{}
+
+// Statically assert that ChatMessageHistory can be used as a core memory backend.
+var _ core.Backend = &ChatMessageHistory{}
+
+// Statically assert that ChatMessageHistory can tell wrappers whether it resolves identities.
+var _ core.IdentityResolver = &ChatMessageHistory{}
+
+// NewChatMessageHistory wraps history, summarizing it with model. The summary is stored under
+// sessionID in the store set with WithStore, in process by default.
+func NewChatMessageHistory(
+	history schema.ChatMessageHistory, model llms.Model, sessionID string, options ...Option,
+) *ChatMessageHistory {
+	h := applyOptions(options...)
+	h.History = history
+	h.Model = model
+	h.SessionID = sessionID
+	return h
+}
+
+// ResolvesIdentity reports whether the history has a Resolver.
+func (h *ChatMessageHistory) ResolvesIdentity() bool {
+	return h.Resolver != nil
+}
+
+// sessionID returns the session whose summary is used by a call, resolved from ctx if the
+// history has a Resolver.
+func (h *ChatMessageHistory) sessionID(ctx context.Context) (string, error) {
+	if h.Resolver == nil {
+		if core.ResolvesIdentity(h.History) {
+			return "", fmt.Errorf("summary: %w", core.ErrResolverRequired)
+		}
+		return h.SessionID, nil
+	}
+	userID, sessionID, err := core.Resolve(ctx, h.Resolver, "", "")
+	if err != nil {
+		return "", fmt.Errorf("summary: resolving session: %w", err)
+	}
+	for _, id := range []string{sessionID, userID, h.SessionID} {
+		if id != "" {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("summary: %w", core.ErrNoIdentity)
+}
+
+// lock locks the summary of a session and returns the function unlocking it.
+func (h *ChatMessageHistory) lock(sessionID string) func() {
+	h.mu.Lock()
+	if h.locks == nil {
+		h.locks = make(map[string]*sessionLock)
+	}
+	l, ok := h.locks[sessionID]
+	if !ok {
+		l = &sessionLock{}
+		h.locks[sessionID] = l
+	}
+	l.refs++
+	h.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if l.refs--; l.refs == 0 {
+			delete(h.locks, sessionID)
+		}
+	}
+}
+
+// Messages returns the system messages of the wrapped history, the running summary and the
+// conversation messages that are not summarized yet. The summary is brought up to date first
+// when the history went over MaxMessages.
+func (h *ChatMessageHistory) Messages(ctx context.Context) ([]llms.ChatMessage, error) {
+	sessionID, err := h.sessionID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := h.History.Messages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var system, conversation []llms.ChatMessage
+	for _, message := range messages {
+		if message.GetType() == llms.ChatMessageTypeSystem {
+			system = append(system, message)
+			continue
+		}
+		conversation = append(conversation, message)
+	}
+
+	defer h.lock(sessionID)()
+	state, err := h.Store.Load(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	state = resync(state, conversation)
+	if end := len(conversation) - h.RecentMessages; len(conversation)-state.Summarized > h.MaxMessages &&
+		end > state.Summarized {
+		summary, err := h.summarize(ctx, state.Summary, conversation[state.Summarized:end])
+		if err != nil {
+			return nil, err
+		}
+		state = State{Summary: summary, Summarized: end, Last: messageKey(conversation[end-1])}
+		if err := h.Store.Save(ctx, sessionID, state); err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]llms.ChatMessage, 0, len(system)+1+len(conversation)-state.Summarized)
+	result = append(result, system...)
+	if state.Summary != "" {
+		result = append(result, llms.SystemChatMessage{Content: state.Summary})
+	}
+	return append(result, conversation[state.Summarized:]...), nil
+}
+
+// summarize folds messages into summary.
+func (h *ChatMessageHistory) summarize(
+	ctx context.Context, summary string, messages []llms.ChatMessage,
+) (string, error) {
+	lines, err := llms.GetBufferString(messages, h.HumanPrefix, h.AIPrefix)
+	if err != nil {
+		return "", err
+	}
+	prompt := strings.NewReplacer("{summary}", summary, "{new_lines}", lines).Replace(h.Prompt)
+	updated, err := llms.GenerateFromSinglePrompt(ctx, h.Model, prompt)
+	if err != nil {
+		return "", fmt.Errorf("summary: summarizing %d messages: %w", len(messages), err)
+	}
+	return strings.TrimSpace(updated), nil
+}
+
+// resync finds the messages of the summary in a conversation that changed since it was
+// stored. A conversation that no longer contains the last summarized message, e.g. because
+// it was cleared or the backend only returns its newest messages, is considered entirely new,
+// and the stale summary is dropped along with it.
+func resync(state State, conversation []llms.ChatMessage) State {
+	if state.Summarized == 0 {
+		return state
+	}
+	if state.Summarized <= len(conversation) && messageKey(conversation[state.Summarized-1]) == state.Last {
+		return state
+	}
+	for i := len(conversation) - 1; i >= 0; i-- {
+		if messageKey(conversation[i]) == state.Last {
+			state.Summarized = i + 1
+			return state
+		}
+	}
+	return State{}
+}
+
+func messageKey(message llms.ChatMessage) string {
+	return string(message.GetType()) + ": " + message.GetContent()
+}
+
+// AddMessage adds a message to the wrapped history.
+func (h *ChatMessageHistory) AddMessage(ctx context.Context, message llms.ChatMessage) error {
+	return h.History.AddMessage(ctx, message)
+}
+
+// AddUserMessage adds a user message to the wrapped history.
+func (h *ChatMessageHistory) AddUserMessage(ctx context.Context, text string) error {
+	return h.History.AddUserMessage(ctx, text)
+}
+
+// AddAIMessage adds an AI message to the wrapped history.
+func (h *ChatMessageHistory) AddAIMessage(ctx context.Context, text string) error {
+	return h.History.AddAIMessage(ctx, text)
+}
+
+// AddMessages adds messages to the wrapped history, in a single request if it is a
+// core.Backend.
+func (h *ChatMessageHistory) AddMessages(ctx context.Context, messages []llms.ChatMessage) error {
+	if backend, ok := h.History.(core.Backend); ok {
+		return backend.AddMessages(ctx, messages)
+	}
+	for _, message := range messages {
+		if err := h.History.AddMessage(ctx, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Clear clears the wrapped history and deletes the summary.
+func (h *ChatMessageHistory) Clear(ctx context.Context) error {
+	sessionID, err := h.sessionID(ctx)
+	if err != nil {
+		return err
+	}
+	if err := h.History.Clear(ctx); err != nil {
+		return err
+	}
+	return h.Store.Delete(ctx, sessionID)
+}
+
+// SetMessages replaces the messages of the wrapped history and deletes the summary.
+func (h *ChatMessageHistory) SetMessages(ctx context.Context, messages []llms.ChatMessage) error {
+	sessionID, err := h.sessionID(ctx)
+	if err != nil {
+		return err
+	}
+	if err := h.History.SetMessages(ctx, messages); err != nil {
+		return err
+	}
+	return h.Store.Delete(ctx, sessionID)
+}
@@ -0,0 +1,81 @@
+package summary
+
+import "github.com/0xDezzy/langchaingo-memory/memory/core"
+
+// Option is a function for creating a new summarizing chat message history
+// with other than the default values.
+type Option func(h *ChatMessageHistory)
+
+// WithStore is an option for specifying where summaries are stored. Defaults to an
+// in-process MemoryStore.
+func WithStore(store Store) Option {
+	return func(h *ChatMessageHistory) {
+		h.Store = store
+	}
+}
+
+// WithResolver is an option for resolving the session whose summary is used from the context
+// of every call, such as with core.ResolveFromContext, for a history that serves every user
+// with a resolver of its own.
+func WithResolver(resolver core.Resolver) Option {
+	return func(h *ChatMessageHistory) {
+		h.Resolver = resolver
+	}
+}
+
+// WithMaxMessages is an option for specifying how many conversation messages may be returned
+// before the older ones are summarized. Defaults to 20.
+func WithMaxMessages(maxMessages int) Option {
+	return func(h *ChatMessageHistory) {
+		h.MaxMessages = maxMessages
+	}
+}
+
+// WithRecentMessages is an option for specifying how many of the newest conversation messages
+// are kept verbatim when the history is summarized. Defaults to 10.
+func WithRecentMessages(recentMessages int) Option {
+	return func(h *ChatMessageHistory) {
+		h.RecentMessages = recentMessages
+	}
+}
+
+// WithPrompt is an option for specifying the summarization prompt. See DefaultPrompt.
+func WithPrompt(prompt string) Option {
+	return func(h *ChatMessageHistory) {
+		h.Prompt = prompt
+	}
+}
+
+// WithHumanPrefix is an option for specifying the human prefix used in the lines sent to the
+// model.
+func WithHumanPrefix(humanPrefix string) Option {
+	return func(h *ChatMessageHistory) {
+		h.HumanPrefix = humanPrefix
+	}
+}
+
+// WithAIPrefix is an option for specifying the AI prefix used in the lines sent to the model.
+func WithAIPrefix(aiPrefix string) Option {
+	return func(h *ChatMessageHistory) {
+		h.AIPrefix = aiPrefix
+	}
+}
+
+func applyOptions(options ...Option) *ChatMessageHistory {
+	h := &ChatMessageHistory{
+		MaxMessages:    20,
+		RecentMessages: 10,
+		Prompt:         DefaultPrompt,
+		HumanPrefix:    "Human",
+		AIPrefix:       "AI",
+	}
+
+	for _, option := range options {
+		option(h)
+	}
+	if h.Store == nil {
+		h.Store = NewMemoryStore()
+	}
+
+	return h
+}
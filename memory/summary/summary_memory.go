@@ -0,0 +1,30 @@
+package summary
+
+import (
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// Memory wraps the memory of a backend, such as mem0.Memory or graphiti.Memory, and returns
+// the running summary of the older turns followed by the recent window from
+// LoadMemoryVariables.
+type Memory struct {
+	core.Memory
+	History *ChatMessageHistory
+}
+
+// Statically assert that Memory implement the memory interface.
+var _ schema.Memory = &Memory{}
+
+// NewMemory wraps memory, summarizing its history with model. The settings of memory, such as
+// its keys, prefixes and token budget, are kept; memory itself is not modified. The human and
+// AI prefixes of memory are also used in the lines sent to the model unless options say
+// otherwise.
+func NewMemory(memory *core.Memory, model llms.Model, sessionID string, options ...Option) *Memory {
+	options = append([]Option{WithHumanPrefix(memory.HumanPrefix), WithAIPrefix(memory.AIPrefix)}, options...)
+	m := &Memory{Memory: *memory}
+	m.History = NewChatMessageHistory(memory.ChatHistory, model, sessionID, options...)
+	m.ChatHistory = m.History
+	return m
+}
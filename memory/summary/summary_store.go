@@ -0,0 +1,166 @@
+package summary
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// State is the running summary of a session.
+type State struct {
+	// Summary condenses the oldest conversation messages of the session.
+	Summary string `json:"summary"`
+	// Summarized is the number of conversation messages folded into Summary.
+	Summarized int `json:"summarized"`
+	// Last identifies the last message folded into Summary, so that a history that moved
+	// under the summary, e.g. because the backend only returns a window of it, is detected.
+	Last string `json:"last,omitempty"`
+}
+
+// Store persists running summaries by session. Load returns the zero State for sessions
+// without a summary.
+type Store interface {
+	Load(ctx context.Context, sessionID string) (State, error)
+	Save(ctx context.Context, sessionID string, state State) error
+	Delete(ctx context.Context, sessionID string) error
+}
+
+// MemoryStore keeps summaries in process. They are lost when the process exits.
+type MemoryStore struct {
+	mu     sync.Mutex
+	states map[string]State
+}
+
+// Statically assert that MemoryStore implement the store interface.
+var _ Store = &MemoryStore{}
+
+// NewMemoryStore returns an empty in-process store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: make(map[string]State)}
+}
+
+// Load returns the summary of the session.
+func (s *MemoryStore) Load(ctx context.Context, sessionID string) (State, error) {
+	if err := ctx.Err(); err != nil {
+		return State{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.states[sessionID], nil
+}
+
+// Save replaces the summary of the session.
+func (s *MemoryStore) Save(ctx context.Context, sessionID string, state State) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[sessionID] = state
+	return nil
+}
+
+// Delete removes the summary of the session.
+func (s *MemoryStore) Delete(ctx context.Context, sessionID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, sessionID)
+	return nil
+}
+
+// FileStore keeps each summary in a JSON file named after its session in a directory. It
+// suits backends like mem0 that have no place to store a summary next to the session.
+type FileStore struct {
+	Dir string
+	mu  sync.Mutex
+}
+
+// Statically assert that FileStore implement the store interface.
+var _ Store = &FileStore{}
+
+// NewFileStore returns a store that keeps summaries in dir. The directory is created on the
+// first save.
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{Dir: dir}
+}
+
+func (s *FileStore) path(sessionID string) string {
+	return filepath.Join(s.Dir, url.PathEscape(sessionID)+".json")
+}
+
+// Load reads the summary of the session.
+func (s *FileStore) Load(ctx context.Context, sessionID string) (State, error) {
+	if err := ctx.Err(); err != nil {
+		return State{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var state State
+	data, err := os.ReadFile(s.path(sessionID))
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("summary: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return State{}, fmt.Errorf("summary: reading %s: %w", s.path(sessionID), err)
+	}
+	return state, nil
+}
+
+// Save writes the summary of the session. The file is replaced atomically, so a crash never
+// leaves a partial summary behind.
+func (s *FileStore) Save(ctx context.Context, sessionID string, state State) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("summary: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return fmt.Errorf("summary: %w", err)
+	}
+	tmp, err := os.CreateTemp(s.Dir, ".summary-*")
+	if err != nil {
+		return fmt.Errorf("summary: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("summary: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("summary: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(sessionID)); err != nil {
+		return fmt.Errorf("summary: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the summary file of the session, if any.
+func (s *FileStore) Delete(ctx context.Context, sessionID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(s.path(sessionID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("summary: %w", err)
+	}
+	return nil
+}
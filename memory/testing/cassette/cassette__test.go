@@ -0,0 +1,161 @@
+package cassette
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/graphiti"
+	"github.com/0xDezzy/langchaingo-memory/memory/graphiti/graphitest"
+	"github.com/0xDezzy/langchaingo-memory/memory/mem0/mem0test"
+	"github.com/bytectlgo/mem0-go/client"
+	"github.com/bytectlgo/mem0-go/types"
+	zepClient "github.com/getzep/zep-go/client"
+	"github.com/getzep/zep-go/option"
+)
+
+func TestRecordAndReplayMem0(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "mem0.json")
+	fake := mem0test.NewServer(mem0test.WithAPIKey("secret-key"))
+
+	useMem0 := func(mode Mode) []types.Memory {
+		t.Helper()
+		r, err := New(path, WithMode(mode), WithRedactedFields("org_id", "project_id"))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		proxy, err := r.Server(fake.URL)
+		if err != nil {
+			t.Fatalf("Server: %v", err)
+		}
+		defer proxy.Close()
+
+		c, err := client.NewMemoryClient(client.ClientOptions{APIKey: "secret-key", Host: proxy.URL})
+		if err != nil {
+			t.Fatalf("NewMemoryClient: %v", err)
+		}
+		if _, err := c.Add("I live in Berlin", types.MemoryOptions{UserID: "sarah"}); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+		memories, err := c.GetAll(nil)
+		if err != nil {
+			t.Fatalf("GetAll: %v", err)
+		}
+		if err := r.Stop(); err != nil {
+			t.Fatalf("Stop: %v", err)
+		}
+		return memories
+	}
+
+	recorded := useMem0(ModeRecord)
+	fake.Close()
+	replayed := useMem0(ModeReplay)
+
+	if len(replayed) != 1 || replayed[0].ID != recorded[0].ID || replayed[0].Memory != "I live in Berlin" {
+		t.Errorf("Expected the recorded memories to be replayed, got %+v", replayed)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	for _, secret := range []string{"secret-key", "org-test", "project-test"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("Expected %q to be redacted from the cassette", secret)
+		}
+	}
+}
+
+func TestRecordAndReplayZep(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "zep.json")
+	fake := graphitest.NewServer()
+	ctx := context.Background()
+
+	r, err := New(path, WithMode(ModeAuto))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if !r.Recording() {
+		t.Fatalf("Expected auto mode to record a missing cassette")
+	}
+	history := graphiti.NewZepChatMessageHistory(
+		zepClient.NewClient(append(fake.ClientOptions(), option.WithHTTPClient(r.Client()))...), "s1")
+	if err := history.AddUserMessage(ctx, "hello"); err != nil {
+		t.Fatalf("AddUserMessage: %v", err)
+	}
+	if err := r.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	fake.Close()
+
+	r, err = New(path, WithMode(ModeAuto))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if r.Recording() {
+		t.Fatalf("Expected auto mode to replay an existing cassette")
+	}
+	history = graphiti.NewZepChatMessageHistory(zepClient.NewClient(
+		option.WithBaseURL(fake.URL), option.WithAPIKey("other-key"), option.WithHTTPClient(r.Client())), "s1")
+	if err := history.AddUserMessage(ctx, "hello"); err != nil {
+		t.Errorf("Expected the recorded interaction to be replayed, got %v", err)
+	}
+	if err := history.AddUserMessage(ctx, "hello"); !errors.Is(err, ErrNoInteraction) {
+		t.Errorf("Expected ErrNoInteraction once the interaction is used up, got %v", err)
+	}
+}
+
+func TestReplayMatchesNormalizedBody(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "body.json")
+	cassette := `{"interactions":[
+		{"request":{"method":"POST","url":"https://example.com/v1/memories/?api_key=REDACTED","body":"{\"b\":1,\"a\":[1,2]}"},
+		 "response":{"status_code":200,"body":"first"}},
+		{"request":{"method":"POST","url":"https://example.com/v1/memories/","body":"{\"a\":[2,1]}"},
+		 "response":{"status_code":201,"body":"second"}}
+	]}`
+	if err := os.WriteFile(path, []byte(cassette), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	r, err := New(path, WithMode(ModeReplay))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	post := func(body string) (*http.Response, error) {
+		req := httptest.NewRequest(http.MethodPost, "http://localhost/v1/memories/?api_key=live", strings.NewReader(body))
+		return r.RoundTrip(req)
+	}
+	resp, err := post("{ \"a\": [2, 1] }")
+	if err != nil || resp.StatusCode != http.StatusCreated {
+		t.Errorf("Expected the second interaction for a reordered body, got %v, %v", resp, err)
+	}
+	resp, err = post(`{"a":[1,2],"b":1}`)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the first interaction regardless of key order, got %v, %v", resp, err)
+	}
+	if _, err := post(`{"a":[1,2],"b":1}`); !errors.Is(err, ErrNoInteraction) {
+		t.Errorf("Expected ErrNoInteraction for a used interaction, got %v", err)
+	}
+}
+
+func TestNewUnknownMode(t *testing.T) {
+	t.Parallel()
+
+	if _, err := New(filepath.Join(t.TempDir(), "x.json"), WithMode("sometimes")); err == nil {
+		t.Errorf("Expected an error for an unknown mode")
+	}
+	if _, err := New(filepath.Join(t.TempDir(), "missing.json"), WithMode(ModeReplay)); err == nil {
+		t.Errorf("Expected an error replaying a missing cassette")
+	}
+}
@@ -0,0 +1,74 @@
+package cassette
+
+import (
+	"net/http"
+	"os"
+)
+
+// Option is a function for creating a new recorder
+// with other than the default values.
+type Option func(r *Recorder)
+
+// WithMode is an option for specifying the mode. It takes precedence over the CASSETTE_MODE
+// environment variable.
+func WithMode(mode Mode) Option {
+	return func(r *Recorder) {
+		r.mode = mode
+	}
+}
+
+// WithTransport is an option for specifying the transport used to reach the real service
+// while recording. Defaults to http.DefaultTransport.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(r *Recorder) {
+		r.transport = transport
+	}
+}
+
+// WithRedactedHeaders is an option for redacting more request and response headers, in
+// addition to Authorization, Api-Key and X-Api-Key.
+func WithRedactedHeaders(names ...string) Option {
+	return func(r *Recorder) {
+		r.redactedHeaders = append(r.redactedHeaders, names...)
+	}
+}
+
+// WithRedactedQuery is an option for redacting query parameters of recorded URLs.
+func WithRedactedQuery(names ...string) Option {
+	return func(r *Recorder) {
+		r.redactedQuery = append(r.redactedQuery, names...)
+	}
+}
+
+// WithRedactedFields is an option for redacting JSON fields, at any depth, in recorded
+// request and response bodies, e.g. "user_email". Request bodies are redacted the same way
+// before matching, so redacted fields never cause a mismatch.
+func WithRedactedFields(names ...string) Option {
+	return func(r *Recorder) {
+		r.redactedFields = append(r.redactedFields, names...)
+	}
+}
+
+// WithQueryMatching is an option for also matching requests on their query parameters.
+func WithQueryMatching() Option {
+	return func(r *Recorder) {
+		r.matchQuery = true
+	}
+}
+
+func applyOptions(options ...Option) *Recorder {
+	r := &Recorder{
+		mode:            ModeReplay,
+		transport:       http.DefaultTransport,
+		redactedHeaders: []string{"Authorization", "Api-Key", "X-Api-Key"},
+	}
+	if mode := Mode(os.Getenv(ModeEnv)); mode != "" {
+		r.mode = mode
+	}
+
+	for _, option := range options {
+		option(r)
+	}
+
+	return r
+}
@@ -0,0 +1,371 @@
+// Package cassette records HTTP interactions with the mem0 and Zep APIs to cassette files and
+// replays them deterministically, so tests get realistic payloads without credentials or
+// network access. Re-recording a cassette against the live service shows API drift as a diff.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Mode selects whether a Recorder talks to the real service.
+type Mode string
+
+const (
+	// ModeReplay serves every request from the cassette and fails requests it has no
+	// interaction for.
+	ModeReplay Mode = "replay"
+	// ModeRecord sends every request to the real service and overwrites the cassette on Stop.
+	ModeRecord Mode = "record"
+	// ModeAuto replays the cassette if it exists, and records it otherwise.
+	ModeAuto Mode = "auto"
+)
+
+// ModeEnv is the environment variable that overrides the default mode, e.g.
+// CASSETTE_MODE=record go test ./... to re-record every cassette.
+const ModeEnv = "CASSETTE_MODE"
+
+// ErrNoInteraction is returned in replay mode for requests that match no unused interaction.
+var ErrNoInteraction = errors.New("cassette: no recorded interaction matches the request")
+
+// Redacted replaces secrets in recorded cassettes.
+const Redacted = "REDACTED"
+
+// Request is a recorded HTTP request.
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// Response is a recorded HTTP response.
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Interaction is a recorded request and the response the service gave to it.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Cassette is the content of a cassette file.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Recorder is an http.RoundTripper that records interactions to a cassette file or replays
+// them from it. It is safe for concurrent use.
+type Recorder struct {
+	path            string
+	mode            Mode
+	transport       http.RoundTripper
+	redactedHeaders []string
+	redactedQuery   []string
+	redactedFields  []string
+	matchQuery      bool
+	recording       bool
+	mu              sync.Mutex
+	cassette        Cassette
+	used            []bool
+	stopped         bool
+	stopErr         error
+}
+
+// New returns a Recorder for the cassette file at path.
+func New(path string, options ...Option) (*Recorder, error) {
+	r := applyOptions(options...)
+	r.path = path
+	if r.mode != ModeReplay && r.mode != ModeRecord && r.mode != ModeAuto {
+		return nil, fmt.Errorf("cassette: unknown mode %q", r.mode)
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case r.mode == ModeRecord:
+		r.recording = true
+	case err == nil:
+		if err := json.Unmarshal(data, &r.cassette); err != nil {
+			return nil, fmt.Errorf("cassette: reading %s: %w", path, err)
+		}
+	case errors.Is(err, os.ErrNotExist) && r.mode == ModeAuto:
+		r.recording = true
+	default:
+		return nil, fmt.Errorf("cassette: %w", err)
+	}
+	r.used = make([]bool, len(r.cassette.Interactions))
+	return r, nil
+}
+
+// Use returns an HTTP client backed by the cassette testdata/cassettes/<name>.json. The
+// cassette is saved when the test finishes.
+func Use(t *testing.T, name string, options ...Option) *http.Client {
+	t.Helper()
+
+	r, err := New(filepath.Join("testdata", "cassettes", name+".json"), options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := r.Stop(); err != nil {
+			t.Error(err)
+		}
+	})
+	return r.Client()
+}
+
+// Recording reports whether the recorder sends requests to the real service.
+func (r *Recorder) Recording() bool {
+	return r.recording
+}
+
+// Client returns an HTTP client that uses the recorder as its transport. Pass it to
+// zepClient.NewClient with option.WithHTTPClient.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Server starts a local reverse proxy to upstream that goes through the recorder. Clients
+// that do not accept an HTTP client, like the mem0 client, can use its URL as their host.
+// The caller must close the server.
+func (r *Recorder) Server(upstream string) (*httptest.Server, error) {
+	target, err := url.Parse(upstream)
+	if err != nil {
+		return nil, fmt.Errorf("cassette: %w", err)
+	}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = r
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		director(req)
+		req.Host = target.Host
+	}
+	return httptest.NewServer(proxy), nil
+}
+
+// Stop saves the cassette if the recorder was recording. It is safe to call more than once.
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped {
+		return r.stopErr
+	}
+	r.stopped = true
+	if !r.recording {
+		return nil
+	}
+	r.stopErr = r.save()
+	return r.stopErr
+}
+
+func (r *Recorder) save() error {
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("cassette: %w", err)
+	}
+	if err := os.WriteFile(r.path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("cassette: %w", err)
+	}
+	return nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	if r.recording {
+		return r.record(req, body)
+	}
+	return r.replay(req, body)
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	interaction := Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    r.redactURL(req.URL),
+			Header: r.redactHeader(req.Header),
+			Body:   r.redactBody(body),
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     r.redactHeader(resp.Header),
+			Body:       r.redactBody(respBody),
+		},
+	}
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.used = append(r.used, true)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	incoming, err := url.Parse(r.redactURL(req.URL))
+	if err != nil {
+		return nil, fmt.Errorf("cassette: %w", err)
+	}
+	key := r.key(req.Method, incoming, r.redactBody(body))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, interaction := range r.cassette.Interactions {
+		if r.used[i] {
+			continue
+		}
+		recorded, err := url.Parse(interaction.Request.URL)
+		if err != nil {
+			continue
+		}
+		if r.key(interaction.Request.Method, recorded, interaction.Request.Body) != key {
+			continue
+		}
+		r.used[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+			StatusCode:    interaction.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        interaction.Response.Header.Clone(),
+			Body:          io.NopCloser(strings.NewReader(interaction.Response.Body)),
+			ContentLength: int64(len(interaction.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, req.Method, req.URL.Path)
+}
+
+// key returns what requests are matched on: the method, the path and the normalized body.
+func (r *Recorder) key(method string, u *url.URL, body string) string {
+	path := u.Path
+	if r.matchQuery {
+		path += "?" + u.Query().Encode()
+	}
+	return method + " " + path + "\n" + normalizeBody(body)
+}
+
+// normalizeBody re-encodes JSON bodies so that key order and whitespace do not matter.
+func normalizeBody(body string) string {
+	var value any
+	if err := json.Unmarshal([]byte(body), &value); err != nil {
+		return strings.TrimSpace(body)
+	}
+	normalized, err := json.Marshal(value)
+	if err != nil {
+		return strings.TrimSpace(body)
+	}
+	return string(normalized)
+}
+
+// readBody reads a request or response body and replaces it with an in-memory copy.
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	if err != nil {
+		return nil, err
+	}
+	if err := (*body).Close(); err != nil {
+		return nil, err
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+func (r *Recorder) redactHeader(header http.Header) http.Header {
+	redacted := header.Clone()
+	for _, name := range r.redactedHeaders {
+		if redacted.Get(name) != "" {
+			redacted.Set(name, Redacted)
+		}
+	}
+	return redacted
+}
+
+func (r *Recorder) redactURL(u *url.URL) string {
+	redacted := *u
+	query := redacted.Query()
+	for _, name := range r.redactedQuery {
+		if query.Has(name) {
+			query.Set(name, Redacted)
+		}
+	}
+	redacted.RawQuery = query.Encode()
+	redacted.User = nil
+	return redacted.String()
+}
+
+// redactBody replaces the values of the redacted fields anywhere in a JSON body. Other
+// bodies are kept as they are.
+func (r *Recorder) redactBody(body []byte) string {
+	if len(r.redactedFields) == 0 || len(body) == 0 {
+		return string(body)
+	}
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return string(body)
+	}
+	redacted, err := json.Marshal(r.redactValue(value))
+	if err != nil {
+		return string(body)
+	}
+	return string(redacted)
+}
+
+func (r *Recorder) redactValue(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, field := range value {
+			if contains(r.redactedFields, key) {
+				value[key] = Redacted
+				continue
+			}
+			value[key] = r.redactValue(field)
+		}
+	case []any:
+		for i, item := range value {
+			value[i] = r.redactValue(item)
+		}
+	}
+	return value
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package tiered
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/0xDezzy/langchaingo-memory/memory/mem0"
+	"github.com/0xDezzy/langchaingo-memory/memory/mem0/mem0test"
+	"github.com/0xDezzy/langchaingo-memory/memory/memorytest"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// countingRemote counts how often the remote transcript is fetched.
+type countingRemote struct {
+	*mem0.ChatMessageHistory
+	transcripts atomic.Int32
+}
+
+func (r *countingRemote) Messages(ctx context.Context) ([]llms.ChatMessage, error) {
+	r.transcripts.Add(1)
+	return r.ChatMessageHistory.Messages(ctx)
+}
+
+func newRemote(t *testing.T) *countingRemote {
+	t.Helper()
+
+	server := mem0test.NewServer()
+	t.Cleanup(server.Close)
+	client, err := server.NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return &countingRemote{ChatMessageHistory: mem0.NewMem0ChatMessageHistory(client, "sarah")}
+}
+
+func saveTurns(t *testing.T, m schema.Memory, from, to int) {
+	t.Helper()
+
+	for i := from; i <= to; i++ {
+		err := m.SaveContext(context.Background(),
+			map[string]any{"input": fmt.Sprintf("question %d", i)},
+			map[string]any{"output": fmt.Sprintf("answer %d", i)})
+		if err != nil {
+			t.Fatalf("SaveContext: %v", err)
+		}
+	}
+}
+
+func load(t *testing.T, m schema.Memory) []llms.ChatMessage {
+	t.Helper()
+
+	result, err := m.LoadMemoryVariables(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("LoadMemoryVariables: %v", err)
+	}
+	return result["history"].([]llms.ChatMessage)
+}
+
+func contents(messages []llms.ChatMessage) string {
+	var c []string
+	for _, message := range messages {
+		c = append(c, string(message.GetType())+": "+strings.TrimSpace(message.GetContent()))
+	}
+	return strings.Join(c, "|")
+}
+
+func TestMemory(t *testing.T) {
+	t.Parallel()
+
+	t.Run("RecentTurnsAndFacts", func(t *testing.T) {
+		t.Parallel()
+		remote := newRemote(t)
+		m := NewMemory(remote, WithMaxTurns(2))
+		saveTurns(t, m, 1, 3)
+
+		expected := "system: question 1|human: question 2|ai: answer 2|human: question 3|ai: answer 3"
+		if got := contents(load(t, m)); got != expected {
+			t.Errorf("Expected %q, got %q", expected, got)
+		}
+		if n := remote.transcripts.Load(); n != 0 {
+			t.Errorf("Expected only facts to be fetched from the remote backend, got %d transcripts", n)
+		}
+	})
+
+	t.Run("FillsEmptyBuffer", func(t *testing.T) {
+		t.Parallel()
+		remote := newRemote(t)
+		saveTurns(t, NewMemory(remote), 1, 3)
+
+		m := NewMemory(remote, WithMaxTurns(1))
+		expected := "system: question 1\nquestion 2|human: question 3|ai: answer 3"
+		for i := 0; i < 2; i++ {
+			if got := contents(load(t, m)); got != expected {
+				t.Errorf("Expected %q, got %q", expected, got)
+			}
+		}
+		if n := remote.transcripts.Load(); n != 1 {
+			t.Errorf("Expected the transcript to be fetched once, got %d", n)
+		}
+	})
+
+	t.Run("FileBuffer", func(t *testing.T) {
+		t.Parallel()
+		remote := newRemote(t)
+		path := filepath.Join(t.TempDir(), "sarah.json")
+		saveTurns(t, NewMemory(remote, WithLocal(NewFileChatMessageHistory(path))), 1, 2)
+
+		m := NewMemory(remote, WithLocal(NewFileChatMessageHistory(path)))
+		if got := contents(load(t, m)); !strings.HasSuffix(got, "human: question 2|ai: answer 2") {
+			t.Errorf("Expected the buffered turns to survive a restart, got %q", got)
+		}
+		if n := remote.transcripts.Load(); n != 0 {
+			t.Errorf("Expected no transcript fetch with a persisted buffer, got %d", n)
+		}
+	})
+}
+
+func TestDedupe(t *testing.T) {
+	t.Parallel()
+
+	facts := []string{"Sarah lives in Berlin", "sarah  lives in berlin", "I like tea", ""}
+	local := []llms.ChatMessage{llms.HumanChatMessage{Content: "I like  tea"}}
+	if got := dedupe(facts, local); len(got) != 1 || got[0] != "Sarah lives in Berlin" {
+		t.Errorf("Expected one fact left, got %q", got)
+	}
+}
+
+func TestFileChatMessageHistory(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "nested", "history.json")
+	expected := []llms.ChatMessage{
+		llms.HumanChatMessage{Content: "hi"},
+		llms.AIChatMessage{Content: "hello"},
+		llms.ToolChatMessage{ID: "call-1", Content: "{}"},
+		llms.FunctionChatMessage{Name: "lookup", Content: "sunny"},
+		llms.GenericChatMessage{Role: "critic", Content: "ok"},
+	}
+	if err := NewFileChatMessageHistory(path).SetMessages(ctx, expected); err != nil {
+		t.Fatalf("SetMessages: %v", err)
+	}
+	messages, err := NewFileChatMessageHistory(path).Messages(ctx)
+	if err != nil {
+		t.Fatalf("Messages: %v", err)
+	}
+	if fmt.Sprint(messages) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, messages)
+	}
+}
+
+func TestResolver(t *testing.T) {
+	t.Parallel()
+
+	server := mem0test.NewServer()
+	t.Cleanup(server.Close)
+	remote := mem0.NewClientChatMessageHistory(server.ClientOptions(), "", mem0.WithChatHistoryResolver(core.ResolveFromContext))
+	h := NewChatMessageHistory(remote)
+	for _, userID := range []string{"sarah", "john"} {
+		ctx := core.ContextWithUserID(context.Background(), userID)
+		if err := h.AddUserMessage(ctx, "I am "+userID); !errors.Is(err, core.ErrResolverUnsupported) {
+			t.Errorf("Expected ErrResolverUnsupported for %s, got %v", userID, err)
+		}
+		if _, err := h.Messages(ctx); !errors.Is(err, core.ErrResolverUnsupported) {
+			t.Errorf("Expected ErrResolverUnsupported for %s, got %v", userID, err)
+		}
+	}
+	if local, _ := h.Local.Messages(context.Background()); len(local) != 0 {
+		t.Errorf("Expected an empty local buffer, got %v", local)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	t.Parallel()
+
+	memorytest.RunChatMessageHistorySuite(t, func(t *testing.T) schema.ChatMessageHistory {
+		return NewChatMessageHistory(newRemote(t))
+	}, memorytest.WithoutSetMessages())
+	memorytest.RunChatMessageHistorySuite(t, func(t *testing.T) schema.ChatMessageHistory {
+		local := NewFileChatMessageHistory(filepath.Join(t.TempDir(), "history.json"))
+		return NewChatMessageHistory(newRemote(t), WithChatHistoryLocal(local))
+	}, memorytest.WithoutSetMessages())
+	memorytest.RunMemorySuite(t, func(t *testing.T) schema.Memory {
+		return NewMemory(newRemote(t))
+	})
+}
@@ -0,0 +1,283 @@
+// Package tiered combines a fast local buffer of the recent turns with a remote long-term
+// backend such as mem0 or Zep. Turns are written to both, but loads only ask the remote
+// backend for its facts and take the transcript from the local buffer.
+package tiered
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// ChatMessageHistory keeps the last MaxTurns turns in Local and every message in Remote.
+// Messages returns the remote facts, de-duplicated, followed by the local turns. Local holds
+// the turns of a single identity, so a Remote that resolves the identity of every call from
+// its context is rejected with core.ErrResolverUnsupported; use one history per identity.
+type ChatMessageHistory struct {
+	Local    schema.ChatMessageHistory
+	Remote   schema.ChatMessageHistory
+	MaxTurns int
+
+	// mu serializes local writes, which read the buffer back to trim it.
+	mu sync.Mutex
+	// warm is set once the local buffer was filled from the remote transcript or written to.
+	warm bool
+}
+
+// Statically assert that ChatMessageHistory implement the chat message history interface.
+var _ schema.ChatMessageHistory = &ChatMessageHistory{}
+
+// Statically assert that ChatMessageHistory can be used as a core memory backend.
+var _ core.Backend = &ChatMessageHistory{}
+
+// NewChatMessageHistory creates a tiered history on top of remote. The local buffer is in
+// process unless WithChatHistoryLocal says otherwise.
+func NewChatMessageHistory(remote schema.ChatMessageHistory, options ...ChatMessageHistoryOption) *ChatMessageHistory {
+	h := applyChatHistoryOptions(options...)
+	h.Remote = remote
+	return h
+}
+
+// checkRemote fails if Remote resolves the identity of every call, whose turns would all end up
+// in the same local buffer.
+func (h *ChatMessageHistory) checkRemote() error {
+	if core.ResolvesIdentity(h.Remote) {
+		return fmt.Errorf("tiered: %w", core.ErrResolverUnsupported)
+	}
+	return nil
+}
+
+// Messages returns a system message with the remote facts that are not already in the local
+// turns, followed by the local turns. The remote transcript is only fetched when the local
+// buffer is still empty, e.g. after a restart with an in-process buffer, to fill it; after
+// that, only facts are requested from remotes that are a core.FactSource.
+func (h *ChatMessageHistory) Messages(ctx context.Context) ([]llms.ChatMessage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := h.checkRemote(); err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	local, err := h.Local.Messages(ctx)
+	if err != nil {
+		h.mu.Unlock()
+		return nil, err
+	}
+	var facts []string
+	if len(local) == 0 && !h.warm {
+		facts, local, err = h.fill(ctx)
+		h.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		h.mu.Unlock()
+		facts, err = h.facts(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	facts = dedupe(facts, local)
+	messages := make([]llms.ChatMessage, 0, len(local)+1)
+	if len(facts) > 0 {
+		messages = append(messages, llms.SystemChatMessage{Content: strings.Join(facts, "\n") + "\n"})
+	}
+	return append(messages, local...), nil
+}
+
+// fill loads the remote history once and keeps its last turns in the local buffer. The
+// caller must hold h.mu.
+func (h *ChatMessageHistory) fill(ctx context.Context) ([]string, []llms.ChatMessage, error) {
+	remote, err := h.Remote.Messages(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	facts, conversation := split(remote)
+	conversation = lastTurns(conversation, h.MaxTurns)
+	if len(conversation) > 0 {
+		if err := h.Local.SetMessages(ctx, conversation); err != nil {
+			return nil, nil, err
+		}
+	}
+	h.warm = true
+	return facts, conversation, nil
+}
+
+// facts returns the long-term knowledge of the remote backend, from its system messages if it
+// is not a core.FactSource. Only remotes whose facts are cheaper to read than their transcript
+// benefit: the facts of mem0 cost the same full read as its messages.
+func (h *ChatMessageHistory) facts(ctx context.Context) ([]string, error) {
+	if source, ok := h.Remote.(core.FactSource); ok {
+		return source.Facts(ctx)
+	}
+	messages, err := h.Remote.Messages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	facts, _ := split(messages)
+	return facts, nil
+}
+
+// split separates the lines of the system messages from the conversation messages.
+func split(messages []llms.ChatMessage) ([]string, []llms.ChatMessage) {
+	var facts []string
+	var conversation []llms.ChatMessage
+	for _, message := range messages {
+		if message.GetType() != llms.ChatMessageTypeSystem {
+			conversation = append(conversation, message)
+			continue
+		}
+		for _, line := range strings.Split(message.GetContent(), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				facts = append(facts, line)
+			}
+		}
+	}
+	return facts, conversation
+}
+
+// dedupe drops facts that repeat an earlier fact or the content of a local message, ignoring
+// case and whitespace.
+func dedupe(facts []string, local []llms.ChatMessage) []string {
+	seen := make(map[string]bool, len(facts)+len(local))
+	for _, message := range local {
+		seen[normalize(message.GetContent())] = true
+	}
+	var unique []string
+	for _, fact := range facts {
+		key := normalize(fact)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, fact)
+	}
+	return unique
+}
+
+func normalize(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
+
+// lastTurns returns the messages from the start of the last maxTurns turns, a turn starting
+// with a human message. Zero or less keeps every message.
+func lastTurns(messages []llms.ChatMessage, maxTurns int) []llms.ChatMessage {
+	if maxTurns <= 0 {
+		return messages
+	}
+	turns := 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].GetType() != llms.ChatMessageTypeHuman {
+			continue
+		}
+		if turns++; turns == maxTurns {
+			return messages[i:]
+		}
+	}
+	return messages
+}
+
+// AddMessage adds a message to the remote backend and the local buffer.
+func (h *ChatMessageHistory) AddMessage(ctx context.Context, message llms.ChatMessage) error {
+	return h.AddMessages(ctx, []llms.ChatMessage{message})
+}
+
+// AddUserMessage adds a user message to the remote backend and the local buffer.
+func (h *ChatMessageHistory) AddUserMessage(ctx context.Context, text string) error {
+	return h.AddMessage(ctx, llms.HumanChatMessage{Content: text})
+}
+
+// AddAIMessage adds an AI message to the remote backend and the local buffer.
+func (h *ChatMessageHistory) AddAIMessage(ctx context.Context, text string) error {
+	return h.AddMessage(ctx, llms.AIChatMessage{Content: text})
+}
+
+// AddMessages adds messages to the remote backend first, in a single request if it is a
+// core.Backend, and then to the local buffer, so that the buffer never holds a message the
+// remote backend does not have. The buffer is trimmed to the last MaxTurns turns.
+func (h *ChatMessageHistory) AddMessages(ctx context.Context, messages []llms.ChatMessage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := h.checkRemote(); err != nil {
+		return err
+	}
+	if err := addMessages(ctx, h.Remote, messages); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.warm = true
+	if err := addMessages(ctx, h.Local, messages); err != nil {
+		return err
+	}
+	local, err := h.Local.Messages(ctx)
+	if err != nil {
+		return err
+	}
+	if trimmed := lastTurns(local, h.MaxTurns); len(trimmed) < len(local) {
+		return h.Local.SetMessages(ctx, trimmed)
+	}
+	return nil
+}
+
+func addMessages(ctx context.Context, history schema.ChatMessageHistory, messages []llms.ChatMessage) error {
+	if backend, ok := history.(core.Backend); ok {
+		return backend.AddMessages(ctx, messages)
+	}
+	for _, message := range messages {
+		if err := history.AddMessage(ctx, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Clear clears the remote backend and the local buffer.
+func (h *ChatMessageHistory) Clear(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := h.checkRemote(); err != nil {
+		return err
+	}
+	if err := h.Remote.Clear(ctx); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.warm = true
+	return h.Local.Clear(ctx)
+}
+
+// SetMessages passes the messages to SetMessages of the remote backend, and keeps their last
+// turns in the local buffer. Whether the remote messages are replaced depends on the backend:
+// mem0 and graphiti cannot replace their memories and ignore the call, so the local buffer
+// then differs from the remote transcript until the next cold load.
+func (h *ChatMessageHistory) SetMessages(ctx context.Context, messages []llms.ChatMessage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := h.checkRemote(); err != nil {
+		return err
+	}
+	if err := h.Remote.SetMessages(ctx, messages); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.warm = true
+	_, conversation := split(messages)
+	return h.Local.SetMessages(ctx, lastTurns(conversation, h.MaxTurns))
+}
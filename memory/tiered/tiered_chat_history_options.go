@@ -0,0 +1,42 @@
+package tiered
+
+import (
+	"github.com/tmc/langchaingo/memory"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// ChatMessageHistoryOption is a function for creating a new tiered chat message history
+// with other than the default values.
+type ChatMessageHistoryOption func(h *ChatMessageHistory)
+
+// WithChatHistoryLocal is an option for specifying the local buffer, such as a
+// FileChatMessageHistory to keep the recent turns across restarts. Defaults to an in-process
+// buffer.
+func WithChatHistoryLocal(local schema.ChatMessageHistory) ChatMessageHistoryOption {
+	return func(h *ChatMessageHistory) {
+		h.Local = local
+	}
+}
+
+// WithChatHistoryMaxTurns is an option for specifying how many turns the local buffer keeps.
+// Defaults to 10.
+func WithChatHistoryMaxTurns(maxTurns int) ChatMessageHistoryOption {
+	return func(h *ChatMessageHistory) {
+		h.MaxTurns = maxTurns
+	}
+}
+
+func applyChatHistoryOptions(options ...ChatMessageHistoryOption) *ChatMessageHistory {
+	h := &ChatMessageHistory{
+		MaxTurns: 10,
+	}
+
+	for _, option := range options {
+		option(h)
+	}
+	if h.Local == nil {
+		h.Local = memory.NewChatMessageHistory()
+	}
+
+	return h
+}
@@ -0,0 +1,187 @@
+package tiered
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// FileChatMessageHistory is an on-disk local buffer that keeps the messages in a JSON file, so
+// the recent turns survive restarts without a remote call.
+type FileChatMessageHistory struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// Statically assert that FileChatMessageHistory implement the chat message history interface.
+var _ schema.ChatMessageHistory = &FileChatMessageHistory{}
+
+// Statically assert that FileChatMessageHistory writes a turn in a single write.
+var _ core.Backend = &FileChatMessageHistory{}
+
+// NewFileChatMessageHistory returns a history stored in the file at path. The file and its
+// directory are created on the first write.
+func NewFileChatMessageHistory(path string) *FileChatMessageHistory {
+	return &FileChatMessageHistory{Path: path}
+}
+
+// fileMessage is a message as stored in the file.
+type fileMessage struct {
+	Type    llms.ChatMessageType `json:"type"`
+	Content string               `json:"content"`
+	Name    string               `json:"name,omitempty"`
+	ID      string               `json:"id,omitempty"`
+}
+
+func toFileMessage(message llms.ChatMessage) fileMessage {
+	stored := fileMessage{Type: message.GetType(), Content: message.GetContent()}
+	switch m := message.(type) {
+	case llms.FunctionChatMessage:
+		stored.Name = m.Name
+	case llms.ToolChatMessage:
+		stored.ID = m.ID
+	case llms.GenericChatMessage:
+		stored.Name = m.Role
+	}
+	return stored
+}
+
+func (m fileMessage) chatMessage() llms.ChatMessage {
+	switch m.Type {
+	case llms.ChatMessageTypeHuman:
+		return llms.HumanChatMessage{Content: m.Content}
+	case llms.ChatMessageTypeAI:
+		return llms.AIChatMessage{Content: m.Content}
+	case llms.ChatMessageTypeSystem:
+		return llms.SystemChatMessage{Content: m.Content}
+	case llms.ChatMessageTypeFunction:
+		return llms.FunctionChatMessage{Name: m.Name, Content: m.Content}
+	case llms.ChatMessageTypeTool:
+		return llms.ToolChatMessage{ID: m.ID, Content: m.Content}
+	default:
+		return llms.GenericChatMessage{Role: m.Name, Content: m.Content}
+	}
+}
+
+// read returns the stored messages. The caller must hold h.mu.
+func (h *FileChatMessageHistory) read() ([]llms.ChatMessage, error) {
+	data, err := os.ReadFile(h.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tiered: %w", err)
+	}
+	var stored []fileMessage
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("tiered: reading %s: %w", h.Path, err)
+	}
+	messages := make([]llms.ChatMessage, 0, len(stored))
+	for _, message := range stored {
+		messages = append(messages, message.chatMessage())
+	}
+	return messages, nil
+}
+
+// write replaces the stored messages atomically. The caller must hold h.mu.
+func (h *FileChatMessageHistory) write(messages []llms.ChatMessage) error {
+	stored := make([]fileMessage, 0, len(messages))
+	for _, message := range messages {
+		stored = append(stored, toFileMessage(message))
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("tiered: %w", err)
+	}
+
+	dir := filepath.Dir(h.Path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("tiered: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".history-*")
+	if err != nil {
+		return fmt.Errorf("tiered: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("tiered: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("tiered: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), h.Path); err != nil {
+		return fmt.Errorf("tiered: %w", err)
+	}
+	return nil
+}
+
+// Messages returns all messages stored.
+func (h *FileChatMessageHistory) Messages(ctx context.Context) ([]llms.ChatMessage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.read()
+}
+
+// AddMessage adds a message to the file.
+func (h *FileChatMessageHistory) AddMessage(ctx context.Context, message llms.ChatMessage) error {
+	return h.AddMessages(ctx, []llms.ChatMessage{message})
+}
+
+// AddUserMessage adds a user message to the file.
+func (h *FileChatMessageHistory) AddUserMessage(ctx context.Context, text string) error {
+	return h.AddMessage(ctx, llms.HumanChatMessage{Content: text})
+}
+
+// AddAIMessage adds an AI message to the file.
+func (h *FileChatMessageHistory) AddAIMessage(ctx context.Context, text string) error {
+	return h.AddMessage(ctx, llms.AIChatMessage{Content: text})
+}
+
+// AddMessages adds messages to the file in a single write.
+func (h *FileChatMessageHistory) AddMessages(ctx context.Context, messages []llms.ChatMessage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	stored, err := h.read()
+	if err != nil {
+		return err
+	}
+	return h.write(append(stored, messages...))
+}
+
+// Clear removes the file.
+func (h *FileChatMessageHistory) Clear(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err := os.Remove(h.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("tiered: %w", err)
+	}
+	return nil
+}
+
+// SetMessages replaces the messages in the file.
+func (h *FileChatMessageHistory) SetMessages(ctx context.Context, messages []llms.ChatMessage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.write(messages)
+}
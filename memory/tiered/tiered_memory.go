@@ -0,0 +1,25 @@
+package tiered
+
+import (
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// Memory remembers the recent turns in a local buffer and the long-term knowledge in a remote
+// backend. The schema.Memory implementation comes from the embedded core.Memory.
+type Memory struct {
+	core.Memory
+	History *ChatMessageHistory
+}
+
+// Statically assert that Memory implement the memory interface.
+var _ schema.Memory = &Memory{}
+
+// NewMemory creates a tiered memory on top of remote, such as a mem0 or graphiti chat message
+// history.
+func NewMemory(remote schema.ChatMessageHistory, options ...MemoryOption) *Memory {
+	m := applyTieredMemoryOptions(options...)
+	m.History.Remote = remote
+	m.ChatHistory = m.History
+	return m
+}
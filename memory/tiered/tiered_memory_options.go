@@ -0,0 +1,47 @@
+package tiered
+
+import (
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// MemoryOption is a function for creating a new tiered memory
+// with other than the default values.
+type MemoryOption func(m *Memory)
+
+// WithLocal is an option for specifying the local buffer of the recent turns.
+func WithLocal(local schema.ChatMessageHistory) MemoryOption {
+	return func(m *Memory) {
+		WithChatHistoryLocal(local)(m.History)
+	}
+}
+
+// WithMaxTurns is an option for specifying how many turns the local buffer keeps.
+func WithMaxTurns(maxTurns int) MemoryOption {
+	return func(m *Memory) {
+		WithChatHistoryMaxTurns(maxTurns)(m.History)
+	}
+}
+
+// WithMemoryOptions is an option for applying core.Memory options, such as the memory key or
+// a token budget.
+func WithMemoryOptions(options ...core.Option) MemoryOption {
+	return func(m *Memory) {
+		for _, option := range options {
+			option(&m.Memory)
+		}
+	}
+}
+
+func applyTieredMemoryOptions(options ...MemoryOption) *Memory {
+	m := &Memory{
+		Memory:  *core.ApplyOptions(),
+		History: applyChatHistoryOptions(),
+	}
+
+	for _, option := range options {
+		option(m)
+	}
+
+	return m
+}
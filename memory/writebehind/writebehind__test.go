@@ -0,0 +1,342 @@
+package writebehind
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/0xDezzy/langchaingo-memory/memory/mem0"
+	"github.com/0xDezzy/langchaingo-memory/memory/mem0/mem0test"
+	"github.com/0xDezzy/langchaingo-memory/memory/memorytest"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+const addPath = "/v1/memories/"
+
+func newServer(t *testing.T, options ...mem0test.ServerOption) (*mem0test.Server, BackendFunc) {
+	t.Helper()
+
+	server := mem0test.NewServer(options...)
+	t.Cleanup(server.Close)
+	client, err := server.NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return server, func(sessionID string) core.Backend {
+		return mem0.NewMem0ChatMessageHistory(client, sessionID)
+	}
+}
+
+func newQueue(t *testing.T, path string, backend BackendFunc, options ...QueueOption) *Queue {
+	t.Helper()
+
+	options = append([]QueueOption{WithBackoff(time.Millisecond, time.Millisecond)}, options...)
+	q, err := NewQueue(path, backend, options...)
+	if err != nil {
+		t.Fatalf("NewQueue: %v", err)
+	}
+	t.Cleanup(func() { q.Close() })
+	return q
+}
+
+func saveTurn(t *testing.T, m schema.Memory, i int) {
+	t.Helper()
+
+	err := m.SaveContext(context.Background(),
+		map[string]any{"input": fmt.Sprintf("question %d", i)},
+		map[string]any{"output": fmt.Sprintf("answer %d", i)})
+	if err != nil {
+		t.Fatalf("SaveContext: %v", err)
+	}
+}
+
+func stored(server *mem0test.Server, userID string) string {
+	var c []string
+	for _, memory := range server.Memories(userID) {
+		for _, message := range memory.Messages {
+			c = append(c, message.Content)
+		}
+	}
+	return strings.Join(c, "|")
+}
+
+func TestQueue(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("AcknowledgesBeforeFlush", func(t *testing.T) {
+		t.Parallel()
+		server, backend := newServer(t, mem0test.WithFault(memorytest.Fault{
+			Method: http.MethodPost, Path: addPath, Latency: 300 * time.Millisecond,
+		}))
+		q := newQueue(t, filepath.Join(t.TempDir(), "wal"), backend)
+		m := NewMemory(q, "sarah")
+
+		start := time.Now()
+		saveTurn(t, m, 1)
+		if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+			t.Errorf("Expected SaveContext not to wait for mem0, took %v", elapsed)
+		}
+		messages, err := m.ChatHistory.Messages(ctx)
+		if err != nil {
+			t.Fatalf("Messages: %v", err)
+		}
+		if len(messages) != 2 || messages[1].GetContent() != "answer 1" {
+			t.Errorf("Expected the queued turn to be read back, got %v", messages)
+		}
+
+		if err := q.Flush(ctx); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+		if got := stored(server, "sarah"); got != "question 1|answer 1" {
+			t.Errorf("Expected the turn in mem0 after Flush, got %q", got)
+		}
+		if messages, _ := m.ChatHistory.Messages(ctx); len(messages) != 3 {
+			t.Errorf("Expected the flushed turn once after the mem0 memory, got %v", messages)
+		}
+	})
+
+	t.Run("OrderPerSession", func(t *testing.T) {
+		t.Parallel()
+		server, backend := newServer(t)
+		q := newQueue(t, filepath.Join(t.TempDir(), "wal"), backend)
+		sarah, bob := NewMemory(q, "sarah"), NewMemory(q, "bob")
+		for i := 1; i <= 5; i++ {
+			saveTurn(t, sarah, i)
+			saveTurn(t, bob, i)
+		}
+		if err := q.Flush(ctx); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+		var expected []string
+		for i := 1; i <= 5; i++ {
+			expected = append(expected, fmt.Sprintf("question %d|answer %d", i, i))
+		}
+		for _, user := range []string{"sarah", "bob"} {
+			if got := stored(server, user); got != strings.Join(expected, "|") {
+				t.Errorf("Expected the turns of %s in order, got %q", user, got)
+			}
+		}
+	})
+
+	t.Run("Retries", func(t *testing.T) {
+		t.Parallel()
+		server, backend := newServer(t, mem0test.WithFault(memorytest.Fault{
+			Method: http.MethodPost, Path: addPath, StatusCode: http.StatusServiceUnavailable, Times: 2,
+		}))
+		q := newQueue(t, filepath.Join(t.TempDir(), "wal"), backend)
+		saveTurn(t, NewMemory(q, "sarah"), 1)
+
+		if err := q.Flush(ctx); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+		if n := server.RequestCount(http.MethodPost, addPath); n != 3 {
+			t.Errorf("Expected 2 retries, got %d requests", n)
+		}
+	})
+
+	t.Run("DropsRejectedWrites", func(t *testing.T) {
+		t.Parallel()
+		server, backend := newServer(t, mem0test.WithFault(memorytest.Fault{
+			Method: http.MethodPost, Path: addPath, StatusCode: http.StatusBadRequest, Times: 1,
+		}))
+		failed := make(chan error, 1)
+		path := filepath.Join(t.TempDir(), "wal")
+		q := newQueue(t, path, backend,
+			WithOnError(func(_ string, err error) { failed <- err }))
+		m := NewMemory(q, "sarah")
+		saveTurn(t, m, 1)
+
+		var dropped *DroppedWriteError
+		if err := <-failed; !errors.As(err, &dropped) || !errors.Is(err, core.ErrInvalidInput) || len(dropped.Messages) != 2 {
+			t.Errorf("Expected the invalid write to be dropped, got %v", err)
+		}
+		saveTurn(t, m, 2)
+		if err := q.Flush(ctx); err != nil {
+			t.Fatalf("Expected the session to go on after the dropped write, got %v", err)
+		}
+		if got := stored(server, "sarah"); got != "question 2|answer 2" {
+			t.Errorf("Expected the next write to be delivered, got %q", got)
+		}
+		if n := server.RequestCount(http.MethodPost, addPath); n != 2 {
+			t.Errorf("Expected no retries of an invalid write, got %d requests", n)
+		}
+
+		if err := q.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		reopened := newQueue(t, path, backend)
+		if err := reopened.Flush(ctx); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+		if n := server.RequestCount(http.MethodPost, addPath); n != 2 {
+			t.Errorf("Expected the dropped write to be acknowledged in the log, got %d requests", n)
+		}
+	})
+
+	t.Run("AttemptTimeout", func(t *testing.T) {
+		t.Parallel()
+		server := mem0test.NewServer(mem0test.WithFault(memorytest.Fault{
+			Method: http.MethodPost, Path: addPath, Latency: 5 * time.Second,
+		}))
+		t.Cleanup(server.Close)
+		backend := func(sessionID string) core.Backend {
+			return mem0.NewClientChatMessageHistory(server.ClientOptions(), sessionID)
+		}
+		q := newQueue(t, filepath.Join(t.TempDir(), "wal"), backend,
+			WithAttemptTimeout(20*time.Millisecond), WithMaxAttempts(2))
+		saveTurn(t, NewMemory(q, "sarah"), 1)
+
+		start := time.Now()
+		if err := q.Flush(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected the attempts to time out, got %v", err)
+		}
+		if n := server.RequestCount(http.MethodPost, addPath); n != 2 {
+			t.Errorf("Expected a timed out send to be retried, got %d requests", n)
+		}
+
+		saveTurn(t, NewMemory(q, "sarah"), 2)
+		if err := q.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Expected Close not to wait for the hanging send, took %v", elapsed)
+		}
+	})
+
+	t.Run("StallsUntilFlush", func(t *testing.T) {
+		t.Parallel()
+		server, backend := newServer(t, mem0test.WithFault(memorytest.Fault{
+			Method: http.MethodPost, Path: addPath, StatusCode: http.StatusServiceUnavailable,
+		}))
+		var failures atomic.Int32
+		q := newQueue(t, filepath.Join(t.TempDir(), "wal"), backend, WithMaxAttempts(2),
+			WithOnError(func(string, error) { failures.Add(1) }))
+		saveTurn(t, NewMemory(q, "sarah"), 1)
+
+		if err := q.Flush(ctx); err == nil || !strings.Contains(err.Error(), "session sarah") {
+			t.Errorf("Expected the flush error of sarah, got %v", err)
+		}
+		if failures.Load() == 0 {
+			t.Errorf("Expected OnError to be called")
+		}
+
+		server.ClearFaults()
+		if err := q.Flush(ctx); err != nil {
+			t.Fatalf("Expected Flush to retry the stalled write, got %v", err)
+		}
+		if got := stored(server, "sarah"); got != "question 1|answer 1" {
+			t.Errorf("Expected the turn in mem0, got %q", got)
+		}
+	})
+
+	t.Run("ReplaysAfterRestart", func(t *testing.T) {
+		t.Parallel()
+		server, backend := newServer(t, mem0test.WithFault(memorytest.Fault{
+			Method: http.MethodPost, Path: addPath, StatusCode: http.StatusServiceUnavailable,
+		}))
+		path := filepath.Join(t.TempDir(), "wal")
+		q, err := NewQueue(path, backend, WithMaxAttempts(1))
+		if err != nil {
+			t.Fatalf("NewQueue: %v", err)
+		}
+		saveTurn(t, NewMemory(q, "sarah"), 1)
+		saveTurn(t, NewMemory(q, "sarah"), 2)
+		// Close interrupts sends in flight, which may still land; let the session stall first.
+		if err := q.Flush(ctx); err == nil {
+			t.Fatalf("Expected the flush to fail")
+		}
+		if err := q.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		if err := NewMemory(q, "sarah").SaveContext(ctx, map[string]any{"input": "x"}, map[string]any{"output": "y"}); !errors.Is(err, ErrClosed) {
+			t.Errorf("Expected ErrClosed after Close, got %v", err)
+		}
+
+		server.ClearFaults()
+		q = newQueue(t, path, backend)
+		if err := q.Flush(ctx); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+		if got := stored(server, "sarah"); got != "question 1|answer 1|question 2|answer 2" {
+			t.Errorf("Expected the logged turns to be replayed in order, got %q", got)
+		}
+		if data, err := os.ReadFile(path); err != nil || len(data) != 0 {
+			t.Errorf("Expected the log to be emptied once flushed, got %q, %v", data, err)
+		}
+	})
+
+	t.Run("TornLastLine", func(t *testing.T) {
+		t.Parallel()
+		server, backend := newServer(t)
+		path := filepath.Join(t.TempDir(), "wal")
+		log := `{"seq":1,"session_id":"sarah","messages":[{"type":"human","content":"hi"}]}
+{"seq":2,"session_id":"sarah","messages":[{"type":"human","content":"lost"}]}
+{"ack":2}
+{"seq":3,"session_id":"sarah","mess`
+		if err := os.WriteFile(path, []byte(log), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+
+		q := newQueue(t, path, backend)
+		if err := q.Flush(ctx); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+		if got := stored(server, "sarah"); got != "hi" {
+			t.Errorf("Expected only the complete unacknowledged write to be replayed, got %q", got)
+		}
+	})
+}
+
+func TestResolver(t *testing.T) {
+	t.Parallel()
+	ctx := core.ContextWithUserID(context.Background(), "sarah")
+
+	server := mem0test.NewServer()
+	t.Cleanup(server.Close)
+	q := newQueue(t, filepath.Join(t.TempDir(), "wal"), func(string) core.Backend {
+		return mem0.NewClientChatMessageHistory(server.ClientOptions(), "", mem0.WithChatHistoryResolver(core.ResolveFromContext))
+	})
+	err := NewChatMessageHistory(q, "sarah").AddUserMessage(ctx, "hello")
+	if !errors.Is(err, core.ErrResolverUnsupported) {
+		t.Errorf("Expected ErrResolverUnsupported for a resolving backend, got %v", err)
+	}
+
+	memorytest.RunUserIsolationSuite(t, func(t *testing.T) schema.ChatMessageHistory {
+		_, backend := newServer(t)
+		q := newQueue(t, filepath.Join(t.TempDir(), "wal"), backend)
+		return NewChatMessageHistory(q, "", WithChatHistoryResolver(core.ResolveFromContext))
+	})
+}
+
+func TestConformance(t *testing.T) {
+	t.Parallel()
+
+	memorytest.RunChatMessageHistorySuite(t, func(t *testing.T) schema.ChatMessageHistory {
+		_, backend := newServer(t)
+		return NewChatMessageHistory(newQueue(t, filepath.Join(t.TempDir(), "wal"), backend), "sarah")
+	}, memorytest.WithoutSetMessages())
+	memorytest.RunMemorySuite(t, func(t *testing.T) schema.Memory {
+		_, backend := newServer(t)
+		return NewMemory(newQueue(t, filepath.Join(t.TempDir(), "wal"), backend), "sarah")
+	})
+}
+
+func TestEndsWith(t *testing.T) {
+	t.Parallel()
+
+	messages := []llms.ChatMessage{llms.HumanChatMessage{Content: "a"}, llms.AIChatMessage{Content: "b"}}
+	if !endsWith(messages, messages[1:]) || endsWith(messages[1:], messages) ||
+		endsWith(messages, []llms.ChatMessage{llms.HumanChatMessage{Content: "b"}}) {
+		t.Errorf("Expected endsWith to compare types and contents of the tail")
+	}
+}
@@ -0,0 +1,188 @@
+package writebehind
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// ChatMessageHistory writes the messages of a session through a Queue and reads them from
+// the session's backend, followed by the writes that are still queued.
+type ChatMessageHistory struct {
+	Queue     *Queue
+	SessionID string
+	Backend   core.Backend
+	// Resolver, if set, resolves the session of every call from its context, so that one
+	// history serves every session of the queue. The resolved session is used, or the
+	// resolved user if it resolves no session, and SessionID if it resolves neither.
+	Resolver core.Resolver
+}
+
+// Statically assert that ChatMessageHistory implement the chat message history interface.
+var _ schema.ChatMessageHistory = &ChatMessageHistory{}
+
+// Statically assert that ChatMessageHistory can be used as a core memory backend.
+var _ core.Backend = &ChatMessageHistory{}
+
+// Statically assert that ChatMessageHistory can tell wrappers whether it resolves identities.
+var _ core.IdentityResolver = &ChatMessageHistory{}
+
+// NewChatMessageHistory returns the history of a session whose writes go through queue.
+func NewChatMessageHistory(queue *Queue, sessionID string, options ...ChatMessageHistoryOption) *ChatMessageHistory {
+	h := applyChatHistoryOptions(options...)
+	h.Queue = queue
+	h.SessionID = sessionID
+	if h.Resolver == nil || sessionID != "" {
+		queue.mu.Lock()
+		h.Backend = queue.session(sessionID).backend
+		queue.mu.Unlock()
+	}
+	return h
+}
+
+// NewMemory returns a memory for a session whose SaveContext returns as soon as the turn is in
+// the write-ahead log of queue.
+func NewMemory(queue *Queue, sessionID string, options ...core.Option) *core.Memory {
+	return core.NewMemory(NewChatMessageHistory(queue, sessionID), options...)
+}
+
+// ResolvesIdentity reports whether the history has a Resolver.
+func (h *ChatMessageHistory) ResolvesIdentity() bool {
+	return h.Resolver != nil
+}
+
+// session returns the session of a call and its backend, resolved from ctx if the history has
+// a Resolver.
+func (h *ChatMessageHistory) session(ctx context.Context) (string, core.Backend, error) {
+	if h.Resolver == nil {
+		if core.ResolvesIdentity(h.Backend) {
+			return "", nil, fmt.Errorf("writebehind: %w", core.ErrResolverUnsupported)
+		}
+		return h.SessionID, h.Backend, nil
+	}
+	userID, sessionID, err := core.Resolve(ctx, h.Resolver, "", "")
+	if err != nil {
+		return "", nil, fmt.Errorf("writebehind: resolving session: %w", err)
+	}
+	for _, id := range []string{sessionID, userID, h.SessionID} {
+		if id != "" {
+			backend, err := h.Queue.backendOf(id)
+			return id, backend, err
+		}
+	}
+	return "", nil, fmt.Errorf("writebehind: %w", core.ErrNoIdentity)
+}
+
+// Messages returns the messages of the backend followed by the queued writes of the session,
+// so that a session reads its own writes before they are flushed.
+func (h *ChatMessageHistory) Messages(ctx context.Context) ([]llms.ChatMessage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	sessionID, backend, err := h.session(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pending := h.Queue.pending(sessionID)
+	messages, err := backend.Messages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// The oldest queued writes may have reached the backend while it was read.
+	pending = pending[delivered(messages, pending):]
+	for _, write := range pending {
+		messages = append(messages, write...)
+	}
+	return messages, nil
+}
+
+// delivered returns how many of the oldest queued writes end the messages of the backend.
+func delivered(messages []llms.ChatMessage, pending [][]llms.ChatMessage) int {
+	for n := len(pending); n > 0; n-- {
+		var writes []llms.ChatMessage
+		for _, write := range pending[:n] {
+			writes = append(writes, write...)
+		}
+		if endsWith(messages, writes) {
+			return n
+		}
+	}
+	return 0
+}
+
+func endsWith(messages, suffix []llms.ChatMessage) bool {
+	if len(suffix) > len(messages) {
+		return false
+	}
+	offset := len(messages) - len(suffix)
+	for i, message := range suffix {
+		if messageType(messages[offset+i]) != messageType(message) || messages[offset+i].GetContent() != message.GetContent() {
+			return false
+		}
+	}
+	return true
+}
+
+// messageType returns the type of a message, with function messages as tool messages, since
+// backends commonly store both under one role.
+func messageType(message llms.ChatMessage) llms.ChatMessageType {
+	if message.GetType() == llms.ChatMessageTypeFunction {
+		return llms.ChatMessageTypeTool
+	}
+	return message.GetType()
+}
+
+// AddMessage queues a message.
+func (h *ChatMessageHistory) AddMessage(ctx context.Context, message llms.ChatMessage) error {
+	return h.AddMessages(ctx, []llms.ChatMessage{message})
+}
+
+// AddUserMessage queues a user message.
+func (h *ChatMessageHistory) AddUserMessage(ctx context.Context, text string) error {
+	return h.AddMessage(ctx, llms.HumanChatMessage{Content: text})
+}
+
+// AddAIMessage queues an AI message.
+func (h *ChatMessageHistory) AddAIMessage(ctx context.Context, text string) error {
+	return h.AddMessage(ctx, llms.AIChatMessage{Content: text})
+}
+
+// AddMessages queues messages as a single write, so they are sent in one request.
+func (h *ChatMessageHistory) AddMessages(ctx context.Context, messages []llms.ChatMessage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	sessionID, _, err := h.session(ctx)
+	if err != nil {
+		return err
+	}
+	return h.Queue.Enqueue(sessionID, messages)
+}
+
+// Clear waits for the queued writes of the session and clears the backend.
+func (h *ChatMessageHistory) Clear(ctx context.Context) error {
+	sessionID, backend, err := h.session(ctx)
+	if err != nil {
+		return err
+	}
+	if err := h.Queue.FlushSession(ctx, sessionID); err != nil {
+		return err
+	}
+	return backend.Clear(ctx)
+}
+
+// SetMessages waits for the queued writes of the session and replaces the messages of the
+// backend.
+func (h *ChatMessageHistory) SetMessages(ctx context.Context, messages []llms.ChatMessage) error {
+	sessionID, backend, err := h.session(ctx)
+	if err != nil {
+		return err
+	}
+	if err := h.Queue.FlushSession(ctx, sessionID); err != nil {
+		return err
+	}
+	return backend.SetMessages(ctx, messages)
+}
@@ -0,0 +1,26 @@
+package writebehind
+
+import "github.com/0xDezzy/langchaingo-memory/memory/core"
+
+// ChatMessageHistoryOption is a function for creating a new write-behind chat message history
+// with other than the default values.
+type ChatMessageHistoryOption func(h *ChatMessageHistory)
+
+// WithChatHistoryResolver is an option for resolving the session of every call from its
+// context, such as with core.ResolveFromContext, so that one history serves every session of
+// the queue.
+func WithChatHistoryResolver(resolver core.Resolver) ChatMessageHistoryOption {
+	return func(h *ChatMessageHistory) {
+		h.Resolver = resolver
+	}
+}
+
+func applyChatHistoryOptions(options ...ChatMessageHistoryOption) *ChatMessageHistory {
+	h := &ChatMessageHistory{}
+
+	for _, option := range options {
+		option(h)
+	}
+
+	return h
+}
@@ -0,0 +1,388 @@
+// Package writebehind takes memory writes off the response path. Writes are appended to a
+// local write-ahead log and acknowledged right away; a background flusher then sends them to
+// mem0 or Zep, in order per session, retrying transient failures. Writes still in the log when
+// the process stops are replayed when the queue is opened again, so a write may be delivered
+// more than once.
+package writebehind
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/0xDezzy/langchaingo-memory/memory/core"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// ErrClosed is returned for writes to a closed queue.
+var ErrClosed = errors.New("writebehind: queue closed")
+
+// DroppedWriteError is passed to the OnError function for a write that the backend rejected
+// with an error that is not retried, such as an invalid request. The write is removed from the
+// queue and the log, so that the writes after it are still delivered.
+type DroppedWriteError struct {
+	SessionID string
+	Messages  []llms.ChatMessage
+	Err       error
+}
+
+func (e *DroppedWriteError) Error() string {
+	return fmt.Sprintf("writebehind: dropped write of %d messages to session %s: %v", len(e.Messages), e.SessionID, e.Err)
+}
+
+func (e *DroppedWriteError) Unwrap() error {
+	return e.Err
+}
+
+// BackendFunc returns the backend that the writes of a session are flushed to, such as a mem0
+// or graphiti chat message history for that session. Writes are flushed without the context of
+// their caller, so backends that resolve the identity of every call from it are rejected with
+// core.ErrResolverUnsupported.
+type BackendFunc func(sessionID string) core.Backend
+
+// Queue is a durable queue of memory writes shared by any number of sessions. It is safe for
+// concurrent use.
+type Queue struct {
+	backend     BackendFunc
+	maxAttempts int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	// attemptTimeout limits every send of a write to the backend.
+	attemptTimeout time.Duration
+	onError        func(sessionID string, err error)
+
+	// stop is cancelled by Close to interrupt retry backoffs.
+	stop   context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	wal      *wal
+	seq      uint64
+	sessions map[string]*session
+	closed   bool
+	// changed is closed and replaced whenever a session makes progress or stalls.
+	changed chan struct{}
+}
+
+// session holds the writes of a session that are not flushed yet.
+type session struct {
+	backend core.Backend
+	pending []record
+	// unsynced holds the sequence numbers of the pending writes whose Enqueue has not synced
+	// them yet. They are not delivered before then, and are removed if the sync fails.
+	unsynced map[uint64]bool
+	// running is set while a flusher goroutine works on the session.
+	running bool
+	// err is the error of the last failed flush, until a flush succeeds.
+	err error
+}
+
+// NewQueue opens the write-ahead log at path, creating it if needed, and starts flushing the
+// writes it still holds to the backends returned by backend.
+func NewQueue(path string, backend BackendFunc, options ...QueueOption) (*Queue, error) {
+	q := applyQueueOptions(options...)
+	q.backend = backend
+	q.stop, q.cancel = context.WithCancel(context.Background())
+	q.sessions = make(map[string]*session)
+	q.changed = make(chan struct{})
+
+	w, pending, err := openWAL(path)
+	if err != nil {
+		return nil, err
+	}
+	q.wal = w
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, r := range pending {
+		q.seq = max(q.seq, r.Seq)
+		s := q.session(r.SessionID)
+		s.pending = append(s.pending, r)
+	}
+	for sessionID, s := range q.sessions {
+		q.start(sessionID, s)
+	}
+	return q, nil
+}
+
+// session returns the state of a session, creating it if needed. The caller must hold q.mu.
+func (q *Queue) session(sessionID string) *session {
+	s, ok := q.sessions[sessionID]
+	if !ok {
+		s = &session{backend: q.backend(sessionID)}
+		q.sessions[sessionID] = s
+	}
+	return s
+}
+
+// ready reports whether the next pending write of the session can be delivered. The caller
+// must hold q.mu.
+func (s *session) ready() bool {
+	return len(s.pending) > 0 && !s.unsynced[s.pending[0].Seq]
+}
+
+// start starts a flusher for the session unless one is running. The caller must hold q.mu.
+func (q *Queue) start(sessionID string, s *session) {
+	if s.running || !s.ready() || q.closed {
+		return
+	}
+	s.running = true
+	q.wg.Add(1)
+	go q.flush(sessionID, s)
+}
+
+// notify wakes up the goroutines waiting in Flush. The caller must hold q.mu.
+func (q *Queue) notify() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// backendOf returns the backend of a session, or an error wrapping
+// core.ErrResolverUnsupported if it resolves the identity of every call.
+func (q *Queue) backendOf(sessionID string) (core.Backend, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.session(sessionID).checkBackend()
+}
+
+// checkBackend returns the backend of the session, unless it resolves the identity of every
+// call from a context that the flusher does not have.
+func (s *session) checkBackend() (core.Backend, error) {
+	if core.ResolvesIdentity(s.backend) {
+		return nil, fmt.Errorf("writebehind: %w", core.ErrResolverUnsupported)
+	}
+	return s.backend, nil
+}
+
+// Enqueue appends a write for the session to the log and returns once it is on disk. The
+// messages are sent to the backend in the background, after the earlier writes of the session,
+// but not before they are on disk: a write that fails to sync is never sent, so that callers
+// may retry it. The log is synced without holding up the other sessions, and writes enqueued
+// together share one sync.
+func (q *Queue) Enqueue(sessionID string, messages []llms.ChatMessage) error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return ErrClosed
+	}
+	s := q.session(sessionID)
+	if _, err := s.checkBackend(); err != nil {
+		q.mu.Unlock()
+		return err
+	}
+	r := record{Seq: q.seq + 1, SessionID: sessionID, Messages: toWALMessages(messages)}
+	position, err := q.wal.append(r)
+	if err != nil {
+		q.mu.Unlock()
+		return err
+	}
+	q.seq = r.Seq
+	s.pending = append(s.pending, r)
+	if s.unsynced == nil {
+		s.unsynced = make(map[uint64]bool)
+	}
+	s.unsynced[r.Seq] = true
+	q.mu.Unlock()
+
+	err = q.wal.sync(position)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(s.unsynced, r.Seq)
+	if err != nil {
+		s.pending = slices.DeleteFunc(s.pending, func(p record) bool { return p.Seq == r.Seq })
+		// The write may still reach the disk; the acknowledgement keeps it from being replayed.
+		_, _ = q.wal.append(record{Ack: r.Seq})
+	}
+	q.start(sessionID, s)
+	q.notify()
+	return err
+}
+
+// flush sends the pending writes of a session to its backend, one at a time, until there are
+// none left that are on disk, a write fails every attempt, or the queue is closed. Writes that
+// the backend rejects are dropped and reported as a *DroppedWriteError.
+func (q *Queue) flush(sessionID string, s *session) {
+	defer q.wg.Done()
+	for {
+		q.mu.Lock()
+		if !s.ready() || q.closed {
+			s.running = false
+			q.notify()
+			q.mu.Unlock()
+			return
+		}
+		r := s.pending[0]
+		q.mu.Unlock()
+
+		err := q.deliver(s.backend, r)
+
+		q.mu.Lock()
+		if err != nil && (q.closed || !permanent(err)) {
+			s.running = false
+			s.err = err
+			closed := q.closed
+			q.notify()
+			q.mu.Unlock()
+			if q.onError != nil && !closed {
+				q.onError(sessionID, err)
+			}
+			return
+		}
+		s.pending = s.pending[1:]
+		s.err = nil
+		// A lost acknowledgement only means the write is delivered again after a restart.
+		_, _ = q.wal.append(record{Ack: r.Seq})
+		if q.idle() {
+			_ = q.wal.rewrite(nil)
+		}
+		q.notify()
+		q.mu.Unlock()
+		if err != nil && q.onError != nil {
+			q.onError(sessionID, &DroppedWriteError{SessionID: sessionID, Messages: fromWALMessages(r.Messages), Err: err})
+		}
+	}
+}
+
+// permanent reports whether a failed write would fail the same way every time it is sent, so
+// that keeping it queued would stall its session for good.
+func permanent(err error) bool {
+	return core.ClassifyError(err) == core.NotRetryable &&
+		!errors.Is(err, context.Canceled) && !errors.Is(err, core.ErrUnavailable)
+}
+
+// deliver sends a write, retrying transient failures with exponential backoff. Errors that
+// would fail the same way again, such as invalid requests, end the retries right away. Every
+// attempt is limited by the attempt timeout and interrupted by Close; an interrupted write may
+// have been applied, and is sent again by the next NewQueue.
+func (q *Queue) deliver(backend core.Backend, r record) error {
+	backoff := q.minBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = q.attempt(backend, r)
+		if err == nil || attempt >= q.maxAttempts || core.ClassifyError(err) == core.NotRetryable {
+			return err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-q.stop.Done():
+			return err
+		}
+		backoff = min(2*backoff, q.maxBackoff)
+	}
+}
+
+// attempt sends a write once.
+func (q *Queue) attempt(backend core.Backend, r record) error {
+	ctx, cancel := q.stop, context.CancelFunc(func() {})
+	if q.attemptTimeout > 0 {
+		ctx, cancel = context.WithTimeout(q.stop, q.attemptTimeout)
+	}
+	defer cancel()
+	return backend.AddMessages(ctx, fromWALMessages(r.Messages))
+}
+
+// idle reports whether every write was flushed. The caller must hold q.mu.
+func (q *Queue) idle() bool {
+	for _, s := range q.sessions {
+		if len(s.pending) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// pending returns the writes of the session that are not flushed yet, in order.
+func (q *Queue) pending(sessionID string) [][]llms.ChatMessage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	s, ok := q.sessions[sessionID]
+	if !ok {
+		return nil
+	}
+	writes := make([][]llms.ChatMessage, 0, len(s.pending))
+	for _, r := range s.pending {
+		writes = append(writes, fromWALMessages(r.Messages))
+	}
+	return writes
+}
+
+// Flush waits until every write enqueued so far is sent to its backend. Sessions whose
+// flushing stopped after a failure are retried first. If writes still fail every attempt,
+// Flush returns their errors; they stay queued.
+func (q *Queue) Flush(ctx context.Context) error {
+	return q.wait(ctx, func(string) bool { return true })
+}
+
+// FlushSession is like Flush for the writes of one session.
+func (q *Queue) FlushSession(ctx context.Context, sessionID string) error {
+	return q.wait(ctx, func(id string) bool { return id == sessionID })
+}
+
+func (q *Queue) wait(ctx context.Context, match func(sessionID string) bool) error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return ErrClosed
+	}
+	for sessionID, s := range q.sessions {
+		if match(sessionID) {
+			q.start(sessionID, s)
+		}
+	}
+	for {
+		if q.closed {
+			q.mu.Unlock()
+			return ErrClosed
+		}
+		busy := false
+		var errs []error
+		for sessionID, s := range q.sessions {
+			if !match(sessionID) || len(s.pending) == 0 {
+				continue
+			}
+			if s.running || !s.ready() {
+				busy = true
+				continue
+			}
+			errs = append(errs, fmt.Errorf("writebehind: flushing session %s: %w", sessionID, s.err))
+		}
+		if !busy {
+			q.mu.Unlock()
+			return errors.Join(errs...)
+		}
+		changed := q.changed
+		q.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		q.mu.Lock()
+	}
+}
+
+// Close stops flushing and closes the log. Sends in flight are interrupted, and writes that
+// are still queued stay in the log and are replayed by the next NewQueue. Call Flush first to
+// send them now.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil
+	}
+	q.closed = true
+	q.mu.Unlock()
+
+	q.cancel()
+	q.wg.Wait()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.wal.close()
+}
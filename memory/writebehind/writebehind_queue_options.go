@@ -0,0 +1,57 @@
+package writebehind
+
+import "time"
+
+// QueueOption is a function for creating a new queue
+// with other than the default values.
+type QueueOption func(q *Queue)
+
+// WithMaxAttempts is an option for specifying how many times a write is sent before its
+// session stops flushing until the next write or Flush. Defaults to 5.
+func WithMaxAttempts(maxAttempts int) QueueOption {
+	return func(q *Queue) {
+		q.maxAttempts = maxAttempts
+	}
+}
+
+// WithBackoff is an option for specifying the wait before the first retry of a write, doubled
+// for every following retry up to maxBackoff. Defaults to 200ms and 10s.
+func WithBackoff(minBackoff, maxBackoff time.Duration) QueueOption {
+	return func(q *Queue) {
+		q.minBackoff = minBackoff
+		q.maxBackoff = maxBackoff
+	}
+}
+
+// WithAttemptTimeout is an option for specifying how long a send of a write may take before
+// it is abandoned and retried. Zero sends without a timeout. Defaults to 30s.
+func WithAttemptTimeout(timeout time.Duration) QueueOption {
+	return func(q *Queue) {
+		q.attemptTimeout = timeout
+	}
+}
+
+// WithOnError is an option for specifying a function that is called when a write of a session
+// failed every attempt, in which case it stays queued, or failed with an error that is not
+// retried, in which case it is dropped and err is a *DroppedWriteError.
+func WithOnError(onError func(sessionID string, err error)) QueueOption {
+	return func(q *Queue) {
+		q.onError = onError
+	}
+}
+
+func applyQueueOptions(options ...QueueOption) *Queue {
+	q := &Queue{
+		maxAttempts: 5,
+		minBackoff:  200 * time.Millisecond,
+		maxBackoff:  10 * time.Second,
+		// Backends set their own, shorter timeouts; this one only ends sends that hang.
+		attemptTimeout: 30 * time.Second,
+	}
+
+	for _, option := range options {
+		option(q)
+	}
+
+	return q
+}
@@ -0,0 +1,231 @@
+package writebehind
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// record is a line of the write-ahead log: either a write or the acknowledgement that the
+// write with sequence number Ack reached the backend.
+type record struct {
+	Seq       uint64       `json:"seq,omitempty"`
+	SessionID string       `json:"session_id,omitempty"`
+	Messages  []walMessage `json:"messages,omitempty"`
+	Ack       uint64       `json:"ack,omitempty"`
+}
+
+// walMessage is a chat message as stored in the write-ahead log.
+type walMessage struct {
+	Type    llms.ChatMessageType `json:"type"`
+	Content string               `json:"content"`
+	Name    string               `json:"name,omitempty"`
+	ID      string               `json:"id,omitempty"`
+}
+
+func toWALMessages(messages []llms.ChatMessage) []walMessage {
+	stored := make([]walMessage, 0, len(messages))
+	for _, message := range messages {
+		m := walMessage{Type: message.GetType(), Content: message.GetContent()}
+		switch message := message.(type) {
+		case llms.FunctionChatMessage:
+			m.Name = message.Name
+		case llms.ToolChatMessage:
+			m.ID = message.ID
+		case llms.GenericChatMessage:
+			m.Name = message.Role
+		}
+		stored = append(stored, m)
+	}
+	return stored
+}
+
+func fromWALMessages(stored []walMessage) []llms.ChatMessage {
+	messages := make([]llms.ChatMessage, 0, len(stored))
+	for _, m := range stored {
+		switch m.Type {
+		case llms.ChatMessageTypeHuman:
+			messages = append(messages, llms.HumanChatMessage{Content: m.Content})
+		case llms.ChatMessageTypeAI:
+			messages = append(messages, llms.AIChatMessage{Content: m.Content})
+		case llms.ChatMessageTypeSystem:
+			messages = append(messages, llms.SystemChatMessage{Content: m.Content})
+		case llms.ChatMessageTypeFunction:
+			messages = append(messages, llms.FunctionChatMessage{Name: m.Name, Content: m.Content})
+		case llms.ChatMessageTypeTool:
+			messages = append(messages, llms.ToolChatMessage{ID: m.ID, Content: m.Content})
+		default:
+			messages = append(messages, llms.GenericChatMessage{Role: m.Name, Content: m.Content})
+		}
+	}
+	return messages
+}
+
+// wal is an append-only log of JSON lines. It is safe for concurrent use, so that writes can
+// be synced to disk without holding the lock of the queue.
+type wal struct {
+	path string
+
+	// syncMu serializes syncs, so that writes waiting for one share the next.
+	syncMu sync.Mutex
+	mu     sync.Mutex
+	file   *os.File
+	// written and synced count the records appended and the records known to be on disk.
+	written, synced uint64
+}
+
+// openWAL opens the log at path and returns the writes that were not acknowledged, in order.
+// A last line cut short by a crash is ignored. The log is rewritten with only those writes.
+func openWAL(path string) (*wal, []record, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("writebehind: %w", err)
+	}
+
+	var writes []record
+	acked := make(map[uint64]bool)
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var r record
+		if err := json.Unmarshal(line, &r); err != nil {
+			if i == len(lines)-1 {
+				break
+			}
+			return nil, nil, fmt.Errorf("writebehind: reading %s line %d: %w", path, i+1, err)
+		}
+		if r.Ack != 0 {
+			acked[r.Ack] = true
+			continue
+		}
+		writes = append(writes, r)
+	}
+	pending := writes[:0]
+	for _, r := range writes {
+		if !acked[r.Seq] {
+			pending = append(pending, r)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, nil, fmt.Errorf("writebehind: %w", err)
+	}
+	w := &wal{path: path}
+	if err := w.rewrite(pending); err != nil {
+		return nil, nil, err
+	}
+	return w, pending, nil
+}
+
+// rewrite atomically replaces the log with the given writes, synced to disk, and reopens it
+// for appending.
+func (w *wal) rewrite(writes []record) error {
+	var buf bytes.Buffer
+	for _, r := range writes {
+		line, err := json.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("writebehind: %w", err)
+		}
+		buf.Write(append(line, '\n'))
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	tmp := w.path + ".tmp"
+	if err := writeSynced(tmp, buf.Bytes()); err != nil {
+		return fmt.Errorf("writebehind: %w", err)
+	}
+	if err := os.Rename(tmp, w.path); err != nil {
+		return fmt.Errorf("writebehind: %w", err)
+	}
+	if w.file != nil {
+		w.file.Close()
+	}
+	file, err := os.OpenFile(w.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("writebehind: %w", err)
+	}
+	w.file = file
+	w.synced = w.written
+	return nil
+}
+
+// writeSynced writes data to the file at path and syncs it to disk.
+func writeSynced(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// append writes a record without syncing it to disk and returns its position, to be passed to
+// sync. Acknowledgements are never synced: losing one only means the write is delivered again
+// after a restart.
+func (w *wal) append(r record) (uint64, error) {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return 0, fmt.Errorf("writebehind: %w", err)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.file.Write(append(line, '\n')); err != nil {
+		return 0, fmt.Errorf("writebehind: %w", err)
+	}
+	w.written++
+	return w.written, nil
+}
+
+// sync returns once the record at position n is on disk. A single sync covers every record
+// appended before it, so concurrent writes share it. Appends are not blocked while the file is
+// synced.
+func (w *wal) sync(n uint64) error {
+	w.syncMu.Lock()
+	defer w.syncMu.Unlock()
+	w.mu.Lock()
+	file, written := w.file, w.written
+	done := w.synced >= n
+	w.mu.Unlock()
+	if done {
+		return nil
+	}
+
+	err := file.Sync()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err == nil && file == w.file {
+		w.synced = max(w.synced, written)
+	}
+	// A rewrite replacing the file in the meantime synced the record, or dropped it once it
+	// was delivered.
+	if w.synced >= n {
+		return nil
+	}
+	if err == nil {
+		err = errors.New("log rewritten")
+	}
+	return fmt.Errorf("writebehind: %w", err)
+}
+
+func (w *wal) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}